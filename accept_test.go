@@ -0,0 +1,184 @@
+package zbx_test
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// exhaustedListener fails to accept connections with EMFILE while failures is greater than 0
+type exhaustedListener struct {
+	net.Listener
+	failures int32
+}
+
+func (l *exhaustedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if atomic.AddInt32(&l.failures, -1) >= 0 {
+		conn.Close()
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	}
+	return conn, nil
+}
+
+// Ensure that the server recovers from running out of file descriptors and sheds idle connections
+func TestAcceptExhausted(t *testing.T) {
+	t.Parallel()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	l := &exhaustedListener{Listener: inner}
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		ConnectionIdleTimeout: time.Minute,
+		ShedIdleConnections:   true,
+	}
+	go server.Serve(l)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	addr := inner.Addr().String()
+
+	idle, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer idle.Close()
+	if _, err := idle.Write(requestForKey("agent.ping")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	if _, err := zbxproto.ReadMessage(idle, nil); err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+
+	// The idle connection may not be recorded as idle immediately after the reply is sent
+	for i := 0; i < 100 && server.Stats().ShedConnections == 0; i++ {
+		atomic.StoreInt32(&l.failures, 1)
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			io.ReadAll(c)
+			c.Close()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := server.Stats()
+	if stats.ShedConnections != 1 {
+		t.Fatalf("Unexpected shed connections. Expected 1 got %d", stats.ShedConnections)
+	}
+	if stats.AcceptErrors == 0 {
+		t.Errorf("No accept errors counted")
+	}
+	if !stats.Exhausted {
+		t.Errorf("Server not reported as exhausted")
+	}
+
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := idle.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Idle connection not closed, read returned: %v", err)
+	}
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if _, err := c.Write(requestForKey("agent.ping")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	reply, err := zbxproto.ReadMessage(c, nil)
+	if err != nil {
+		t.Fatalf("Error reading reply after recovering: %s", err.Error())
+	}
+	if string(reply.Data) != "1" {
+		t.Errorf("Unexpected reply after recovering. Expected '1' got '%s'", reply.Data)
+	}
+	if server.Stats().Exhausted {
+		t.Errorf("Server still reported as exhausted after recovering")
+	}
+}
+
+// Ensure that a persistent connection is not shed while it is serving a request
+func TestShedIdleSkipsBusyConnection(t *testing.T) {
+	t.Parallel()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	l := &exhaustedListener{Listener: inner}
+	calls := int32(0)
+	serving := make(chan struct{})
+	release := make(chan struct{})
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			if atomic.AddInt32(&calls, 1) == 2 {
+				close(serving)
+				<-release
+			}
+			return 1, nil
+		},
+		ConnectionIdleTimeout: time.Minute,
+		ShedIdleConnections:   true,
+	}
+	go server.Serve(l)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	addr := inner.Addr().String()
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Write(requestForKey("agent.ping")); err != nil {
+			t.Fatalf("Error writing request: %s", err.Error())
+		}
+		if i == 0 {
+			if _, err := zbxproto.ReadMessage(c, nil); err != nil {
+				t.Fatalf("Error reading reply: %s", err.Error())
+			}
+		}
+	}
+
+	// The connection was idle between the requests, but is now busy with the second one
+	<-serving
+	for i := 0; i < 5; i++ {
+		atomic.StoreInt32(&l.failures, 1)
+		if c, err := net.Dial("tcp", addr); err == nil {
+			io.ReadAll(c)
+			c.Close()
+		}
+	}
+	for i := 0; i < 100 && server.Stats().AcceptErrors == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+
+	reply, err := zbxproto.ReadMessage(c, nil)
+	if err != nil {
+		t.Fatalf("Error reading reply for busy connection: %s", err.Error())
+	}
+	if string(reply.Data) != "1" {
+		t.Errorf("Unexpected reply. Expected '1' got '%s'", reply.Data)
+	}
+	if shed := server.Stats().ShedConnections; shed != 0 {
+		t.Errorf("Busy connection was shed. Expected 0 shed connections got %d", shed)
+	}
+}
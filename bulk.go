@@ -0,0 +1,48 @@
+package zbx
+
+import (
+	"sync"
+	"time"
+)
+
+// BulkItemFunc describes a method that computes the values for many related items at once, such as by parsing
+// a status page. It returns a map of item keys to their values.
+type BulkItemFunc func() (map[string]interface{}, error)
+
+// BulkItem returns an ItemFunc that responds with values from the map returned by bulkFunc. The result of bulkFunc
+// is cached for the duration of cacheFor, as measured by clock, so that multiple requests for related keys within that
+// window only invoke bulkFunc once. Errors from bulkFunc are returned for every key and are not cached. If clock is nil
+// SystemClock is used.
+//
+// Keys that are not present in the map are treated as unknown.
+func BulkItem(bulkFunc BulkItemFunc, cacheFor time.Duration, clock Clock) ItemFunc {
+	if bulkFunc == nil {
+		panic("bulkFunc is nil")
+	}
+	clock = clockOrSystem(clock)
+
+	var lock sync.Mutex
+	var values map[string]interface{}
+	var expires time.Time
+
+	return func(key string) (interface{}, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if values == nil || clock.Now().After(expires) {
+			result, err := bulkFunc()
+			if err != nil {
+				values = nil
+				return nil, err
+			}
+			values = result
+			expires = clock.Now().Add(cacheFor)
+		}
+
+		value, ok := values[key]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
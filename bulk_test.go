@@ -0,0 +1,91 @@
+package zbx_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestBulkItem(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	itemFunc := zbx.BulkItem(func() (map[string]interface{}, error) {
+		calls++
+		return map[string]interface{}{
+			"app.connections": 10,
+			"app.requests":    calls,
+		}, nil
+	}, time.Minute, nil)
+
+	connections, err := itemFunc("app.connections")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if connections != 10 {
+		t.Errorf("Unexpected value. Expected %d got %v", 10, connections)
+	}
+	requests, err := itemFunc("app.requests")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if requests != 1 {
+		t.Errorf("Unexpected value. Expected %d got %v", 1, requests)
+	}
+	unknown, err := itemFunc("app.unknown")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if unknown != nil {
+		t.Errorf("Unexpected value for unknown key: %v", unknown)
+	}
+	if calls != 1 {
+		t.Errorf("Bulk function called %d times when only 1 expected", calls)
+	}
+}
+
+func TestBulkItemExpires(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	calls := 0
+	itemFunc := zbx.BulkItem(func() (map[string]interface{}, error) {
+		calls++
+		return map[string]interface{}{
+			"app.calls": calls,
+		}, nil
+	}, time.Minute, clock)
+
+	itemFunc("app.calls")
+	clock.Advance(59 * time.Second)
+	if value, _ := itemFunc("app.calls"); value != 1 {
+		t.Errorf("Unexpected value before expiry. Expected %d got %v", 1, value)
+	}
+	clock.Advance(2 * time.Second)
+	value, _ := itemFunc("app.calls")
+	if value != 2 {
+		t.Errorf("Unexpected value. Expected %d got %v", 2, value)
+	}
+}
+
+func TestBulkItemError(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	itemFunc := zbx.BulkItem(func() (map[string]interface{}, error) {
+		calls++
+		return nil, fmt.Errorf("status page unavailable")
+	}, time.Minute, nil)
+
+	if _, err := itemFunc("app.connections"); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+	if _, err := itemFunc("app.connections"); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+	if calls != 2 {
+		t.Errorf("Bulk function called %d times when 2 expected", calls)
+	}
+}
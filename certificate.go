@@ -0,0 +1,92 @@
+package zbx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CertificateFunc describes a method that fetches the certificate for the agent, such as from a key vault or KMS.
+type CertificateFunc func() (*tls.Certificate, error)
+
+// CertificateFetcher returns a function for the GetCertificate field of a tls.Config, which presents the certificate
+// returned by fetch. The certificate is fetched on the first connection and cached until it is within renewBefore of
+// expiring, or until it has been cached for maxAge if maxAge is greater than 0, after which it is fetched again.
+// This allows certificates to be rotated without restarting the agent. Times are measured by clock, or SystemClock if
+// clock is nil.
+//
+// Connections that arrive while the certificate is being fetched wait for that fetch rather than making their own. If
+// fetching fails while the cached certificate has not yet expired, the error is written to ErrorLog and the cached
+// certificate is used. Fetch is retried on the next connection.
+//
+//	config := &tls.Config{GetCertificate: zbx.CertificateFetcher(fetchFromVault, 24*time.Hour, 0, nil)}
+//	zbx.StartTLSConfig(getItem, "0.0.0.0:10050", config)
+func CertificateFetcher(fetch CertificateFunc, renewBefore time.Duration, maxAge time.Duration, clock Clock) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if fetch == nil {
+		panic("fetch is nil")
+	}
+	clock = clockOrSystem(clock)
+
+	lock := sync.Mutex{}
+	var cached *tls.Certificate
+	var expires time.Time
+	var fetched time.Time
+	fetches := flightGroup{}
+
+	return func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		lock.Lock()
+		current, currentExpires := cached, expires
+		now := clock.Now()
+		fresh := cached != nil && now.Before(expires.Add(-renewBefore)) && (maxAge <= 0 || now.Sub(fetched) < maxAge)
+		lock.Unlock()
+		if fresh {
+			return current, nil
+		}
+
+		// The fetch is made without holding the lock, and is shared by every connection that needs it
+		value, err, shared := fetches.do("", func() (interface{}, error) {
+			certificate, notAfter, err := fetchCertificate(fetch)
+			if err != nil {
+				return nil, err
+			}
+			lock.Lock()
+			cached = certificate
+			expires = notAfter
+			fetched = clock.Now()
+			lock.Unlock()
+			return certificate, nil
+		})
+		if err != nil {
+			if current != nil && clock.Now().Before(currentExpires) {
+				if !shared {
+					errorWrite("Error fetching certificate, using cached certificate: %s", fmt.Sprintf("error='%s'", err.Error()))
+				}
+				return current, nil
+			}
+			return nil, err
+		}
+		return value.(*tls.Certificate), nil
+	}
+}
+
+// fetchCertificate calls fetch and returns the certificate with its expiry
+func fetchCertificate(fetch CertificateFunc) (*tls.Certificate, time.Time, error) {
+	certificate, err := fetch()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if certificate == nil || len(certificate.Certificate) == 0 {
+		return nil, time.Time{}, fmt.Errorf("no certificate returned")
+	}
+
+	leaf := certificate.Leaf
+	if leaf == nil {
+		leaf, err = x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid certificate: %s", err.Error())
+		}
+	}
+	return certificate, leaf.NotAfter, nil
+}
@@ -0,0 +1,130 @@
+package zbx_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestCertificateFetcher(t *testing.T) {
+	t.Parallel()
+
+	certificate := testCertificate(t)
+	fetches := 0
+	var fetchErr error
+	fetch := func() (*tls.Certificate, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return &certificate, nil
+	}
+
+	// The test certificate expires in an hour, so is cached
+	getCertificate := zbx.CertificateFetcher(fetch, time.Minute, 0, nil)
+	for i := 0; i < 3; i++ {
+		if _, err := getCertificate(nil); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Unexpected number of fetches. Expected 1 got %d", fetches)
+	}
+
+	// Renewing two hours before expiry fetches every time
+	fetches = 0
+	getCertificate = zbx.CertificateFetcher(fetch, 2*time.Hour, 0, nil)
+	getCertificate(nil)
+	getCertificate(nil)
+	if fetches != 2 {
+		t.Errorf("Unexpected number of fetches. Expected 2 got %d", fetches)
+	}
+
+	// The cached certificate is used if fetching fails before it expires
+	fetchErr = fmt.Errorf("vault unavailable")
+	if result, err := getCertificate(nil); err != nil || result != &certificate {
+		t.Errorf("Cached certificate not returned when fetch failed: %v", err)
+	}
+
+	// Errors are returned when there is no cached certificate
+	fetches = 0
+	getCertificate = zbx.CertificateFetcher(fetch, time.Minute, 0, nil)
+	if _, err := getCertificate(nil); err == nil {
+		t.Errorf("No error seen when fetch failed without a cached certificate")
+	}
+	fetchErr = nil
+	if _, err := getCertificate(nil); err != nil {
+		t.Errorf("Unexpected error after fetch recovered: %s", err.Error())
+	}
+	if fetches != 2 {
+		t.Errorf("Unexpected number of fetches. Expected 2 got %d", fetches)
+	}
+}
+
+func TestCertificateFetcherMaxAge(t *testing.T) {
+	t.Parallel()
+
+	certificate := testCertificate(t)
+	clock := newFakeClock()
+	fetches := 0
+	getCertificate := zbx.CertificateFetcher(func() (*tls.Certificate, error) {
+		fetches++
+		return &certificate, nil
+	}, 0, time.Minute, clock)
+
+	getCertificate(nil)
+	clock.Advance(59 * time.Second)
+	getCertificate(nil)
+	clock.Advance(time.Second)
+	getCertificate(nil)
+	if fetches != 2 {
+		t.Errorf("Unexpected number of fetches. Expected 2 got %d", fetches)
+	}
+}
+
+func TestCertificateFetcherConcurrent(t *testing.T) {
+	t.Parallel()
+
+	certificate := testCertificate(t)
+	var fetches int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	getCertificate := zbx.CertificateFetcher(func() (*tls.Certificate, error) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return &certificate, nil
+	}, time.Minute, 0, nil)
+
+	// Connections that arrive during a fetch share it
+	wg := sync.WaitGroup{}
+	results := make([]*tls.Certificate, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = getCertificate(nil)
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches := atomic.LoadInt32(&fetches); fetches != 1 {
+		t.Errorf("Unexpected number of fetches. Expected 1 got %d", fetches)
+	}
+	for i, result := range results {
+		if result != &certificate {
+			t.Errorf("Certificate not returned for connection %d", i)
+		}
+	}
+}
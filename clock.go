@@ -0,0 +1,65 @@
+package zbx
+
+import "time"
+
+// Clock is the source of time for a server and for the helpers that cache or sample values, such as BulkItem and
+// Counter, allowing tests to control time instead of sleeping, or embedders to provide their own time source.
+// Deadlines on connections always use the system time, as they are enforced by the operating system.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// NewTimer returns a timer that sends the current time on its channel after at least d has passed
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer created by a Clock, behaving like time.Timer
+type Timer interface {
+	// C returns the channel that the time is sent on when the timer fires
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, returning false if it has already fired or been stopped
+	Stop() bool
+}
+
+// SystemClock is the Clock that uses the system time, and is used by a server that does not have a Clock
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// clock returns the Clock of the server, or SystemClock if it does not have one
+func (s *Server) clock() Clock {
+	return clockOrSystem(s.Clock)
+}
+
+// clockOrSystem returns clock, or SystemClock if clock is nil
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// sleep pauses the current goroutine for at least d, as measured by the Clock of the server
+func (s *Server) sleep(d time.Duration) {
+	timer := s.clock().NewTimer(d)
+	<-timer.C()
+}
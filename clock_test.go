@@ -0,0 +1,185 @@
+package zbx_test
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// fakeClock is a zbx.Clock that only moves forward when advanced
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	c     chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) zbx.Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	timer := &fakeTimer{clock: c, when: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.c <- c.now
+		return timer
+	}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d, firing any timers that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.when.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = pending
+}
+
+// Waiting returns the number of timers that have not fired
+func (c *fakeClock) Waiting() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.timers)
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestClockBanExpires(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		InvalidRequestLimit: 1,
+		InvalidRequestBan:   time.Hour,
+		Clock:               clock,
+	})
+
+	ping := func() []byte {
+		c, err := retryDial(addr)
+		if err != nil {
+			t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+		}
+		defer c.Close()
+		c.Write(requestForKey("agent.ping"))
+		reply, _ := io.ReadAll(c)
+		return reply
+	}
+
+	sendGarbage(t, addr)
+	if reply := ping(); len(reply) > 0 {
+		t.Errorf("Unexpected reply from server during ban: %x", reply)
+	}
+
+	clock.Advance(time.Hour)
+	expectedResponse := []byte("\x5A\x42\x58\x44\x01\x01\x00\x00\x00\x00\x00\x00\x00\x31")
+	if reply := ping(); !bytes.Equal(reply, expectedResponse) {
+		t.Errorf("Unexpected reply from server after ban. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+	}
+}
+
+func TestClockTarpit(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		InvalidRequestLimit: 1,
+		TarpitDelay:         time.Hour,
+		Clock:               clock,
+	})
+
+	closed := make(chan struct{})
+	go func() {
+		sendGarbage(t, addr)
+		close(closed)
+	}()
+
+	for clock.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-closed:
+		t.Fatalf("Connection closed before tarpit delay")
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Connection not closed after tarpit delay")
+	}
+}
+
+func TestClockUptime(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return nil, nil
+		},
+		StatsItems: true,
+		Clock:      clock,
+	}
+	addr := startTestServer(t, server)
+	if uptime := queryKey(t, addr, "zbx.stats[uptime]"); uptime != "0" {
+		t.Errorf("Unexpected uptime. Expected '0' got '%s'", uptime)
+	}
+
+	clock.Advance(90 * time.Second)
+	if uptime := queryKey(t, addr, "zbx.stats[uptime]"); uptime != "90" {
+		t.Errorf("Unexpected uptime. Expected '90' got '%s'", uptime)
+	}
+	if uptime := server.Health().Uptime; uptime != 90 {
+		t.Errorf("Unexpected health uptime. Expected 90 got %d", uptime)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+type activeChecksRequest struct {
+	Request      string `json:"request"`
+	Host         string `json:"host"`
+	HostMetadata string `json:"host_metadata,omitempty"`
+}
+
+type activeCheck struct {
+	Key         string `json:"key"`
+	ItemID      uint64 `json:"itemid,omitempty"`
+	Delay       string `json:"delay"`
+	LastLogSize uint64 `json:"lastlogsize"`
+	MTime       int64  `json:"mtime"`
+	Timeout     string `json:"timeout,omitempty"`
+}
+
+type activeChecksResponse struct {
+	Response string        `json:"response"`
+	Info     string        `json:"info"`
+	Data     []activeCheck `json:"data"`
+}
+
+// runActive requests the list of active checks for a host from a Zabbix server or proxy, prints it to stdout and
+// returns the exit code
+func runActive(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("active", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Printf(`Usage: %s active [--json] [--metadata <Metadata>] [--source <IP>] [--proxy <URL>] [--tls-connect cert ...]
+       <Server> <Hostname>
+
+Where <Server> is the address and port of the zabbix server or proxy and <Hostname> is the name
+of the host to request active checks for, as configured on the server.
+
+Options:
+  --json                     Print the item list as JSON instead of a table
+  --metadata                 Host metadata to send with the request
+  --timeout                  Maximum time to wait for the server, default 30s
+  --source                   Local IP address to connect from, for hosts with more than one interface
+  --proxy                    URL of a SOCKS5 (socks5://host:port) or HTTP CONNECT (http://host:port) proxy to
+                             connect through. Defaults to the ALL_PROXY environment variable, honoring NO_PROXY
+  --tls-connect              How to connect to the server: unencrypted (default) or cert. psk is not
+                             supported
+  --tls-ca-file              File with the CA certificates to verify the server certificate
+  --tls-cert-file            File with the client certificate
+  --tls-key-file             File with the client certificate key
+  --tls-server-cert-issuer   Required issuer of the server certificate
+  --tls-server-cert-subject  Required subject of the server certificate
+
+%s`, os.Args[0], exitCodeHelp)
+	}
+	jsonOutput := flags.Bool("json", false, "")
+	metadata := flags.String("metadata", "", "")
+	timeout := flags.Duration("timeout", 30*time.Second, "")
+	source := flags.String("source", "", "")
+	proxyURL := flags.String("proxy", "", "")
+	tlsOptions := &tlsFlags{}
+	tlsOptions.register(flags)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	server := flags.Arg(0)
+	hostname := flags.Arg(1)
+
+	options := connectOptions{timeout: *timeout, source: *source, proxy: *proxyURL}
+	tlsConfig, err := tlsOptions.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return exitUsage
+	}
+	options.tls = tlsConfig
+	if err := options.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return exitUsage
+	}
+
+	conn, err := options.dial(server)
+	if err != nil {
+		return reportError("Error dialing zabbix server", err)
+	}
+	defer conn.Close()
+
+	request, err := json.Marshal(activeChecksRequest{
+		Request:      "active checks",
+		Host:         hostname,
+		HostMetadata: *metadata,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding request: %s\n", err.Error())
+		return exitUsage
+	}
+	if err := zbxproto.WriteMessage(conn, request, nil); err != nil {
+		return reportError("Error sending request", err)
+	}
+	reply, err := zbxproto.ReadMessage(conn, nil)
+	if err != nil {
+		return reportError("Error reading reply", err)
+	}
+
+	response := activeChecksResponse{}
+	if err := json.Unmarshal(reply.Data, &response); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid reply: %s\n", err.Error())
+		return exitProtocol
+	}
+	if response.Response != "success" {
+		fmt.Fprintf(os.Stderr, "Unsuccessful response: %s\n", response.Info)
+		return exitUnsupported
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "    ")
+		encoder.Encode(response.Data)
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ITEMID\tKEY\tDELAY\tTIMEOUT\tLASTLOGSIZE\tMTIME\n")
+	for _, check := range response.Data {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\n", check.ItemID, check.Key, check.Delay, check.Timeout, check.LastLogSize, check.MTime)
+	}
+	w.Flush()
+	return exitOK
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// startTestActiveServer starts a Zabbix server that replies to one active checks request with response, and sends
+// the request it received on requests once the client has closed the connection
+func startTestActiveServer(t *testing.T, l net.Listener, response string) (string, <-chan activeChecksRequest) {
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	requests := make(chan activeChecksRequest, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		message, err := zbxproto.ReadMessage(conn, nil)
+		if err != nil {
+			return
+		}
+		request := activeChecksRequest{}
+		json.Unmarshal(message.Data, &request)
+		zbxproto.WriteMessage(conn, []byte(response), nil)
+
+		// The client must close the connection once it has the reply
+		if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+			return
+		}
+		requests <- request
+	}()
+	return l.Addr().String(), requests
+}
+
+func TestRunActive(t *testing.T) {
+	t.Parallel()
+
+	caFile, certFile, keyFile, _ := testCertificates(t)
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Error loading certificate: %s", err.Error())
+	}
+	tlsArgs := []string{"--tls-connect", "cert", "--tls-ca-file", caFile, "--tls-cert-file", certFile, "--tls-key-file", keyFile}
+	checks := `{"response":"success","data":[{"key":"agent.ping","itemid":1,"delay":"30s","lastlogsize":0,"mtime":0}]}`
+
+	tests := []struct {
+		name     string
+		args     []string
+		tls      bool
+		response string
+		exitCode int
+		output   string
+	}{
+		{
+			name:     "table",
+			response: checks,
+			output:   "ITEMID  KEY         DELAY  TIMEOUT  LASTLOGSIZE  MTIME\n1       agent.ping  30s             0            0\n",
+		},
+		{
+			name:     "json",
+			args:     []string{"--json", "--metadata", "Linux"},
+			response: checks,
+			output:   `"key": "agent.ping"`,
+		},
+		{
+			name:     "tls",
+			args:     tlsArgs,
+			tls:      true,
+			response: checks,
+			output:   "agent.ping",
+		},
+		{
+			name:     "rejected",
+			response: `{"response":"failed","info":"host [web01] not found"}`,
+			exitCode: exitUnsupported,
+		},
+		{
+			name:     "invalid reply",
+			response: "not json",
+			exitCode: exitProtocol,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var l net.Listener
+			var err error
+			if test.tls {
+				l, err = tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+			} else {
+				l, err = net.Listen("tcp", "127.0.0.1:0")
+			}
+			if err != nil {
+				t.Fatalf("Error opening listener: %s", err.Error())
+			}
+			address, requests := startTestActiveServer(t, l, test.response)
+
+			output := &bytes.Buffer{}
+			args := append(append([]string{"--timeout", "5s"}, test.args...), address, "web01")
+			if exitCode := runActive(args, output); exitCode != test.exitCode {
+				t.Fatalf("Unexpected exit code. Expected %d got %d", test.exitCode, exitCode)
+			}
+			if !strings.Contains(output.String(), test.output) {
+				t.Errorf("Unexpected output. Expected %q got %q", test.output, output.String())
+			}
+
+			select {
+			case request := <-requests:
+				if request.Request != "active checks" || request.Host != "web01" {
+					t.Errorf("Unexpected request: %+v", request)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("Connection not closed after the reply")
+			}
+		})
+	}
+}
+
+func TestRunActiveInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := [][]string{
+		{"127.0.0.1:10051"},
+		{"--unknown", "127.0.0.1:10051", "web01"},
+		{"--tls-connect", "psk", "127.0.0.1:10051", "web01"},
+		{"--tls-cert-file", "cert.pem", "127.0.0.1:10051", "web01"},
+		{"--source", "localhost", "127.0.0.1:10051", "web01"},
+	}
+
+	for _, args := range tests {
+		if exitCode := runActive(args, io.Discard); exitCode != exitUsage {
+			t.Errorf("Unexpected exit code for %q. Expected %d got %d", args, exitUsage, exitCode)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// runDecode decodes the Zabbix protocol frames in a file or stdin, such as those extracted from a packet capture, and
+// prints them
+func runDecode(args []string) {
+	flags := flag.NewFlagSet("decode", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Printf(`Usage: %s decode [--hex] [<File>]
+
+Decodes the zabbix protocol frames in <File>, or stdin if no file is given, and prints the flags,
+lengths and payload of each frame. Frames must follow each other without any other bytes between
+them, as in the payload of a TCP stream extracted from a packet capture. Compressed payloads are
+decompressed and JSON payloads are indented.
+
+Options:
+  --hex        The input is a hex string, such as one copied from Wireshark, rather than raw bytes.
+               Whitespace is ignored
+
+%s`, os.Args[0], exitCodeHelp)
+	}
+	hexInput := flags.Bool("hex", false, "")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	var input io.Reader = os.Stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening input: %s\n", err.Error())
+			os.Exit(exitUsage)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	if *hexInput {
+		text, err := io.ReadAll(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %s\n", err.Error())
+			os.Exit(exitUsage)
+		}
+		raw, err := hex.DecodeString(strings.Join(strings.Fields(string(text)), ""))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid hex input: %s\n", err.Error())
+			os.Exit(exitUsage)
+		}
+		input = bytes.NewReader(raw)
+	}
+
+	if err := decodeFrames(input, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(exitProtocol)
+	}
+}
+
+// decodeFrames prints each frame read from r to w until the end of r, or returns an error for the first frame that
+// cannot be decoded
+func decodeFrames(r io.Reader, w io.Writer) error {
+	counter := &countingReader{r: r}
+	for frame := 1; ; frame++ {
+		offset := counter.n
+		message, err := zbxproto.ReadMessage(counter, nil)
+		if errors.Is(err, io.EOF) && counter.n == offset {
+			if frame == 1 {
+				return fmt.Errorf("no frames in input")
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid frame %d at offset %d: %w", frame, offset, err)
+		}
+
+		fmt.Fprintf(w, "Frame %d (offset %d, %d bytes):\n", frame, offset, counter.n-offset)
+		fmt.Fprintf(w, "  Flags:    0x%02x (%s)\n", message.Flags, flagNames(message.Flags))
+		fmt.Fprintf(w, "  Length:   %d\n", message.Length)
+		fmt.Fprintf(w, "  Reserved: %d\n", message.Reserved)
+		fmt.Fprintf(w, "  Payload:\n%s\n", formatPayload(message.Data))
+	}
+}
+
+// flagNames returns the names of the flags that are set
+func flagNames(flags byte) string {
+	names := []string{}
+	if flags&zbxproto.FlagProtocol != 0 {
+		names = append(names, "protocol")
+	}
+	if flags&zbxproto.FlagCompressed != 0 {
+		names = append(names, "compressed")
+	}
+	if flags&zbxproto.FlagLargePacket != 0 {
+		names = append(names, "large packet")
+	}
+	return strings.Join(names, ", ")
+}
+
+// formatPayload returns the payload indented if it is JSON, otherwise quoted so that the NUL separating a
+// ZBX_NOTSUPPORTED message and any other control characters are visible
+func formatPayload(data []byte) string {
+	indented := &bytes.Buffer{}
+	if json.Valid(data) && json.Indent(indented, data, "    ", "    ") == nil {
+		return "    " + indented.String()
+	}
+	return fmt.Sprintf("    %q", data)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+func TestDecodeFrames(t *testing.T) {
+	t.Parallel()
+
+	encode := func(data string, options *zbxproto.Options) string {
+		message, err := zbxproto.Encode([]byte(data), options)
+		if err != nil {
+			t.Fatalf("Error encoding message: %s", err.Error())
+		}
+		return string(message)
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		err    string
+	}{
+		{
+			name:  "reply",
+			input: encode("1", nil),
+			output: "Frame 1 (offset 0, 14 bytes):\n" +
+				"  Flags:    0x01 (protocol)\n" +
+				"  Length:   1\n" +
+				"  Reserved: 0\n" +
+				"  Payload:\n    1\n",
+		},
+		{
+			name:  "request and unsupported reply",
+			input: encode("agent.ping", nil) + encode("ZBX_NOTSUPPORTED\x00Item key unknown", &zbxproto.Options{LargePacket: true}),
+			output: "Frame 1 (offset 0, 23 bytes):\n" +
+				"  Flags:    0x01 (protocol)\n" +
+				"  Length:   10\n" +
+				"  Reserved: 0\n" +
+				"  Payload:\n    \"agent.ping\"\n" +
+				"Frame 2 (offset 23, 54 bytes):\n" +
+				"  Flags:    0x05 (protocol, large packet)\n" +
+				"  Length:   33\n" +
+				"  Reserved: 0\n" +
+				"  Payload:\n    \"ZBX_NOTSUPPORTED\\x00Item key unknown\"\n",
+		},
+		{
+			name:  "compressed json",
+			input: encode(`{"request":"active checks"}`, &zbxproto.Options{Compress: true}),
+			output: "  Flags:    0x03 (protocol, compressed)\n" +
+				"  Length:   40\n" +
+				"  Reserved: 27\n" +
+				"  Payload:\n    {\n        \"request\": \"active checks\"\n    }\n",
+		},
+		{
+			name:  "empty input",
+			input: "",
+			err:   "no frames in input",
+		},
+		{
+			name:  "truncated frame",
+			input: encode("agent.ping", nil)[:15],
+			err:   "invalid frame 1 at offset 0: unexpected EOF",
+		},
+		{
+			name:  "bytes after frame",
+			input: encode("1", nil) + "HTTP/1.1 400 Bad Request",
+			err:   "invalid frame 2 at offset 14: bad header",
+		},
+	}
+
+	for _, test := range tests {
+		output := &bytes.Buffer{}
+		err := decodeFrames(strings.NewReader(test.input), output)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Unexpected error for %s. Expected '%s' got '%v'", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.name, err.Error())
+			continue
+		}
+		if !strings.Contains(output.String(), test.output) {
+			t.Errorf("Unexpected output for %s. Expected to contain:\n%s\ngot:\n%s", test.name, test.output, output.String())
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contextDialer makes connections, directly or through a proxy
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// connectOptions describes how connections are made to agents and servers
+type connectOptions struct {
+	// timeout is the maximum time for the connection, including the request and reply
+	timeout time.Duration
+	// source is the optional local IP address to connect from
+	source string
+	// proxy is the optional URL of a SOCKS5 or HTTP CONNECT proxy to connect through. When empty the ALL_PROXY and
+	// NO_PROXY environment variables are used.
+	proxy string
+	// tls is the optional TLS configuration, when set connections are encrypted
+	tls *tls.Config
+}
+
+// validate returns an error if the options are invalid
+func (o connectOptions) validate() error {
+	if o.source != "" && net.ParseIP(o.source) == nil {
+		return fmt.Errorf("invalid source address '%s'", o.source)
+	}
+	if o.proxy != "" {
+		if _, err := newProxyDialer(o.proxy, &net.Dialer{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxyDialer returns the dialer for connecting to address with the proxy option, connecting to the proxy with
+// forward. Without a proxy option the ALL_PROXY environment variable is used, unless address matches NO_PROXY.
+func (o connectOptions) proxyDialer(forward contextDialer, address string) (contextDialer, error) {
+	proxyURL := o.proxy
+	if proxyURL == "" {
+		proxyURL = getenv("ALL_PROXY", "all_proxy")
+		if proxyURL == "" || bypassProxy(getenv("NO_PROXY", "no_proxy"), address) {
+			return forward, nil
+		}
+	}
+	return newProxyDialer(proxyURL, forward)
+}
+
+// getenv returns the value of the first of the environment variables that is set
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// bypassProxy returns true if address matches an entry of noProxy, a comma separated list of IP addresses, CIDR
+// ranges and domain names, which also match their subdomains. An entry of "*" matches every address.
+func bypassProxy(noProxy string, address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryHost, _, err := net.SplitHostPort(entry); err == nil {
+			entry = entryHost
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(entry, "*"), "."), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newProxyDialer returns the dialer for the proxy at proxyURL, connecting to the proxy with forward
+func newProxyDialer(proxyURL string, forward contextDialer) (contextDialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url '%s': %s", proxyURL, err.Error())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url '%s': missing proxy host", proxyURL)
+	}
+	username := u.User.Username()
+	password, _ := u.User.Password()
+
+	switch u.Scheme {
+	case "http":
+		dialer := &httpConnectDialer{proxyAddress: proxyAddress(u, "80"), forward: forward}
+		if u.User != nil {
+			dialer.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		}
+		return dialer, nil
+	case "socks5", "socks5h":
+		if len(username) > 255 || len(password) > 255 {
+			return nil, fmt.Errorf("invalid proxy url '%s': username or password too long", proxyURL)
+		}
+		return &socks5Dialer{
+			proxyAddress: proxyAddress(u, "1080"),
+			username:     username,
+			password:     password,
+			forward:      forward,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid proxy url '%s': unknown scheme '%s'", proxyURL, u.Scheme)
+}
+
+// proxyAddress returns the address of the proxy at u, with defaultPort if u has no port
+func proxyAddress(u *url.URL, defaultPort string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return u.Host
+}
+
+// dial connects to address, setting a deadline on the connection for the timeout
+func (o connectOptions) dial(address string) (net.Conn, error) {
+	forward := &net.Dialer{Timeout: o.timeout}
+	if o.source != "" {
+		forward.LocalAddr = &net.TCPAddr{IP: net.ParseIP(o.source)}
+	}
+	dialer, err := o.proxyDialer(forward, address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(o.timeout))
+
+	if o.tls != nil {
+		tlsConn := tls.Client(conn, o.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		return tlsConn, nil
+	}
+	return conn, nil
+}
+
+// dialProxy connects to the proxy at proxyAddress with forward, setting the deadline of ctx on the connection until
+// the returned function is called
+func dialProxy(ctx context.Context, forward contextDialer, network, proxyAddress string) (net.Conn, func(), error) {
+	conn, err := forward.DialContext(ctx, network, proxyAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		return conn, func() { conn.SetDeadline(time.Time{}) }, nil
+	}
+	return conn, func() {}, nil
+}
+
+// httpConnectDialer connects through an HTTP proxy using the CONNECT method
+type httpConnectDialer struct {
+	proxyAddress  string
+	authorization string
+	forward       contextDialer
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, done, err := dialProxy(ctx, d.forward, network, d.proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if d.authorization != "" {
+		request.Header.Set("Proxy-Authorization", d.authorization)
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection: %s", response.Status)
+	}
+	if reader.Buffered() > 0 {
+		// Anything read past the end of the response belongs to the tunnel
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose reads are served from reader first
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+const (
+	socks5Version      = 5
+	socks5NoAuth       = 0
+	socks5PasswordAuth = 2
+	socks5NoMethod     = 0xff
+	socks5Connect      = 1
+	socks5IPv4         = 1
+	socks5Domain       = 3
+	socks5IPv6         = 4
+)
+
+// socks5Errors are the descriptions of the reply codes of a SOCKS5 proxy
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socks5Dialer connects through a SOCKS5 proxy, as described in RFC 1928, authenticating with a username and password
+// as described in RFC 1929 if the proxy asks for them. Host names are resolved by the proxy.
+type socks5Dialer struct {
+	proxyAddress string
+	username     string
+	password     string
+	forward      contextDialer
+}
+
+func (d *socks5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port '%s'", portString)
+	}
+	if len(host) > 255 {
+		return nil, fmt.Errorf("host name too long")
+	}
+
+	conn, done, err := dialProxy(ctx, d.forward, network, d.proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if err := d.connect(conn, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// connect authenticates with the proxy on conn and asks it to connect to host and port
+func (d *socks5Dialer) connect(conn net.Conn, host string, port uint16) error {
+	methods := []byte{socks5NoAuth}
+	if d.username != "" {
+		methods = append(methods, socks5PasswordAuth)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected socks version %d from proxy", reply[0])
+	}
+	switch {
+	case reply[1] == socks5NoAuth:
+	case reply[1] == socks5PasswordAuth && d.username != "":
+		request := append([]byte{1, byte(len(d.username))}, d.username...)
+		request = append(append(request, byte(len(d.password))), d.password...)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("proxy refused username and password")
+		}
+	case reply[1] == socks5NoMethod:
+		return fmt.Errorf("proxy requires authentication")
+	default:
+		return fmt.Errorf("unsupported authentication method %d from proxy", reply[1])
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		request = append(append(request, socks5Domain, byte(len(host))), host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(append(request, socks5IPv4), ip4...)
+	} else {
+		request = append(append(request, socks5IPv6), ip...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("unexpected socks version %d from proxy", header[0])
+	}
+	if header[1] != 0 {
+		description, ok := socks5Errors[header[1]]
+		if !ok {
+			description = fmt.Sprintf("error %d", header[1])
+		}
+		return fmt.Errorf("proxy refused connection: %s", description)
+	}
+
+	// The address the proxy connected from is not needed, but must be read before the tunnel starts
+	var length int
+	switch header[3] {
+	case socks5IPv4:
+		length = net.IPv4len
+	case socks5IPv6:
+		length = net.IPv6len
+	case socks5Domain:
+		if _, err := io.ReadFull(conn, header[:1]); err != nil {
+			return err
+		}
+		length = int(header[0])
+	default:
+		return fmt.Errorf("unexpected address type %d from proxy", header[3])
+	}
+	_, err := io.ReadFull(conn, make([]byte, length+2))
+	return err
+}
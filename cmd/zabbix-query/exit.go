@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// Exit codes returned by zabbix-query
+const (
+	exitOK          = 0
+	exitUsage       = 1
+	exitUnsupported = 2
+	exitNetwork     = 3
+	exitProtocol    = 4
+	exitTimeout     = 5
+)
+
+const exitCodeHelp = `Exit codes:
+  0  Success
+  1  Invalid usage
+  2  The item is not supported by the agent, or the server rejected the request
+  3  Network error
+  4  Protocol error, such as a malformed reply or the connection closing without a reply
+  5  Timed out
+`
+
+// exitWithError prints the message and error to stderr and exits with the exit code matching the type of error
+func exitWithError(message string, err error) {
+	os.Exit(reportError(message, err))
+}
+
+// reportError prints the message and error to stderr and returns the exit code matching the type of error
+func reportError(message string, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", message, err.Error())
+	return exitCodeForError(err)
+}
+
+func exitCodeForError(err error) int {
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return exitTimeout
+	}
+
+	if errors.Is(err, zbxproto.ErrBadHeader) ||
+		errors.Is(err, zbxproto.ErrUnsupportedFlags) ||
+		errors.Is(err, zbxproto.ErrTooLarge) ||
+		errors.Is(err, zbxproto.ErrLengthMismatch) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return exitProtocol
+	}
+
+	return exitNetwork
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+func TestExitCodeForError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"deadline exceeded", fmt.Errorf("read reply: %w", os.ErrDeadlineExceeded), exitTimeout},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}, exitTimeout},
+		{"bad header", fmt.Errorf("read reply: %w", zbxproto.ErrBadHeader), exitProtocol},
+		{"unsupported flags", &zbxproto.FlagsError{Flags: 0x09}, exitProtocol},
+		{"too large", zbxproto.ErrTooLarge, exitProtocol},
+		{"length mismatch", zbxproto.ErrLengthMismatch, exitProtocol},
+		{"closed without reply", io.EOF, exitProtocol},
+		{"truncated reply", io.ErrUnexpectedEOF, exitProtocol},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, exitNetwork},
+		{"other", fmt.Errorf("tls handshake: remote error"), exitNetwork},
+	}
+
+	for _, test := range tests {
+		if code := exitCodeForError(test.err); code != test.code {
+			t.Errorf("Unexpected exit code for %s. Expected %d got %d", test.name, test.code, code)
+		}
+	}
+}
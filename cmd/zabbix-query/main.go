@@ -1,98 +1,203 @@
 // Command zabbix-query provides a simply utility to return a item value from a running zabbix
-// agent.
+// agent, or the list of active checks for a host from a zabbix server. It can also decode captured
+// zabbix protocol frames.
 package main
 
 import (
 	"bytes"
-	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
 )
 
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Printf(`Usage: %s <Host> <Key>
+	if len(os.Args) > 1 && os.Args[1] == "active" {
+		os.Exit(runActive(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "decode" {
+		runDecode(os.Args[2:])
+		return
+	}
+
+	flag.Usage = func() {
+		fmt.Printf(`Usage: %s [--hex] [--source <IP>] [--proxy <URL>] <Host> <Key>
+       %s -s <Host> [-p <Port>] -k <Key> [-I <IP>] [-t <Seconds>] [--tls-connect cert ...]
+       %s --validate <File> <Host>
+       %s active [--json] [--metadata <Metadata>] <Server> <Hostname>
+       %s decode [--hex] [<File>]
 
 Where <Host> is the address and port of the zabbix agent and <Key> is the name of the item key
 to request from the agent.
-`, os.Args[0])
-		os.Exit(1)
+
+The -s, -p, -k, -I, -t and --tls-* options are the same as those of zabbix_get, so that existing
+scripts can use this tool unchanged.
+
+With --validate, every key listed in <File> (one per line, blank lines and lines starting with #
+are ignored) is requested from the agent and a report of the unsupported, failing and slow keys is
+printed. The exit code is 2 if any key was not supported.
+
+The active subcommand requests the list of active checks for <Hostname> from the zabbix server or
+proxy at <Server> and prints it.
+
+The decode subcommand prints the zabbix protocol frames read from <File> or stdin, such as those
+extracted from a packet capture.
+
+Options:
+  --hex        Print the raw request and response frames, with their decoded headers and a hexdump
+  --timeout    Maximum time to wait for the agent, default 30s
+  --source     Local IP address to connect from, for hosts with more than one interface
+  --proxy      URL of a SOCKS5 (socks5://host:port) or HTTP CONNECT (http://host:port) proxy to connect
+               through. Defaults to the ALL_PROXY environment variable, honoring NO_PROXY
+  --validate   Request every key listed in the given file and report which are supported
+  --slow       With --validate, how long a key can take before it is reported as slow, default 3s
+
+zabbix_get options:
+  -s           Host name or IP address of the zabbix agent
+  -p           Port of the zabbix agent, default 10050
+  -k           Item key to request
+  -I           Local IP address to connect from, same as --source
+  -t           Timeout in seconds, same as --timeout
+  --tls-connect              How to connect to the agent: unencrypted (default) or cert. psk is not
+                             supported
+  --tls-ca-file              File with the CA certificates to verify the agent certificate
+  --tls-cert-file            File with the client certificate
+  --tls-key-file             File with the client certificate key
+  --tls-server-cert-issuer   Required issuer of the agent certificate
+  --tls-server-cert-subject  Required subject of the agent certificate
+
+Unsupported items are reported on stderr as "ZBX_NOTSUPPORTED: <message>".
+
+%s`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], exitCodeHelp)
+	}
+	hexMode := flag.Bool("hex", false, "")
+	flag.BoolVar(hexMode, "debug", false, "")
+	timeout := flag.Duration("timeout", 30*time.Second, "")
+	validateFile := flag.String("validate", "", "")
+	slow := flag.Duration("slow", 3*time.Second, "")
+	source := flag.String("source", "", "")
+	proxyURL := flag.String("proxy", "", "")
+	agentHost := flag.String("s", "", "")
+	agentPort := flag.String("p", "10050", "")
+	agentKey := flag.String("k", "", "")
+	flag.StringVar(source, "I", "", "")
+	timeoutSeconds := flag.Int("t", 0, "")
+	tlsOptions := &tlsFlags{}
+	tlsOptions.register(flag.CommandLine)
+	flag.Parse()
+
+	if *timeoutSeconds > 0 {
+		*timeout = time.Duration(*timeoutSeconds) * time.Second
+	}
+	options := connectOptions{timeout: *timeout, source: *source, proxy: *proxyURL}
+	tlsConfig, err := tlsOptions.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(exitUsage)
+	}
+	options.tls = tlsConfig
+	if err := options.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(exitUsage)
 	}
 
-	host := os.Args[1]
-	key := os.Args[2]
+	// Arguments are either positional, or given with the zabbix_get options
+	args := flag.Args()
+	if *agentHost != "" {
+		args = append([]string{net.JoinHostPort(*agentHost, *agentPort)}, args...)
+	}
+	if *agentKey != "" {
+		args = append(args, *agentKey)
+	}
 
-	conn, err := net.Dial("tcp", host)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error dialing zabbix agent: %s", err.Error())
-		os.Exit(1)
+	if *validateFile != "" {
+		if len(args) != 1 {
+			flag.Usage()
+			os.Exit(exitUsage)
+		}
+		os.Exit(runValidate(args[0], *validateFile, options, *slow))
 	}
-	defer conn.Close()
 
-	if err := sendRequest(conn, key); err != nil {
-		fmt.Fprintf(os.Stderr, "Error sending request: %s", err.Error())
-		os.Exit(1)
+	if len(args) != 2 {
+		flag.Usage()
+		os.Exit(exitUsage)
 	}
-	reply, err := readReply(conn)
+
+	value, err := queryAgent(args[0], args[1], options, *hexMode)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading reply: %s", err.Error())
-		os.Exit(1)
+		var unsupported *unsupportedError
+		if errors.As(err, &unsupported) {
+			fmt.Fprintf(os.Stderr, "ZBX_NOTSUPPORTED: %s\n", unsupported.message)
+			os.Exit(exitUnsupported)
+		}
+		exitWithError("Error", err)
 	}
 
-	fmt.Printf("%s\n", reply)
+	fmt.Printf("%s\n", value)
 }
 
-func sendRequest(conn net.Conn, key string) error {
-	header := []byte("ZBXD\x01")
-
-	keyLength := len(key)
-	keyLenBuf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(keyLenBuf, uint32(keyLength))
+// unsupportedError is returned by queryAgent when the agent replied with ZBX_NOTSUPPORTED
+type unsupportedError struct {
+	message string
+}
 
-	reserved := make([]byte, 4)
+func (e *unsupportedError) Error() string {
+	return "ZBX_NOTSUPPORTED: " + e.message
+}
 
-	if _, err := conn.Write(header); err != nil {
-		return err
+// queryAgent requests key from the agent at host and returns the value
+func queryAgent(host string, key string, options connectOptions, hexMode bool) ([]byte, error) {
+	conn, err := options.dial(host)
+	if err != nil {
+		return nil, fmt.Errorf("dialing zabbix agent: %w", err)
 	}
-	if _, err := conn.Write(keyLenBuf); err != nil {
-		return err
+	defer conn.Close()
+
+	request, err := zbxproto.Encode([]byte(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
 	}
-	if _, err := conn.Write(reserved); err != nil {
-		return err
+	if hexMode {
+		printFrame("Request", request)
 	}
-	if _, err := conn.Write([]byte(key)); err != nil {
-		return err
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
 	}
-	return nil
-}
-
-func readReply(conn net.Conn) ([]byte, error) {
-	header := make([]byte, 5)
 
-	if _, err := conn.Read(header); err != nil {
-		return nil, err
+	// Keep a copy of everything read so that the raw response can be printed
+	raw := &bytes.Buffer{}
+	reply, err := zbxproto.ReadMessage(io.TeeReader(conn, raw), nil)
+	if hexMode && raw.Len() > 0 {
+		printFrame("Response", raw.Bytes())
 	}
-
-	if !bytes.Equal([]byte("ZBXD\x01"), header) {
-		return nil, fmt.Errorf("bad reply header")
+	if err != nil {
+		return nil, fmt.Errorf("reading reply: %w", err)
 	}
 
-	dataLenBuf := make([]byte, 8)
-	if _, err := conn.Read(dataLenBuf); err != nil && err != io.EOF {
-		return nil, err
+	if strings.HasPrefix(string(reply.Data), "ZBX_NOTSUPPORTED\x00") {
+		return nil, &unsupportedError{message: strings.TrimPrefix(string(reply.Data), "ZBX_NOTSUPPORTED\x00")}
 	}
-	dataLength := binary.LittleEndian.Uint64(dataLenBuf)
 
-	if dataLength >= 134217728 {
-		return nil, fmt.Errorf("reply too large")
-	}
+	return reply.Data, nil
+}
 
-	data := make([]byte, dataLength)
-	if _, err := conn.Read(data); err != nil && err != io.EOF {
-		return nil, err
+// printFrame prints the decoded header and a hexdump of the raw frame to stderr
+func printFrame(title string, raw []byte) {
+	fmt.Fprintf(os.Stderr, "%s (%d bytes):\n", title, len(raw))
+	if message, err := zbxproto.ReadMessage(bytes.NewReader(raw), nil); err == nil {
+		fmt.Fprintf(os.Stderr, "  Flags:    0x%02x\n", message.Flags)
+		fmt.Fprintf(os.Stderr, "  Length:   %d\n", message.Length)
+		fmt.Fprintf(os.Stderr, "  Reserved: %d\n", message.Reserved)
+		fmt.Fprintf(os.Stderr, "  Payload:  %q\n", message.Data)
+	} else {
+		fmt.Fprintf(os.Stderr, "  Invalid frame: %s\n", err.Error())
 	}
-
-	return data, nil
+	fmt.Fprintf(os.Stderr, "%s\n", hex.Dump(raw))
 }
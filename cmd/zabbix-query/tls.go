@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// tlsFlags are the TLS options of zabbix_get
+type tlsFlags struct {
+	connect           string
+	caFile            string
+	certFile          string
+	keyFile           string
+	serverCertIssuer  string
+	serverCertSubject string
+	pskIdentity       string
+	pskFile           string
+}
+
+func (f *tlsFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.connect, "tls-connect", "unencrypted", "")
+	flags.StringVar(&f.caFile, "tls-ca-file", "", "")
+	flags.StringVar(&f.certFile, "tls-cert-file", "", "")
+	flags.StringVar(&f.keyFile, "tls-key-file", "", "")
+	flags.StringVar(&f.serverCertIssuer, "tls-server-cert-issuer", "", "")
+	flags.StringVar(&f.serverCertSubject, "tls-server-cert-subject", "", "")
+	flags.StringVar(&f.pskIdentity, "tls-psk-identity", "", "")
+	flags.StringVar(&f.pskFile, "tls-psk-file", "", "")
+}
+
+// config returns the TLS configuration for the flags, or nil for unencrypted connections
+func (f *tlsFlags) config() (*tls.Config, error) {
+	switch f.connect {
+	case "", "unencrypted":
+		if f.caFile != "" || f.certFile != "" || f.keyFile != "" || f.pskIdentity != "" || f.pskFile != "" {
+			return nil, fmt.Errorf("TLS options require --tls-connect")
+		}
+		return nil, nil
+	case "psk":
+		return nil, fmt.Errorf("--tls-connect psk is not supported, as Go does not implement TLS-PSK")
+	case "cert":
+	default:
+		return nil, fmt.Errorf("invalid --tls-connect '%s', must be unencrypted, psk or cert", f.connect)
+	}
+
+	if f.caFile == "" || f.certFile == "" || f.keyFile == "" {
+		return nil, fmt.Errorf("--tls-connect cert requires --tls-ca-file, --tls-cert-file and --tls-key-file")
+	}
+	caData, err := os.ReadFile(f.caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA file: %s", err.Error())
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in CA file '%s'", f.caFile)
+	}
+	certificate, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %s", err.Error())
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		// Like zabbix_get and zabbix_sender, the agent or server certificate is verified against the CA but not the
+		// host name. Verification is done in VerifyPeerCertificate.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return f.verify(rawCerts, roots)
+		},
+	}, nil
+}
+
+// verify checks that the certificate of the agent or server chains to roots and has the expected issuer and subject
+func (f *tlsFlags) verify(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("invalid certificate: %s", err.Error())
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return err
+	}
+
+	if f.serverCertIssuer != "" && certs[0].Issuer.String() != f.serverCertIssuer {
+		return fmt.Errorf("certificate issuer '%s' does not match '%s'", certs[0].Issuer.String(), f.serverCertIssuer)
+	}
+	if f.serverCertSubject != "" && certs[0].Subject.String() != f.serverCertSubject {
+		return fmt.Errorf("certificate subject '%s' does not match '%s'", certs[0].Subject.String(), f.serverCertSubject)
+	}
+	return nil
+}
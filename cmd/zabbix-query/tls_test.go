@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificates writes a CA certificate, and a certificate and key signed by it, to a temporary directory and
+// returns their paths along with the DER of the signed certificate
+func testCertificates(t *testing.T) (caFile string, certFile string, keyFile string, der []byte) {
+	dir := t.TempDir()
+	write := func(name string, blockType string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+			t.Fatalf("Error writing %s: %s", name, err.Error())
+		}
+		return path
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "zbx CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "zbx agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err = x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error encoding key: %s", err.Error())
+	}
+
+	return write("ca.pem", "CERTIFICATE", caDER), write("cert.pem", "CERTIFICATE", der), write("key.pem", "EC PRIVATE KEY", keyDER), der
+}
+
+func TestTLSFlagsConfig(t *testing.T) {
+	t.Parallel()
+
+	caFile, certFile, keyFile, _ := testCertificates(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name    string
+		flags   tlsFlags
+		config  bool
+		invalid bool
+	}{
+		{name: "default", flags: tlsFlags{}},
+		{name: "unencrypted", flags: tlsFlags{connect: "unencrypted"}},
+		{name: "unencrypted with certificate", flags: tlsFlags{connect: "unencrypted", certFile: certFile}, invalid: true},
+		{name: "unencrypted with psk", flags: tlsFlags{pskIdentity: "zbx"}, invalid: true},
+		{name: "psk", flags: tlsFlags{connect: "psk", pskIdentity: "zbx", pskFile: keyFile}, invalid: true},
+		{name: "unknown", flags: tlsFlags{connect: "required"}, invalid: true},
+		{name: "cert without files", flags: tlsFlags{connect: "cert"}, invalid: true},
+		{name: "cert without key", flags: tlsFlags{connect: "cert", caFile: caFile, certFile: certFile}, invalid: true},
+		{name: "missing CA", flags: tlsFlags{connect: "cert", caFile: missing, certFile: certFile, keyFile: keyFile}, invalid: true},
+		{name: "CA without certificates", flags: tlsFlags{connect: "cert", caFile: keyFile, certFile: certFile, keyFile: keyFile}, invalid: true},
+		{name: "mismatched key", flags: tlsFlags{connect: "cert", caFile: caFile, certFile: caFile, keyFile: keyFile}, invalid: true},
+		{name: "cert", flags: tlsFlags{connect: "cert", caFile: caFile, certFile: certFile, keyFile: keyFile}, config: true},
+	}
+
+	for _, test := range tests {
+		config, err := test.flags.config()
+		if test.invalid {
+			if err == nil {
+				t.Errorf("No error seen for %s", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.name, err.Error())
+			continue
+		}
+		if (config != nil) != test.config {
+			t.Errorf("Unexpected config for %s: %v", test.name, config)
+		} else if config != nil && (len(config.Certificates) != 1 || config.VerifyPeerCertificate == nil) {
+			t.Errorf("Incomplete config for %s", test.name)
+		}
+	}
+}
+
+func TestTLSFlagsVerify(t *testing.T) {
+	t.Parallel()
+
+	caFile, certFile, keyFile, der := testCertificates(t)
+	_, _, _, untrusted := testCertificates(t)
+
+	tests := []struct {
+		name    string
+		flags   tlsFlags
+		certs   [][]byte
+		invalid bool
+	}{
+		{name: "trusted", certs: [][]byte{der}},
+		{name: "matching issuer and subject", flags: tlsFlags{serverCertIssuer: "CN=zbx CA", serverCertSubject: "CN=zbx agent"}, certs: [][]byte{der}},
+		{name: "no certificate", certs: [][]byte{}, invalid: true},
+		{name: "invalid certificate", certs: [][]byte{[]byte("not a certificate")}, invalid: true},
+		{name: "untrusted", certs: [][]byte{untrusted}, invalid: true},
+		{name: "wrong issuer", flags: tlsFlags{serverCertIssuer: "CN=other CA"}, certs: [][]byte{der}, invalid: true},
+		{name: "wrong subject", flags: tlsFlags{serverCertSubject: "CN=other agent"}, certs: [][]byte{der}, invalid: true},
+	}
+
+	for _, test := range tests {
+		flags := test.flags
+		flags.connect, flags.caFile, flags.certFile, flags.keyFile = "cert", caFile, certFile, keyFile
+		config, err := flags.config()
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", test.name, err.Error())
+		}
+		err = config.VerifyPeerCertificate(test.certs, nil)
+		if test.invalid && err == nil {
+			t.Errorf("No error seen for %s", test.name)
+		} else if !test.invalid && err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.name, err.Error())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runValidate requests every key listed in fileName from the agent at host and prints a report, returning the exit
+// code
+func runValidate(host string, fileName string, options connectOptions, slow time.Duration) int {
+	keys, err := readKeys(fileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading keys: %s\n", err.Error())
+		return exitUsage
+	}
+
+	exitCode := exitOK
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "KEY\tSTATUS\tTIME\tDETAIL\n")
+	for _, key := range keys {
+		start := time.Now()
+		value, err := queryAgent(host, key, options, false)
+		duration := time.Since(start)
+
+		status := "supported"
+		detail := string(value)
+		var unsupported *unsupportedError
+		if errors.As(err, &unsupported) {
+			status = "unsupported"
+			detail = unsupported.message
+			exitCode = exitUnsupported
+		} else if err != nil {
+			w.Flush()
+			fmt.Fprintf(os.Stderr, "Error querying key '%s': %s\n", key, err.Error())
+			return exitCodeForError(err)
+		}
+		if duration > slow {
+			status += ",slow"
+		}
+		if len(detail) > 60 {
+			detail = detail[:57] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%q\n", key, status, duration.Round(time.Millisecond), detail)
+	}
+	w.Flush()
+
+	return exitCode
+}
+
+// readKeys returns the keys listed in fileName, one per line, ignoring blank lines and comments
+func readKeys(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
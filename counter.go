@@ -0,0 +1,81 @@
+package zbx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoValue is returned for counter items that do not have a value yet, because the counter has only been sampled
+// once or was reset since the previous sample. It is sent to the server as ZBX_NOTSUPPORTED without being logged or
+// counted as an item error. The server marks the item as unsupported until it next checks its unsupported items, so
+// counter keys should be listed in the WarmUpKeys of the server to take their first sample at start up.
+var ErrNoValue = fmt.Errorf("no value for counter until the next sample")
+
+// Counter calculates the rate of change of a monotonically increasing value, such as the number of bytes sent on
+// a network interface. The zero value is ready to use and a Counter is safe for concurrent use.
+type Counter struct {
+	// Clock is the source of the time of samples, SystemClock if nil
+	Clock Clock
+
+	lock    sync.Mutex
+	value   float64
+	time    time.Time
+	hasLast bool
+}
+
+// Rate records value as the latest sample of this counter and returns the change per second since the previous
+// sample. Returns false if there is no previous sample or if value is smaller than the previous sample, such as when
+// the counter has been reset, in which case there is no rate to report.
+func (c *Counter) Rate(value float64) (float64, bool) {
+	return c.RateAt(value, clockOrSystem(c.Clock).Now())
+}
+
+// RateAt is like Rate but uses t as the time of the sample instead of the current time.
+func (c *Counter) RateAt(value float64, t time.Time) (float64, bool) {
+	delta, elapsed, ok := c.sample(value, t)
+	if !ok || elapsed <= 0 {
+		return 0, false
+	}
+	return delta / elapsed.Seconds(), true
+}
+
+// Delta records value as the latest sample of this counter and returns the change since the previous sample.
+// Returns false if there is no previous sample or if value is smaller than the previous sample.
+func (c *Counter) Delta(value float64) (float64, bool) {
+	delta, _, ok := c.sample(value, clockOrSystem(c.Clock).Now())
+	if !ok {
+		return 0, false
+	}
+	return delta, true
+}
+
+func (c *Counter) sample(value float64, t time.Time) (float64, time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	last, lastTime, hasLast := c.value, c.time, c.hasLast
+	c.value = value
+	c.time = t
+	c.hasLast = true
+
+	if !hasLast || value < last {
+		return 0, 0, false
+	}
+	return value - last, t.Sub(lastTime), true
+}
+
+// Counters returns an ItemFunc that converts the values returned by itemFunc for the given keys from monotonically
+// increasing counters to their change per second, using a Counter with clock for each key. Other keys are passed
+// through unchanged. Returns ErrNoValue for the first request for a key and after the counter was reset, or an error
+// if the value for a counter key is not numeric.
+func Counters(itemFunc ItemFunc, clock Clock, keys ...string) ItemFunc {
+	steps := map[string][]PreprocessingStep{}
+	rate := counterStep(clock, func(counter *Counter, value float64) (float64, bool) {
+		return counter.Rate(value)
+	})
+	for _, key := range keys {
+		steps[key] = []PreprocessingStep{rate}
+	}
+	return Preprocess(itemFunc, steps)
+}
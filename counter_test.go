@@ -0,0 +1,83 @@
+package zbx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestCounterRate(t *testing.T) {
+	t.Parallel()
+
+	counter := &zbx.Counter{}
+	start := time.Now()
+
+	if rate, ok := counter.RateAt(100, start); ok {
+		t.Errorf("Unexpected rate for first sample: %v", rate)
+	}
+	if rate, ok := counter.RateAt(300, start.Add(10*time.Second)); !ok || rate != 20 {
+		t.Errorf("Unexpected rate. Expected %v got %v %v", 20, rate, ok)
+	}
+	if rate, ok := counter.RateAt(50, start.Add(20*time.Second)); ok {
+		t.Errorf("Unexpected rate after counter reset: %v", rate)
+	}
+	if rate, ok := counter.RateAt(150, start.Add(30*time.Second)); !ok || rate != 10 {
+		t.Errorf("Unexpected rate. Expected %v got %v %v", 10, rate, ok)
+	}
+	if rate, ok := counter.RateAt(150, start.Add(40*time.Second)); !ok || rate != 0 {
+		t.Errorf("Unexpected rate for unchanged counter. Expected %v got %v %v", 0, rate, ok)
+	}
+}
+
+func TestCounterClock(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	counter := &zbx.Counter{Clock: clock}
+	counter.Rate(100)
+	clock.Advance(4 * time.Second)
+	if rate, ok := counter.Rate(300); !ok || rate != 50 {
+		t.Errorf("Unexpected rate. Expected %v got %v %v", 50, rate, ok)
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	t.Parallel()
+
+	counter := &zbx.Counter{}
+	if delta, ok := counter.Delta(5); ok {
+		t.Errorf("Unexpected delta for first sample: %v", delta)
+	}
+	if delta, ok := counter.Delta(12); !ok || delta != 7 {
+		t.Errorf("Unexpected delta. Expected %v got %v %v", 7, delta, ok)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	bytesSent := 0
+	itemFunc := zbx.Counters(func(key string) (interface{}, error) {
+		switch key {
+		case "net.if.out":
+			bytesSent += 1000
+			return bytesSent, nil
+		case "agent.ping":
+			return 1, nil
+		}
+		return nil, nil
+	}, clock, "net.if.out")
+
+	if value, err := itemFunc("net.if.out"); err != zbx.ErrNoValue {
+		t.Errorf("Unexpected result for first sample: %v %v", value, err)
+	}
+	clock.Advance(10 * time.Second)
+	if value, err := itemFunc("net.if.out"); err != nil || value != "100" {
+		t.Errorf("Unexpected result for second sample: %v %v", value, err)
+	}
+	if value, _ := itemFunc("agent.ping"); value != 1 {
+		t.Errorf("Unexpected value for non-counter key: %v", value)
+	}
+}
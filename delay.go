@@ -0,0 +1,489 @@
+package zbx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delay describes a parsed Zabbix item update interval, as configured on the server and sent to agents in the
+// delay field of active checks. A delay is made up of a simple interval and optional flexible and scheduling
+// intervals.
+type Delay struct {
+	// Interval is the default time between collections. May be 0 if the item is only collected by its flexible or
+	// scheduling intervals.
+	Interval time.Duration
+	// Flexible are the intervals that replace Interval during specific periods of the week.
+	Flexible []FlexibleInterval
+	// Scheduling are the intervals that collect the item at specific times, in addition to any other interval.
+	Scheduling []SchedulingInterval
+}
+
+// FlexibleInterval describes an update interval that is only used during a specific period, such as
+// "10s/1-5,09:00-18:00".
+type FlexibleInterval struct {
+	// Interval is the time between collections during this period. An interval of 0 means the item is not
+	// collected during this period.
+	Interval time.Duration
+	// FromDay and ToDay are the first and last days of the week of this period, where 1 is Monday and 7 is Sunday.
+	FromDay int
+	ToDay   int
+	// From and To are the start and end of this period as a duration since midnight.
+	From time.Duration
+	To   time.Duration
+}
+
+// SchedulingInterval describes a set of specific times when an item is collected, such as "wd1-5h9-18".
+type SchedulingInterval struct {
+	monthDays []bool
+	weekDays  []bool
+	hours     []bool
+	minutes   []bool
+	seconds   []bool
+}
+
+// maxScheduleDays is the number of days searched for the next time of a scheduling interval. This is large enough
+// for schedules that only match on specific days of a leap year.
+const maxScheduleDays = 366 * 28
+
+// ParseDelay parses a Zabbix item update interval, such as "1m", "30s;10s/1-5,09:00-18:00" or "0;wd1-5h9-18".
+// The simple interval must come first and may be followed by any number of flexible or scheduling intervals,
+// separated by semicolons. Time suffixes (s, m, h, d and w) are supported, but user macros are not.
+func ParseDelay(s string) (*Delay, error) {
+	parts := strings.Split(s, ";")
+
+	interval, err := parseTimeSuffix(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval '%s': %s", parts[0], err.Error())
+	}
+
+	delay := &Delay{Interval: interval}
+	for _, part := range parts[1:] {
+		if part == "" {
+			return nil, fmt.Errorf("empty custom interval")
+		}
+
+		// Flexible intervals start with their interval, scheduling intervals start with a filter prefix
+		if part[0] >= '0' && part[0] <= '9' {
+			flexible, err := parseFlexibleInterval(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flexible interval '%s': %s", part, err.Error())
+			}
+			delay.Flexible = append(delay.Flexible, *flexible)
+			continue
+		}
+
+		scheduling, err := parseSchedulingInterval(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduling interval '%s': %s", part, err.Error())
+		}
+		delay.Scheduling = append(delay.Scheduling, *scheduling)
+	}
+
+	if delay.Interval == 0 && len(delay.Scheduling) == 0 {
+		hasFlexible := false
+		for _, flexible := range delay.Flexible {
+			if flexible.Interval > 0 {
+				hasFlexible = true
+				break
+			}
+		}
+		if !hasFlexible {
+			return nil, fmt.Errorf("interval is 0 without a non-zero flexible interval or scheduling interval")
+		}
+	}
+
+	return delay, nil
+}
+
+// Next returns the next time after t that the item should be collected. Returns false if the item will never be
+// collected.
+func (d *Delay) Next(t time.Time) (time.Time, bool) {
+	next, ok := d.nextInterval(t)
+
+	for _, scheduling := range d.Scheduling {
+		scheduled, scheduledOk := scheduling.Next(t)
+		if !scheduledOk {
+			continue
+		}
+		if !ok || scheduled.Before(next) {
+			next = scheduled
+			ok = true
+		}
+	}
+
+	return next, ok
+}
+
+// IntervalAt returns the interval in effect at t, taking any flexible intervals into account. If multiple
+// flexible intervals are in effect the smallest one is used. An interval of 0 means the item is not collected at t
+// other than by any scheduling intervals.
+func (d *Delay) IntervalAt(t time.Time) time.Duration {
+	interval := d.Interval
+	active := false
+	for _, flexible := range d.Flexible {
+		if !flexible.Contains(t) {
+			continue
+		}
+		if !active || flexible.Interval < interval {
+			interval = flexible.Interval
+			active = true
+		}
+	}
+	return interval
+}
+
+// nextInterval returns the next collection time after t from the simple and flexible intervals
+func (d *Delay) nextInterval(t time.Time) (time.Time, bool) {
+	// Flexible intervals repeat weekly, so if nothing is found within a week it never will be
+	limit := t.AddDate(0, 0, 8)
+	for t.Before(limit) {
+		interval := d.IntervalAt(t)
+		boundary, hasBoundary := d.nextBoundary(t)
+
+		if interval > 0 {
+			next := t.Add(interval)
+			if !hasBoundary || !boundary.Before(next) {
+				return next, true
+			}
+		} else if !hasBoundary {
+			return time.Time{}, false
+		}
+
+		// The interval changes before the next collection, start again from there
+		t = boundary
+	}
+
+	return time.Time{}, false
+}
+
+// nextBoundary returns the next time after t when any flexible interval starts or ends
+func (d *Delay) nextBoundary(t time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, flexible := range d.Flexible {
+		boundary, ok := flexible.nextBoundary(t)
+		if !ok {
+			continue
+		}
+		if !found || boundary.Before(next) {
+			next = boundary
+			found = true
+		}
+	}
+	return next, found
+}
+
+// Contains returns true if t is within the period of this flexible interval
+func (f FlexibleInterval) Contains(t time.Time) bool {
+	weekday := zabbixWeekday(t)
+	if weekday < f.FromDay || weekday > f.ToDay {
+		return false
+	}
+
+	sinceMidnight := t.Sub(startOfDay(t))
+	return sinceMidnight >= f.From && sinceMidnight < f.To
+}
+
+func (f FlexibleInterval) nextBoundary(t time.Time) (time.Time, bool) {
+	start := startOfDay(t)
+	for i := 0; i <= 7; i++ {
+		day := start.AddDate(0, 0, i)
+		weekday := zabbixWeekday(day)
+		if weekday < f.FromDay || weekday > f.ToDay {
+			continue
+		}
+		for _, offset := range []time.Duration{f.From, f.To} {
+			boundary := day.Add(offset)
+			if boundary.After(t) {
+				return boundary, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Next returns the next time after t that matches this scheduling interval. Returns false if no time matches.
+func (s SchedulingInterval) Next(t time.Time) (time.Time, bool) {
+	today := startOfDay(t)
+	for i := 0; i < maxScheduleDays; i++ {
+		day := today.AddDate(0, 0, i)
+		if !s.monthDays[day.Day()] || !s.weekDays[zabbixWeekday(day)] {
+			continue
+		}
+
+		for hour := 0; hour < 24; hour++ {
+			if !s.hours[hour] || i == 0 && hour < t.Hour() {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if !s.minutes[minute] || i == 0 && hour == t.Hour() && minute < t.Minute() {
+					continue
+				}
+				for second := 0; second < 60; second++ {
+					if !s.seconds[second] {
+						continue
+					}
+					next := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, t.Location())
+					if next.After(t) {
+						return next, true
+					}
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseTimeSuffix(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	unit := time.Second
+	switch s[len(s)-1] {
+	case 's':
+		s = s[:len(s)-1]
+	case 'm':
+		unit = time.Minute
+		s = s[:len(s)-1]
+	case 'h':
+		unit = time.Hour
+		s = s[:len(s)-1]
+	case 'd':
+		unit = 24 * time.Hour
+		s = s[:len(s)-1]
+	case 'w':
+		unit = 7 * 24 * time.Hour
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("not a number")
+	}
+	return time.Duration(value) * unit, nil
+}
+
+// parseFlexibleInterval parses an interval in the format <interval>/<d>[-<d>],<hh>:<mm>-<hh>:<mm>
+func parseFlexibleInterval(s string) (*FlexibleInterval, error) {
+	slash := strings.Index(s, "/")
+	if slash == -1 {
+		return nil, fmt.Errorf("missing period")
+	}
+	interval, err := parseTimeSuffix(s[:slash])
+	if err != nil {
+		return nil, err
+	}
+
+	period := s[slash+1:]
+	comma := strings.Index(period, ",")
+	if comma == -1 {
+		return nil, fmt.Errorf("missing time in period")
+	}
+
+	days := strings.SplitN(period[:comma], "-", 2)
+	fromDay, err := parseRangeValue(days[0], 1, 7)
+	if err != nil {
+		return nil, err
+	}
+	toDay := fromDay
+	if len(days) == 2 {
+		if toDay, err = parseRangeValue(days[1], 1, 7); err != nil {
+			return nil, err
+		}
+	}
+	if fromDay > toDay {
+		return nil, fmt.Errorf("first day is after last day")
+	}
+
+	times := strings.SplitN(period[comma+1:], "-", 2)
+	if len(times) != 2 {
+		return nil, fmt.Errorf("missing end time in period")
+	}
+	from, err := parseTimeOfDay(times[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTimeOfDay(times[1])
+	if err != nil {
+		return nil, err
+	}
+	if from >= to {
+		return nil, fmt.Errorf("start time is not before end time")
+	}
+
+	return &FlexibleInterval{
+		Interval: interval,
+		FromDay:  fromDay,
+		ToDay:    toDay,
+		From:     from,
+		To:       to,
+	}, nil
+}
+
+// parseTimeOfDay parses a time in the format <h>:<mm>, from 0:00 to 24:00
+func parseTimeOfDay(s string) (time.Duration, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+	hour, err := parseRangeValue(parts[0], 0, 24)
+	if err != nil {
+		return 0, err
+	}
+	minute, err := parseRangeValue(parts[1], 0, 59)
+	if err != nil {
+		return 0, err
+	}
+	if hour == 24 && minute != 0 {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// scheduleFilters are the prefixes of a scheduling interval in the order they must appear, with their valid ranges
+var scheduleFilters = []struct {
+	prefix string
+	min    int
+	max    int
+}{
+	{"md", 1, 31},
+	{"wd", 1, 7},
+	{"h", 0, 23},
+	{"m", 0, 59},
+	{"s", 0, 59},
+}
+
+// parseSchedulingInterval parses an interval in the format md<filter>wd<filter>h<filter>m<filter>s<filter>, where
+// each filter is optional but they must appear in that order.
+//
+// Filters not given for units larger than the smallest given unit match any value, filters not given for smaller
+// units match only their first value. For example "h9-17" matches 09:00:00 through 17:00:00 once per hour on every
+// day.
+func parseSchedulingInterval(s string) (*SchedulingInterval, error) {
+	sets := make([][]bool, len(scheduleFilters))
+	smallest := -1
+	remaining := s
+	for i, filter := range scheduleFilters {
+		if !strings.HasPrefix(remaining, filter.prefix) {
+			continue
+		}
+		// "m" is a prefix of "md", which can't follow any other filter
+		if filter.prefix == "m" && strings.HasPrefix(remaining, "md") {
+			return nil, fmt.Errorf("filters out of order")
+		}
+		remaining = remaining[len(filter.prefix):]
+
+		end := strings.IndexFunc(remaining, func(r rune) bool {
+			return !(r >= '0' && r <= '9' || r == '-' || r == '/' || r == ',')
+		})
+		if end == -1 {
+			end = len(remaining)
+		}
+
+		set, err := parseScheduleFilter(remaining[:end], filter.min, filter.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s filter: %s", filter.prefix, err.Error())
+		}
+		sets[i] = set
+		smallest = i
+		remaining = remaining[end:]
+	}
+	if remaining != "" || smallest == -1 {
+		return nil, fmt.Errorf("unrecognized filter")
+	}
+
+	for i, filter := range scheduleFilters {
+		if sets[i] != nil {
+			continue
+		}
+		set := make([]bool, filter.max+1)
+		if i > smallest {
+			set[filter.min] = true
+		} else {
+			for j := filter.min; j <= filter.max; j++ {
+				set[j] = true
+			}
+		}
+		sets[i] = set
+	}
+
+	return &SchedulingInterval{
+		monthDays: sets[0],
+		weekDays:  sets[1],
+		hours:     sets[2],
+		minutes:   sets[3],
+		seconds:   sets[4],
+	}, nil
+}
+
+// parseScheduleFilter parses a comma separated list of <from>[-<to>][/<step>] values
+func parseScheduleFilter(s string, min, max int) ([]bool, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty filter")
+	}
+
+	set := make([]bool, max+1)
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if slash := strings.Index(item, "/"); slash != -1 {
+			var err error
+			if step, err = parseRangeValue(item[slash+1:], 1, max); err != nil {
+				return nil, err
+			}
+			item = item[:slash]
+		}
+
+		from, to := min, max
+		if item != "" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if from, err = parseRangeValue(bounds[0], min, max); err != nil {
+				return nil, err
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = parseRangeValue(bounds[1], min, max); err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				to = max
+			}
+			if from > to {
+				return nil, fmt.Errorf("range start is after range end")
+			}
+		}
+
+		for i := from; i <= to; i += step {
+			set[i] = true
+		}
+	}
+
+	return set, nil
+}
+
+func parseRangeValue(s string, min, max int) (int, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil || s == "" || s[0] == '-' || s[0] == '+' {
+		return 0, fmt.Errorf("invalid value '%s'", s)
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", value, min, max)
+	}
+	return value, nil
+}
+
+// zabbixWeekday returns the day of the week of t where 1 is Monday and 7 is Sunday
+func zabbixWeekday(t time.Time) int {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		return 7
+	}
+	return weekday
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,154 @@
+package zbx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// 2021-06-07 is a Monday
+func testTime(day, hour, minute, second int) time.Time {
+	return time.Date(2021, time.June, day, hour, minute, second, 0, time.UTC)
+}
+
+func TestParseDelaySimple(t *testing.T) {
+	t.Parallel()
+
+	check := func(s string, expected time.Duration) {
+		delay, err := zbx.ParseDelay(s)
+		if err != nil {
+			t.Errorf("Error parsing delay '%s': %s", s, err.Error())
+			return
+		}
+		if delay.Interval != expected {
+			t.Errorf("Unexpected interval for '%s'. Expected %s got %s", s, expected, delay.Interval)
+		}
+	}
+
+	check("30", 30*time.Second)
+	check("30s", 30*time.Second)
+	check("5m", 5*time.Minute)
+	check("1h", time.Hour)
+	check("1d", 24*time.Hour)
+	check("1w", 7*24*time.Hour)
+}
+
+func TestParseDelayInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{
+		"",
+		"{$DELAY}",
+		"-1",
+		"1x",
+		"0",
+		"0;0/1-7,00:00-24:00",
+		"1m;",
+		"1m;10s/1-5",
+		"1m;10s/5-1,09:00-18:00",
+		"1m;10s/1-5,18:00-09:00",
+		"1m;10s/1-8,09:00-18:00",
+		"1m;10s/1-5,09:00-24:30",
+		"1m;wd0",
+		"1m;h24",
+		"1m;h9wd1",
+		"1m;hmd1",
+		"1m;x1",
+		"1m;m5-1",
+	} {
+		if _, err := zbx.ParseDelay(s); err == nil {
+			t.Errorf("No error seen when parsing invalid delay '%s'", s)
+		}
+	}
+}
+
+func TestDelayFlexible(t *testing.T) {
+	t.Parallel()
+
+	delay, err := zbx.ParseDelay("1m;10s/1-5,09:00-18:00")
+	if err != nil {
+		t.Fatalf("Error parsing delay: %s", err.Error())
+	}
+	if len(delay.Flexible) != 1 {
+		t.Fatalf("Unexpected number of flexible intervals: %d", len(delay.Flexible))
+	}
+	flexible := delay.Flexible[0]
+	if flexible.Interval != 10*time.Second || flexible.FromDay != 1 || flexible.ToDay != 5 || flexible.From != 9*time.Hour || flexible.To != 18*time.Hour {
+		t.Errorf("Unexpected flexible interval: %+v", flexible)
+	}
+
+	check := func(now time.Time, expected time.Time) {
+		next, ok := delay.Next(now)
+		if !ok {
+			t.Errorf("No next time after %s", now)
+			return
+		}
+		if !next.Equal(expected) {
+			t.Errorf("Unexpected next time after %s. Expected %s got %s", now, expected, next)
+		}
+	}
+
+	// Monday during business hours
+	check(testTime(7, 12, 0, 0), testTime(7, 12, 0, 10))
+	// Monday before business hours
+	check(testTime(7, 8, 0, 0), testTime(7, 8, 1, 0))
+	// Monday just before business hours starts, the shorter interval applies from 09:00
+	check(testTime(7, 8, 59, 30), testTime(7, 9, 0, 10))
+	// Saturday during business hours
+	check(testTime(12, 12, 0, 0), testTime(12, 12, 1, 0))
+}
+
+func TestDelayFlexibleZero(t *testing.T) {
+	t.Parallel()
+
+	delay, err := zbx.ParseDelay("1m;0/1-7,00:00-06:00")
+	if err != nil {
+		t.Fatalf("Error parsing delay: %s", err.Error())
+	}
+
+	if interval := delay.IntervalAt(testTime(7, 3, 0, 0)); interval != 0 {
+		t.Errorf("Unexpected interval. Expected 0 got %s", interval)
+	}
+	next, ok := delay.Next(testTime(7, 3, 0, 0))
+	if !ok {
+		t.Fatalf("No next time when one expected")
+	}
+	if expected := testTime(7, 6, 1, 0); !next.Equal(expected) {
+		t.Errorf("Unexpected next time. Expected %s got %s", expected, next)
+	}
+}
+
+func TestDelayScheduling(t *testing.T) {
+	t.Parallel()
+
+	check := func(s string, now time.Time, expected time.Time) {
+		delay, err := zbx.ParseDelay(s)
+		if err != nil {
+			t.Errorf("Error parsing delay '%s': %s", s, err.Error())
+			return
+		}
+		next, ok := delay.Next(now)
+		if !ok {
+			t.Errorf("No next time for '%s' after %s", s, now)
+			return
+		}
+		if !next.Equal(expected) {
+			t.Errorf("Unexpected next time for '%s' after %s. Expected %s got %s", s, now, expected, next)
+		}
+	}
+
+	// Weekdays on the hour from 9 to 18
+	check("0;wd1-5h9-18", testTime(7, 12, 30, 0), testTime(7, 13, 0, 0))
+	check("0;wd1-5h9-18", testTime(7, 18, 0, 0), testTime(8, 9, 0, 0))
+	check("0;wd1-5h9-18", testTime(11, 18, 0, 0), testTime(14, 9, 0, 0))
+	// Every 5 minutes
+	check("0;m/5", testTime(7, 12, 31, 0), testTime(7, 12, 35, 0))
+	// At 30 seconds past every minute on Mondays
+	check("0;wd1s30", testTime(7, 12, 31, 45), testTime(7, 12, 32, 30))
+	// First and fifteenth of the month
+	check("0;md1,15h12", testTime(7, 12, 0, 0), testTime(15, 12, 0, 0))
+	// Scheduling intervals are used in addition to the simple interval
+	check("1h;h12m30", testTime(7, 12, 0, 0), testTime(7, 12, 30, 0))
+	check("1m;h12m30", testTime(7, 12, 0, 0), testTime(7, 12, 1, 0))
+}
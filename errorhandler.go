@@ -0,0 +1,121 @@
+package zbx
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorCategory describes the type of an error encountered by a Server
+type ErrorCategory string
+
+const (
+	// ErrorCategoryProtocol is for malformed requests, or errors reading a request
+	ErrorCategoryProtocol ErrorCategory = "protocol"
+	// ErrorCategoryItem is for errors returned by the ItemFunc
+	ErrorCategoryItem ErrorCategory = "item"
+	// ErrorCategoryPanic is for panics recovered from the ItemFunc or while handling a connection
+	ErrorCategoryPanic ErrorCategory = "panic"
+	// ErrorCategoryConnection is for errors writing a reply
+	ErrorCategoryConnection ErrorCategory = "connection"
+	// ErrorCategoryTLS is for failed TLS handshakes
+	ErrorCategoryTLS ErrorCategory = "tls"
+	// ErrorCategoryAccept is for errors accepting connections on the listener
+	ErrorCategoryAccept ErrorCategory = "accept"
+)
+
+// ErrorEvent describes an error encountered by a Server
+type ErrorEvent struct {
+	// Category is the type of error
+	Category ErrorCategory
+	// Peer is the remote address of the connection that caused the error, if any
+	Peer string
+	// Key is the requested item key, for item and panic errors
+	Key string
+	// Err is the error
+	Err error
+	// Suppressed is the number of identical errors that were not reported since this error was last reported, when
+	// the handler is wrapped with RateLimitErrors
+	Suppressed int
+}
+
+// ErrorHandler describes a type that receives errors encountered by a Server
+type ErrorHandler interface {
+	HandleError(event ErrorEvent)
+}
+
+// ErrorHandlerFunc is an adapter to allow the use of ordinary functions as an ErrorHandler
+type ErrorHandlerFunc func(event ErrorEvent)
+
+// HandleError calls f(event)
+func (f ErrorHandlerFunc) HandleError(event ErrorEvent) {
+	f(event)
+}
+
+// RateLimitErrors returns an ErrorHandler that passes errors to handler, but suppresses identical errors, those with
+// the same category, remote host, key and message, that occur within interval of the last one that was passed. The
+// number of errors that were suppressed is included in the Suppressed field of the next event that is passed.
+func RateLimitErrors(handler ErrorHandler, interval time.Duration) ErrorHandler {
+	if handler == nil {
+		panic("handler is nil")
+	}
+
+	sampler := &errorSampler{}
+	return ErrorHandlerFunc(func(event ErrorEvent) {
+		message := ""
+		if event.Err != nil {
+			message = event.Err.Error()
+		}
+		sampleKey := string(event.Category) + "|" + remoteHost(event.Peer) + "|" + event.Key + "|" + message
+		report, suppressed := sampler.sample(sampleKey, interval, time.Now())
+		if !report {
+			return
+		}
+		event.Suppressed = suppressed
+		handler.HandleError(event)
+	})
+}
+
+func (s *Server) handleError(event ErrorEvent) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler.HandleError(event)
+	}
+}
+
+// DefaultMaxErrorLength is the longest error message replied to the server when a server does not have a
+// MaxErrorLength, which is the length of the error of an item in the Zabbix database
+const DefaultMaxErrorLength = 2048
+
+// errorTruncation separates the start of a shortened error message from its root cause
+const errorTruncation = "... "
+
+func (s *Server) maxErrorLength() int {
+	if s.MaxErrorLength <= 0 {
+		return DefaultMaxErrorLength
+	}
+	return s.MaxErrorLength
+}
+
+// truncateError returns the message of err shortened to at most limit characters. Wrapped errors put the root cause
+// last, so rather than cutting it off the message is shortened from the middle, keeping as much of the start as fits
+// before the message of the last error in the Unwrap chain.
+func truncateError(err error, limit int) string {
+	message := []rune(err.Error())
+	if len(message) <= limit {
+		return string(message)
+	}
+
+	root := err
+	for unwrapped := errors.Unwrap(root); unwrapped != nil; unwrapped = errors.Unwrap(root) {
+		root = unwrapped
+	}
+	cause := []rune(root.Error())
+	keep := limit - len(errorTruncation) - len(cause)
+	if root == err || keep <= 0 {
+		// There is no room for the root cause after the start of the message, so keep the start of the root cause
+		if len(cause) > limit {
+			cause = cause[:limit]
+		}
+		return string(cause)
+	}
+	return string(message[:keep]) + errorTruncation + string(cause)
+}
@@ -0,0 +1,128 @@
+package zbx_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan zbx.ErrorEvent, 10)
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "generate.error":
+				return nil, fmt.Errorf("this is an error")
+			case "panic":
+				panic("Ah!")
+			}
+			return 1, nil
+		},
+		ErrorHandler: zbx.ErrorHandlerFunc(func(event zbx.ErrorEvent) {
+			events <- event
+		}),
+	})
+
+	next := func() zbx.ErrorEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No error event received")
+		}
+		return zbx.ErrorEvent{}
+	}
+
+	queryKey(t, addr, "generate.error")
+	event := next()
+	if event.Category != zbx.ErrorCategoryItem || event.Key != "generate.error" || event.Err.Error() != "this is an error" || event.Peer == "" {
+		t.Errorf("Unexpected item error event: %+v", event)
+	}
+
+	queryKey(t, addr, "panic")
+	event = next()
+	if event.Category != zbx.ErrorCategoryPanic || event.Key != "panic" {
+		t.Errorf("Unexpected panic event: %+v", event)
+	}
+
+	sendGarbage(t, addr)
+	event = next()
+	if event.Category != zbx.ErrorCategoryProtocol || event.Err.Error() != "unsupported flags 7f" {
+		t.Errorf("Unexpected protocol error event: %+v", event)
+	}
+}
+
+func TestRateLimitErrors(t *testing.T) {
+	t.Parallel()
+
+	events := []zbx.ErrorEvent{}
+	handler := zbx.RateLimitErrors(zbx.ErrorHandlerFunc(func(event zbx.ErrorEvent) {
+		events = append(events, event)
+	}), 50*time.Millisecond)
+
+	err := fmt.Errorf("unsupported flags 7f")
+	handler.HandleError(zbx.ErrorEvent{Category: zbx.ErrorCategoryProtocol, Peer: "192.0.2.1:1234", Err: err})
+	handler.HandleError(zbx.ErrorEvent{Category: zbx.ErrorCategoryProtocol, Peer: "192.0.2.1:1235", Err: err})
+	handler.HandleError(zbx.ErrorEvent{Category: zbx.ErrorCategoryProtocol, Peer: "192.0.2.1:1236", Err: err})
+	handler.HandleError(zbx.ErrorEvent{Category: zbx.ErrorCategoryProtocol, Peer: "192.0.2.2:1234", Err: err})
+	if len(events) != 2 {
+		t.Fatalf("Unexpected number of events. Expected 2 got %d", len(events))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	handler.HandleError(zbx.ErrorEvent{Category: zbx.ErrorCategoryProtocol, Peer: "192.0.2.1:1237", Err: err})
+	if len(events) != 3 {
+		t.Fatalf("Unexpected number of events. Expected 3 got %d", len(events))
+	}
+	if events[2].Suppressed != 2 {
+		t.Errorf("Unexpected suppressed count. Expected 2 got %d", events[2].Suppressed)
+	}
+}
+
+func TestMaxErrorLength(t *testing.T) {
+	t.Parallel()
+
+	rootCause := errors.New("connection refused")
+	itemErrors := map[string]error{
+		"wrapped.error": fmt.Errorf("querying %s: %w", strings.Repeat("x", 100), fmt.Errorf("dialing database: %w", rootCause)),
+		"long.error":    errors.New(strings.Repeat("y", 100)),
+		"short.error":   fmt.Errorf("dialing database: %w", rootCause),
+	}
+	events := make(chan zbx.ErrorEvent, 10)
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return nil, itemErrors[key]
+		},
+		MaxErrorLength: 40,
+		ErrorHandler: zbx.ErrorHandlerFunc(func(event zbx.ErrorEvent) {
+			events <- event
+		}),
+	})
+
+	expected := map[string]string{
+		"wrapped.error": "ZBX_NOTSUPPORTED\x00querying xxxxxxxxx... connection refused",
+		"long.error":    "ZBX_NOTSUPPORTED\x00" + strings.Repeat("y", 40),
+		"short.error":   "ZBX_NOTSUPPORTED\x00dialing database: connection refused",
+	}
+	for key, expectedReply := range expected {
+		reply := string(queryKey(t, addr, key))
+		if reply != expectedReply {
+			t.Errorf("Unexpected reply for '%s'. Expected '%s' got '%s'", key, expectedReply, reply)
+		}
+
+		select {
+		case event := <-events:
+			if event.Err != itemErrors[key] {
+				t.Errorf("Error handler was not given the full error for '%s': %+v", key, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No error event received")
+		}
+	}
+}
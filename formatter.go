@@ -0,0 +1,149 @@
+package zbx
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter converts a value returned by a Handler into the value sent to the server, such as to turn a Go type into
+// the number that a numeric item expects. It returns an error if the value cannot be converted.
+type Formatter func(value interface{}) (interface{}, error)
+
+// FormatValues returns a Handler that responds with the values of handler converted by formatter, for use when
+// registering the handler with a Router:
+//
+//	router.Register("app.healthy", zbx.FormatValues(healthItem, zbx.Bool))
+//
+// Unknown keys and errors from handler are passed on without calling formatter.
+func FormatValues(handler Handler, formatter Formatter) Handler {
+	if handler == nil || formatter == nil {
+		panic("handler or formatter is nil")
+	}
+
+	return HandlerFunc(func(params []string) (interface{}, error) {
+		value, err := handler.Value(params)
+		if err != nil || value == nil {
+			return value, err
+		}
+		return formatter(value)
+	})
+}
+
+// Bool is a Formatter that converts bools, including named types based on bool, to 1 for true and 0 for false. Other
+// values are an error.
+func Bool(value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Bool {
+		return nil, fmt.Errorf("value '%v' is not a bool", value)
+	}
+	if v.Bool() {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ValueMap maps values, such as the constants of an enumerated type, to the numbers sent to the server, for use with
+// a value map in Zabbix. Its Format method is a Formatter:
+//
+//	states := zbx.ValueMap{StateIdle: 0, StateRunning: 1, StateFailed: 2}
+//	router.Register("app.state", zbx.FormatValues(stateItem, states.Format))
+type ValueMap map[interface{}]int
+
+// Format returns the number that value is mapped to. Values that are not in the map are an error. A nil value, which
+// FormatValues does not pass to formatters, is returned unchanged unless the map has a number for nil.
+func (m ValueMap) Format(value interface{}) (interface{}, error) {
+	if value == nil {
+		if number, ok := m[nil]; ok {
+			return number, nil
+		}
+		return nil, nil
+	}
+	if !reflect.TypeOf(value).Comparable() {
+		return nil, fmt.Errorf("value '%v' is not in the value map", value)
+	}
+	number, ok := m[value]
+	if !ok {
+		return nil, fmt.Errorf("value '%v' is not in the value map", value)
+	}
+	return number, nil
+}
+
+// Seconds is a Formatter that converts time.Duration values to a number of seconds with a fraction, such as "90.5"
+// rather than the "1m30.5s" of the String method, which the server does not accept for numeric items. Other values
+// are an error.
+func Seconds(value interface{}) (interface{}, error) {
+	duration, ok := value.(time.Duration)
+	if !ok {
+		return nil, fmt.Errorf("value '%v' is not a duration", value)
+	}
+	return strconv.FormatFloat(duration.Seconds(), 'f', -1, 64), nil
+}
+
+// byteUnits are the multipliers of the units accepted by Bytes. Single letters are powers of 1024, as in the suffixes
+// of Zabbix.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"m":   1 << 20,
+	"g":   1 << 30,
+	"t":   1 << 40,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// Bytes is a Formatter that converts sizes to a whole number of bytes. Sizes are either non-negative integers, which
+// are already a number of bytes, or strings with an optional unit such as "512K", "1.5 GiB" or "20MB". Units are case
+// insensitive; "KB" and the like are powers of 1000, while "KiB" and single letters like "K" are powers of 1024.
+// Other values are an error.
+func Bytes(value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return nil, fmt.Errorf("size '%v' is negative", value)
+		}
+		return uint64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.String:
+		return parseByteSize(v.String())
+	}
+	return nil, fmt.Errorf("value '%v' is not a size", value)
+}
+
+// parseByteSize returns the number of bytes of a size with an optional unit
+func parseByteSize(size string) (uint64, error) {
+	str := strings.TrimSpace(size)
+	numberLength := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if numberLength == -1 {
+		numberLength = len(str)
+	}
+
+	number, err := strconv.ParseFloat(str[:numberLength], 64)
+	if err != nil {
+		return 0, fmt.Errorf("size '%s' is not a number", size)
+	}
+	multiplier, ok := byteUnits[strings.ToLower(strings.TrimSpace(str[numberLength:]))]
+	if !ok {
+		return 0, fmt.Errorf("size '%s' has an unknown unit", size)
+	}
+	bytes := math.Round(number * multiplier)
+	// 2^64 is the first float that does not fit, as float64(math.MaxUint64) rounds up to it
+	if bytes >= math.Exp2(64) {
+		return 0, fmt.Errorf("size '%s' is too large", size)
+	}
+	return uint64(bytes), nil
+}
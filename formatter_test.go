@@ -0,0 +1,153 @@
+package zbx_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+type serviceState int
+
+const (
+	serviceStopped serviceState = iota
+	serviceRunning
+	serviceFailed
+)
+
+type healthy bool
+
+func TestFormatValues(t *testing.T) {
+	t.Parallel()
+
+	states := zbx.ValueMap{serviceStopped: 0, serviceRunning: 1, serviceFailed: 9}
+	router := &zbx.Router{}
+	register := func(name string, handler zbx.Handler) {
+		if err := router.Register(name, handler); err != nil {
+			t.Fatalf("Error registering handler: %s", err.Error())
+		}
+	}
+	register("app.healthy", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return true, nil
+	}), zbx.Bool))
+	register("app.degraded", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return healthy(false), nil
+	}), zbx.Bool))
+	register("app.state", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return serviceFailed, nil
+	}), states.Format))
+	register("app.unmapped", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return serviceState(7), nil
+	}), states.Format))
+	register("app.untyped", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		// An int is not the same value as a serviceState
+		return 2, nil
+	}), states.Format))
+	register("app.slice", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return []int{1}, nil
+	}), states.Format))
+	register("app.notbool", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return "yes", nil
+	}), zbx.Bool))
+	register("app.unknown", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return nil, nil
+	}), zbx.Bool))
+	register("app.broken", zbx.FormatValues(zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return nil, fmt.Errorf("not available")
+	}), zbx.Bool))
+
+	expected := map[string]interface{}{
+		"app.healthy":  1,
+		"app.degraded": 0,
+		"app.state":    9,
+	}
+	for key, expectedValue := range expected {
+		value, err := router.ItemFunc(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%v' got '%v'", key, expectedValue, value)
+		}
+	}
+
+	for _, key := range []string{"app.unmapped", "app.untyped", "app.slice", "app.notbool", "app.broken"} {
+		if _, err := router.ItemFunc(key); err == nil {
+			t.Errorf("No error seen for '%s'", key)
+		}
+	}
+	if value, err := router.ItemFunc("app.unknown"); value != nil || err != nil {
+		t.Errorf("Unknown key was not passed on. Got '%v', '%v'", value, err)
+	}
+}
+
+// Ensure that Format can be called directly with a nil value, such as from a Formatter that wraps it
+func TestValueMapNil(t *testing.T) {
+	t.Parallel()
+
+	states := zbx.ValueMap{serviceStopped: 0, serviceRunning: 1}
+	if value, err := states.Format(nil); value != nil || err != nil {
+		t.Errorf("Unexpected result for nil value: %v %v", value, err)
+	}
+
+	states[nil] = 7
+	if value, err := states.Format(nil); value != 7 || err != nil {
+		t.Errorf("Unexpected result for mapped nil value: %v %v", value, err)
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	t.Parallel()
+
+	expected := map[time.Duration]string{
+		90 * time.Second:        "90",
+		1500 * time.Millisecond: "1.5",
+		-250 * time.Millisecond: "-0.25",
+		0:                       "0",
+	}
+	for duration, expectedValue := range expected {
+		value, err := zbx.Seconds(duration)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", duration, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%s' got '%v'", duration, expectedValue, value)
+		}
+	}
+
+	if _, err := zbx.Seconds(int64(90)); err == nil {
+		t.Errorf("No error seen for value that is not a duration")
+	}
+}
+
+func TestBytes(t *testing.T) {
+	t.Parallel()
+
+	expected := map[interface{}]uint64{
+		1024:      1024,
+		uint8(7):  7,
+		"512":     512,
+		"512B":    512,
+		"512K":    512 * 1024,
+		"1.5 GiB": 1536 * 1024 * 1024,
+		"20MB":    20000000,
+		" 2 tb ":  2000000000000,
+		"0.5k":    512,
+	}
+	for size, expectedValue := range expected {
+		value, err := zbx.Bytes(size)
+		if err != nil {
+			t.Errorf("Unexpected error for '%v': %s", size, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%v'. Expected '%d' got '%v'", size, expectedValue, value)
+		}
+	}
+
+	for _, size := range []interface{}{-1, "-1K", "1.5 parsecs", "", "K", 1.5, "18446744073709551616"} {
+		if _, err := zbx.Bytes(size); err == nil {
+			t.Errorf("No error seen for invalid size '%v'", size)
+		}
+	}
+}
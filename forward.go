@@ -0,0 +1,65 @@
+package zbx
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// DefaultForwardTimeout is the timeout for forwarded requests when none is specified
+const DefaultForwardTimeout = 3 * time.Second
+
+// ForwardUnknown returns an ItemFunc that responds with values from itemFunc, and forwards requests for keys that
+// itemFunc does not know to the Zabbix agent at address, returning its reply. This lets an agent built with zbx
+// front an existing native agent, running on another port, while items are migrated.
+//
+// The request to the downstream agent, including connecting, must complete within timeout, or DefaultForwardTimeout
+// if timeout is 0. If the downstream agent replies that the item is not supported, its message is returned as the
+// error for the item.
+func ForwardUnknown(itemFunc ItemFunc, address string, timeout time.Duration) ItemFunc {
+	if itemFunc == nil {
+		panic("itemFunc is nil")
+	}
+	if timeout <= 0 {
+		timeout = DefaultForwardTimeout
+	}
+
+	return func(key string) (interface{}, error) {
+		value, err := itemFunc(key)
+		if err != nil || value != nil {
+			return value, err
+		}
+		return forward(address, key, timeout)
+	}
+}
+
+// forward requests key from the agent at address
+func forward(address string, key string, timeout time.Duration) (interface{}, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to downstream agent: %s", err.Error())
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := zbxproto.WriteMessage(conn, []byte(key), nil); err != nil {
+		return nil, fmt.Errorf("error sending request to downstream agent: %s", err.Error())
+	}
+	reply, err := zbxproto.ReadMessage(conn, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading reply from downstream agent: %s", err.Error())
+	}
+
+	data := string(reply.Data)
+	if strings.HasPrefix(data, "ZBX_NOTSUPPORTED") {
+		message := strings.TrimPrefix(strings.TrimPrefix(data, "ZBX_NOTSUPPORTED"), "\x00")
+		if message == "" {
+			message = "Item key unknown"
+		}
+		return nil, fmt.Errorf("%s", message)
+	}
+	return data, nil
+}
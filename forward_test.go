@@ -0,0 +1,67 @@
+package zbx_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestForwardUnknown(t *testing.T) {
+	t.Parallel()
+
+	// The downstream agent stands in for a native zabbix_agentd
+	downstream := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "system.uptime":
+				return 86400, nil
+			case "vfs.fs.size[/,free]":
+				return nil, fmt.Errorf("Cannot obtain filesystem information")
+			}
+			return nil, nil
+		},
+	})
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: zbx.ForwardUnknown(func(key string) (interface{}, error) {
+			if key == "app.version" {
+				return "1.4.2", nil
+			}
+			return nil, nil
+		}, downstream, time.Second),
+	})
+
+	expected := map[string]string{
+		"app.version":         "1.4.2",
+		"system.uptime":       "86400",
+		"vfs.fs.size[/,free]": "ZBX_NOTSUPPORTED\x00Cannot obtain filesystem information",
+		"unknown":             "ZBX_NOTSUPPORTED\x00Item key unknown",
+	}
+	for key, expectedReply := range expected {
+		if reply := queryKey(t, addr, key); reply != expectedReply {
+			t.Errorf("Unexpected reply for '%s'. Expected %q got %q", key, expectedReply, reply)
+		}
+	}
+}
+
+func TestForwardUnknownUnavailable(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	downstream := l.Addr().String()
+	l.Close()
+
+	itemFunc := zbx.ForwardUnknown(func(key string) (interface{}, error) {
+		return nil, nil
+	}, downstream, time.Second)
+	if _, err := itemFunc("system.uptime"); err == nil || !strings.Contains(err.Error(), "downstream agent") {
+		t.Errorf("Unexpected error for unavailable downstream agent: %v", err)
+	}
+}
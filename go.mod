@@ -1,3 +1,5 @@
 module github.com/ecnepsnai/zbx
 
-go 1.17
+go 1.18
+
+require go.uber.org/goleak v1.1.12
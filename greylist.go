@@ -0,0 +1,74 @@
+package zbx
+
+import (
+	"fmt"
+	"time"
+)
+
+type invalidRequests struct {
+	count       int
+	windowStart time.Time
+	bannedUntil time.Time
+}
+
+// recordInvalidRequest counts a malformed request from host and returns true if the host has exceeded the
+// InvalidRequestLimit of the server
+func (s *Server) recordInvalidRequest(host string) bool {
+	if s.InvalidRequestLimit <= 0 {
+		return false
+	}
+
+	window := s.InvalidRequestWindow
+	if window <= 0 {
+		window = time.Minute
+	}
+	now := s.clock().Now()
+
+	s.invalidRequestsLock.Lock()
+	defer s.invalidRequestsLock.Unlock()
+
+	if s.invalidRequests == nil {
+		s.invalidRequests = map[string]*invalidRequests{}
+	}
+	record, ok := s.invalidRequests[host]
+	if !ok || now.Sub(record.windowStart) > window {
+		if len(s.invalidRequests) > 1024 {
+			s.pruneInvalidRequests(now, window)
+		}
+		record = &invalidRequests{windowStart: now}
+		s.invalidRequests[host] = record
+	}
+	record.count++
+
+	if record.count < s.InvalidRequestLimit {
+		return false
+	}
+	if s.InvalidRequestBan > 0 && !now.Before(record.bannedUntil) {
+		record.bannedUntil = now.Add(s.InvalidRequestBan)
+		errorWrite("Banning remote host after repeated invalid requests: %s,%s", fmt.Sprintf("remote_host='%s'", host), fmt.Sprintf("duration='%s'", s.InvalidRequestBan))
+	}
+	return true
+}
+
+// isBanned returns true if connections from host should be closed without being read
+func (s *Server) isBanned(host string) bool {
+	if s.InvalidRequestLimit <= 0 || s.InvalidRequestBan <= 0 {
+		return false
+	}
+
+	s.invalidRequestsLock.Lock()
+	defer s.invalidRequestsLock.Unlock()
+
+	record, ok := s.invalidRequests[host]
+	return ok && s.clock().Now().Before(record.bannedUntil)
+}
+
+// pruneInvalidRequests removes records that are no longer counting or banning their host. The caller must hold
+// invalidRequestsLock.
+func (s *Server) pruneInvalidRequests(now time.Time, window time.Duration) {
+	for host, record := range s.invalidRequests {
+		if now.Sub(record.windowStart) > window && !now.Before(record.bannedUntil) {
+			delete(s.invalidRequests, host)
+		}
+	}
+}
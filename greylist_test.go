@@ -0,0 +1,71 @@
+package zbx_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func sendGarbage(t *testing.T, addr string) {
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	if _, err := c.Write([]byte("ZBXD\x7F")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	io.ReadAll(c)
+}
+
+func TestInvalidRequestBan(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		InvalidRequestLimit: 2,
+		InvalidRequestBan:   time.Minute,
+	})
+
+	ping := func() []byte {
+		c, err := retryDial(addr)
+		if err != nil {
+			t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+		}
+		c.Write(requestForKey("agent.ping"))
+		reply, _ := io.ReadAll(c)
+		return reply
+	}
+
+	expectedResponse := []byte("\x5A\x42\x58\x44\x01\x01\x00\x00\x00\x00\x00\x00\x00\x31")
+	sendGarbage(t, addr)
+	if reply := ping(); !bytes.Equal(reply, expectedResponse) {
+		t.Errorf("Unexpected reply from server before ban. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+	}
+	sendGarbage(t, addr)
+	if reply := ping(); len(reply) > 0 {
+		t.Errorf("Unexpected reply from server after ban: %x", reply)
+	}
+}
+
+func TestTarpit(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		InvalidRequestLimit: 1,
+		TarpitDelay:         100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	sendGarbage(t, addr)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Connection closed after %s, before tarpit delay", elapsed)
+	}
+}
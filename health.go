@@ -0,0 +1,73 @@
+package zbx
+
+import (
+	"net/http"
+	"time"
+)
+
+// Health describes the status of a Server, as reported by Server.HealthHandler
+type Health struct {
+	// Healthy is true if the server is accepting connections
+	Healthy bool `json:"healthy"`
+	// Listening is true if the server is listening for connections
+	Listening bool `json:"listening"`
+	// Address is the address of the listener, if listening
+	Address string `json:"address,omitempty"`
+	// Exhausted is true if the server is unable to accept connections because it has run out of resources
+	Exhausted bool `json:"exhausted"`
+	// Maintenance is true if the server is in maintenance mode, see Server.SetMaintenance
+	Maintenance bool `json:"maintenance"`
+	// Uptime is the number of seconds since the server started
+	Uptime int64 `json:"uptime"`
+	// Stats are the counters of the server
+	Stats Stats `json:"stats"`
+}
+
+// Health returns the current status of the server
+func (s *Server) Health() Health {
+	s.listenerLock.Lock()
+	listening := s.serving
+	var address string
+	if listening {
+		address = s.listener.Addr().String()
+	}
+	s.listenerLock.Unlock()
+
+	stats := s.Stats()
+	health := Health{
+		Healthy:     listening && !stats.Exhausted,
+		Listening:   listening,
+		Address:     address,
+		Exhausted:   stats.Exhausted,
+		Maintenance: s.Maintenance(),
+		Stats:       stats,
+	}
+	if !stats.Started.IsZero() {
+		health.Uptime = int64(s.clock().Now().Sub(stats.Started) / time.Second)
+	}
+	return health
+}
+
+// HealthHandler returns an HTTP handler that reports the health of the server as JSON, for use with liveness and
+// readiness probes such as in Kubernetes. Responds with status 200 when the server is healthy, otherwise 503.
+//
+//	http.Handle("/healthz", server.HealthHandler())
+func (s *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := s.Health()
+		body, err := JSON(health)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if health.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte(body))
+	})
+}
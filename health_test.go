@@ -0,0 +1,62 @@
+package zbx_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+	}
+	handler := server.HealthHandler()
+
+	check := func(expectedStatus int) zbx.Health {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+		if recorder.Code != expectedStatus {
+			t.Errorf("Unexpected status code. Expected %d got %d", expectedStatus, recorder.Code)
+		}
+		health := zbx.Health{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &health); err != nil {
+			t.Fatalf("Error decoding health: %s", err.Error())
+		}
+		return health
+	}
+
+	if health := check(http.StatusServiceUnavailable); health.Listening {
+		t.Errorf("Server reported as listening before it was started")
+	}
+
+	addr := startTestServer(t, server)
+	<-server.Ready()
+	queryKey(t, addr, "agent.ping")
+
+	health := check(http.StatusOK)
+	if !health.Healthy || !health.Listening {
+		t.Errorf("Server not reported as healthy and listening: %+v", health)
+	}
+	if health.Address != addr {
+		t.Errorf("Unexpected address. Expected '%s' got '%s'", addr, health.Address)
+	}
+	if health.Stats.Requests != 1 {
+		t.Errorf("Unexpected requests. Expected 1 got %d", health.Stats.Requests)
+	}
+
+	server.Close()
+	for i := 0; i < 100 && server.Health().Listening; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if health := check(http.StatusServiceUnavailable); health.Listening {
+		t.Errorf("Server reported as listening after it was closed")
+	}
+}
@@ -0,0 +1,79 @@
+package zbx
+
+import (
+	"fmt"
+	"os"
+)
+
+// MaxHostnameLength is the maximum length of a Zabbix host name
+const MaxHostnameLength = 128
+
+// ValidateHostname returns a descriptive error if hostname is not a valid Zabbix host name. Host names must be
+// between 1 and MaxHostnameLength characters, and may only contain ASCII letters and digits, spaces, periods,
+// underscores and dashes.
+func ValidateHostname(hostname string) error {
+	if hostname == "" {
+		return fmt.Errorf("hostname is empty")
+	}
+	if len(hostname) > MaxHostnameLength {
+		return fmt.Errorf("hostname is longer than %d characters", MaxHostnameLength)
+	}
+	for i, c := range hostname {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == ' ' || c == '.' || c == '_' || c == '-' {
+			continue
+		}
+		return fmt.Errorf("hostname contains invalid character %q at position %d", c, i)
+	}
+	return nil
+}
+
+// HostnameEnvironmentVariables are the environment variables checked, in order, by HostnameFromEnvironment when
+// no template is given. POD_NAME and NODE_NAME are commonly populated using the Kubernetes downward API.
+var HostnameEnvironmentVariables = []string{"ZBX_HOSTNAME", "POD_NAME", "NODE_NAME"}
+
+// HostnameFromEnvironment returns the hostname that the agent should identify as to Zabbix, such as for the
+// agent.hostname item, from the environment. This is useful in containers where the value of os.Hostname() is
+// meaningless to Zabbix.
+//
+// If template is not empty it is expanded with the values of environment variables, using the ${VAR} or $VAR
+// syntax. For example "${NODE_NAME}-${POD_NAMESPACE}". An error is returned if any variable in the template is not
+// set.
+//
+// If template is empty the first of HostnameEnvironmentVariables that is set is returned, falling back to
+// os.Hostname().
+//
+// An error is returned if the resulting hostname is not valid, see ValidateHostname.
+func HostnameFromEnvironment(template string) (string, error) {
+	hostname, err := hostnameFromEnvironment(template)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateHostname(hostname); err != nil {
+		return "", err
+	}
+	return hostname, nil
+}
+
+func hostnameFromEnvironment(template string) (string, error) {
+	if template == "" {
+		for _, name := range HostnameEnvironmentVariables {
+			if value := os.Getenv(name); value != "" {
+				return value, nil
+			}
+		}
+		return os.Hostname()
+	}
+
+	var missing string
+	hostname := os.Expand(template, func(name string) string {
+		value := os.Getenv(name)
+		if value == "" && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("environment variable %s is not set", missing)
+	}
+	return hostname, nil
+}
@@ -0,0 +1,83 @@
+package zbx_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestHostnameFromEnvironment(t *testing.T) {
+	t.Setenv("ZBX_HOSTNAME", "")
+	t.Setenv("POD_NAME", "web-5d8f7")
+	t.Setenv("NODE_NAME", "node-1")
+	t.Setenv("POD_NAMESPACE", "prod")
+
+	hostname, err := zbx.HostnameFromEnvironment("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if hostname != "web-5d8f7" {
+		t.Errorf("Unexpected hostname. Expected 'web-5d8f7' got '%s'", hostname)
+	}
+
+	hostname, err = zbx.HostnameFromEnvironment("${NODE_NAME}.$POD_NAMESPACE")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if hostname != "node-1.prod" {
+		t.Errorf("Unexpected hostname. Expected 'node-1.prod' got '%s'", hostname)
+	}
+
+	if _, err := zbx.HostnameFromEnvironment("${NODE_NAME}-${MISSING_VARIABLE}"); err == nil {
+		t.Errorf("No error seen for missing variable")
+	}
+
+	t.Setenv("POD_NAME", "web/5d8f7")
+	if _, err := zbx.HostnameFromEnvironment(""); err == nil {
+		t.Errorf("No error seen for invalid hostname")
+	}
+
+	t.Setenv("POD_NAME", "")
+	t.Setenv("NODE_NAME", "")
+	expected, _ := os.Hostname()
+	hostname, err = zbx.HostnameFromEnvironment("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if hostname != expected {
+		t.Errorf("Unexpected hostname. Expected '%s' got '%s'", expected, hostname)
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"agent",
+		"web-01.example.com",
+		"Zabbix server",
+		"host_name",
+		strings.Repeat("a", zbx.MaxHostnameLength),
+	}
+	for _, hostname := range valid {
+		if err := zbx.ValidateHostname(hostname); err != nil {
+			t.Errorf("Unexpected error for valid hostname '%s': %s", hostname, err.Error())
+		}
+	}
+
+	invalid := []string{
+		"",
+		strings.Repeat("a", zbx.MaxHostnameLength+1),
+		"web/01",
+		"host:10050",
+		"hôte",
+		"tab\there",
+	}
+	for _, hostname := range invalid {
+		if err := zbx.ValidateHostname(hostname); err == nil {
+			t.Errorf("No error seen for invalid hostname '%s'", hostname)
+		}
+	}
+}
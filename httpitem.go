@@ -0,0 +1,206 @@
+package zbx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHTTPItemTimeout is the timeout for requests made by HTTP items that do not specify one
+const DefaultHTTPItemTimeout = 10 * time.Second
+
+// maxHTTPItemResponse is the largest response accepted for an HTTP item
+const maxHTTPItemResponse = 16 * 1024 * 1024
+
+// HTTPItem describes an item with a value fetched from a URL, such as an internal status endpoint. The response
+// body is the value of the item, after the optional JSONPath and Regex are applied.
+type HTTPItem struct {
+	// Key is the item key
+	Key string `json:"key"`
+	// URL is the address that is requested with a GET request
+	URL string `json:"url"`
+	// Headers are added to the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// JSONPath is an optional JSONPath expression that extracts the value from a JSON response. See JSONPath.
+	JSONPath string `json:"jsonpath,omitempty"`
+	// Regex is an optional regular expression that extracts the value from the response, after JSONPath is applied
+	Regex string `json:"regex,omitempty"`
+	// Output is the value returned when Regex matches. See RegexExtract. Defaults to \0, the entire match.
+	Output string `json:"output,omitempty"`
+	// CacheFor is how long the value is used before the URL is requested again, such as "30s". When empty every
+	// request for the item requests the URL.
+	CacheFor string `json:"cache_for,omitempty"`
+	// Timeout is the maximum duration of the request, such as "5s". Defaults to DefaultHTTPItemTimeout.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+type httpItem struct {
+	HTTPItem
+	steps    []PreprocessingStep
+	cacheFor time.Duration
+	timeout  time.Duration
+
+	lock    sync.Mutex
+	value   interface{}
+	expires time.Time
+}
+
+// HTTPItems returns an ItemFunc that responds with values fetched by the given HTTP items, and passes requests for
+// any other key to next. If next is nil other keys are treated as unknown. Requests are made with client, or
+// http.DefaultClient if client is nil. A response with a status other than 2xx returns an error for the item. Errors
+// are not cached, and values are cached for the CacheFor of their item as measured by clock, or SystemClock if clock
+// is nil.
+//
+// An error is returned if a key is invalid or declared more than once, or if an item has an invalid URL, JSONPath,
+// Regex or duration.
+func HTTPItems(items []HTTPItem, client *http.Client, clock Clock, next ItemFunc) (ItemFunc, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	clock = clockOrSystem(clock)
+
+	byKey := map[string]*httpItem{}
+	for _, item := range items {
+		if err := ValidateKey(item.Key); err != nil {
+			return nil, fmt.Errorf("invalid http item '%s': %s", item.Key, err.Error())
+		}
+		if _, duplicate := byKey[item.Key]; duplicate {
+			return nil, fmt.Errorf("invalid http item '%s': key declared more than once", item.Key)
+		}
+		compiled, err := compileHTTPItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http item '%s': %s", item.Key, err.Error())
+		}
+		byKey[item.Key] = compiled
+	}
+
+	return func(key string) (interface{}, error) {
+		item, ok := byKey[key]
+		if !ok {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+		return item.get(client, clock)
+	}, nil
+}
+
+func compileHTTPItem(item HTTPItem) (compiled *httpItem, err error) {
+	compiled = &httpItem{HTTPItem: item, timeout: DefaultHTTPItemTimeout}
+
+	if request, err := http.NewRequest(http.MethodGet, item.URL, nil); err != nil || request.URL.Host == "" {
+		return nil, fmt.Errorf("invalid url '%s'", item.URL)
+	}
+	if item.CacheFor != "" {
+		if compiled.cacheFor, err = time.ParseDuration(item.CacheFor); err != nil {
+			return nil, fmt.Errorf("invalid cache_for: %s", err.Error())
+		}
+	}
+	if item.Timeout != "" {
+		if compiled.timeout, err = time.ParseDuration(item.Timeout); err != nil || compiled.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout '%s'", item.Timeout)
+		}
+	}
+
+	// JSONPath and RegexExtract panic on invalid input
+	defer func() {
+		if r := recover(); r != nil {
+			compiled = nil
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if item.JSONPath != "" {
+		compiled.steps = append(compiled.steps, JSONPath(item.JSONPath))
+	}
+	if item.Regex != "" {
+		output := item.Output
+		if output == "" {
+			output = `\0`
+		}
+		compiled.steps = append(compiled.steps, RegexExtract(item.Regex, output))
+	}
+	return compiled, nil
+}
+
+// get returns the cached value of the item, or requests the URL if the value has expired
+func (item *httpItem) get(client *http.Client, clock Clock) (interface{}, error) {
+	item.lock.Lock()
+	defer item.lock.Unlock()
+
+	if item.value != nil && clock.Now().Before(item.expires) {
+		return item.value, nil
+	}
+
+	body, err := item.fetch(client)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{} = body
+	for _, step := range item.steps {
+		value, err = step(item.Key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	item.value = value
+	item.expires = clock.Now().Add(item.cacheFor)
+	return value, nil
+}
+
+func (item *httpItem) fetch(client *http.Client) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), item.timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, item.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range item.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("error requesting url: %s", err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected response status: %s", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxHTTPItemResponse+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %s", err.Error())
+	}
+	if len(body) > maxHTTPItemResponse {
+		return "", ErrValueTooLarge
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// LoadHTTPItems reads HTTP items from the JSON file at fileName, which contains an array of items:
+//
+//	[
+//	    {"key": "queue.depth", "url": "http://localhost:8080/status", "jsonpath": "$.queue.depth", "cache_for": "30s"},
+//	    {"key": "build.id", "url": "http://localhost:8080/version", "regex": "build ([0-9]+)", "output": "\\1"}
+//	]
+func LoadHTTPItems(fileName string) ([]HTTPItem, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []HTTPItem{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid http items file '%s': %s", fileName, err.Error())
+	}
+	return items, nil
+}
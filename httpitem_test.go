@@ -0,0 +1,114 @@
+package zbx_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestHTTPItems(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/status":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `{"queue":{"depth":42,"name":"jobs"}}`)
+		case "/version":
+			fmt.Fprintf(w, "version 1.4.2 build 977\n")
+		case "/large":
+			w.Write(bytes.Repeat([]byte("a"), 16*1024*1024+1))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	fileName := filepath.Join(t.TempDir(), "items.json")
+	os.WriteFile(fileName, []byte(fmt.Sprintf(`[
+		{"key": "queue.depth", "url": "%[1]s/status", "headers": {"Authorization": "Bearer secret"}, "jsonpath": "$.queue.depth", "cache_for": "1m"},
+		{"key": "build.id", "url": "%[1]s/version", "regex": "build ([0-9]+)", "output": "\\1"},
+		{"key": "version", "url": "%[1]s/version"},
+		{"key": "missing", "url": "%[1]s/missing"},
+		{"key": "large", "url": "%[1]s/large"}
+	]`, server.URL)), 0644)
+	items, err := zbx.LoadHTTPItems(fileName)
+	if err != nil {
+		t.Fatalf("Error loading http items: %s", err.Error())
+	}
+	clock := newFakeClock()
+	itemFunc, err := zbx.HTTPItems(items, server.Client(), clock, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"queue.depth": "42",
+		"build.id":    "977",
+		"version":     "version 1.4.2 build 977",
+		"unknown":     nil,
+	}
+	for key, expectedValue := range expected {
+		value, err := itemFunc(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%v' got '%v'", key, expectedValue, value)
+		}
+	}
+	if _, err := itemFunc("missing"); err == nil {
+		t.Errorf("No error seen for 404 response")
+	}
+	if _, err := itemFunc("large"); err != zbx.ErrValueTooLarge {
+		t.Errorf("Unexpected error for oversized response: %v", err)
+	}
+
+	// queue.depth is cached, the others are not
+	before := atomic.LoadInt32(&requests)
+	itemFunc("queue.depth")
+	itemFunc("version")
+	if after := atomic.LoadInt32(&requests); after != before+1 {
+		t.Errorf("Unexpected number of requests. Expected %d got %d", before+1, after)
+	}
+
+	// queue.depth is requested again once it has been cached for a minute
+	before = atomic.LoadInt32(&requests)
+	clock.Advance(time.Minute)
+	itemFunc("queue.depth")
+	if after := atomic.LoadInt32(&requests); after != before+1 {
+		t.Errorf("Unexpected number of requests after expiry. Expected %d got %d", before+1, after)
+	}
+}
+
+func TestHTTPItemsInvalid(t *testing.T) {
+	t.Parallel()
+
+	invalid := [][]zbx.HTTPItem{
+		{{Key: "", URL: "http://localhost"}},
+		{{Key: "a", URL: "http://localhost"}, {Key: "a", URL: "http://localhost"}},
+		{{Key: "a", URL: "not a url"}},
+		{{Key: "a", URL: "http://localhost", JSONPath: "queue"}},
+		{{Key: "a", URL: "http://localhost", Regex: "("}},
+		{{Key: "a", URL: "http://localhost", CacheFor: "soon"}},
+		{{Key: "a", URL: "http://localhost", Timeout: "0s"}},
+	}
+	for _, items := range invalid {
+		if _, err := zbx.HTTPItems(items, nil, nil, nil); err == nil {
+			t.Errorf("No error seen for invalid items %+v", items)
+		}
+	}
+}
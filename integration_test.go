@@ -0,0 +1,164 @@
+//go:build integration
+// +build integration
+
+package zbx_test
+
+// The integration tests query the agent with zabbix_get from the official Zabbix agent Docker image, rather than
+// comparing frames against hand-computed fixtures. They require Docker and are run with:
+//
+//	go test -tags integration ./...
+//
+// The image can be changed with the ZBX_INTEGRATION_IMAGE environment variable.
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+const defaultIntegrationImage = "zabbix/zabbix-agent:alpine-6.4-latest"
+
+// zabbixGet runs zabbix_get from the Zabbix agent image with the given arguments and returns its output. Files in
+// mountDir, if not empty, are available to zabbix_get under /certs.
+func zabbixGet(t *testing.T, mountDir string, args ...string) string {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available")
+	}
+	image := os.Getenv("ZBX_INTEGRATION_IMAGE")
+	if image == "" {
+		image = defaultIntegrationImage
+	}
+
+	dockerArgs := []string{"run", "--rm", "--network", "host", "--entrypoint", "zabbix_get"}
+	if mountDir != "" {
+		dockerArgs = append(dockerArgs, "-v", mountDir+":/certs:ro")
+	}
+	dockerArgs = append(dockerArgs, image)
+	dockerArgs = append(dockerArgs, args...)
+
+	output, err := exec.Command("docker", dockerArgs...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error running zabbix_get: %s: %s", err.Error(), output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func integrationItems(key string) (interface{}, error) {
+	switch key {
+	case "agent.ping":
+		return 1, nil
+	case "generate.error":
+		return nil, fmt.Errorf("this is an error")
+	case "large":
+		return strings.Repeat("a", 1024*1024), nil
+	}
+	return nil, nil
+}
+
+func TestIntegrationPassive(t *testing.T) {
+	addr := startTestServer(t, &zbx.Server{ItemFunc: integrationItems})
+	host, port, _ := net.SplitHostPort(addr)
+
+	if output := zabbixGet(t, "", "-s", host, "-p", port, "-k", "agent.ping"); output != "1" {
+		t.Errorf("Unexpected output for agent.ping. Expected '1' got '%s'", output)
+	}
+	if output := zabbixGet(t, "", "-s", host, "-p", port, "-k", "generate.error"); !strings.Contains(output, "ZBX_NOTSUPPORTED") || !strings.Contains(output, "this is an error") {
+		t.Errorf("Unexpected output for generate.error: '%s'", output)
+	}
+	if output := zabbixGet(t, "", "-s", host, "-p", port, "-k", "unknown"); !strings.Contains(output, "ZBX_NOTSUPPORTED") {
+		t.Errorf("Unexpected output for unknown key: '%s'", output)
+	}
+	if output := zabbixGet(t, "", "-s", host, "-p", port, "-k", "large"); len(output) != 1024*1024 {
+		t.Errorf("Unexpected output length for large value. Expected %d got %d", 1024*1024, len(output))
+	}
+}
+
+func TestIntegrationTLS(t *testing.T) {
+	dir := t.TempDir()
+	// The container does not run as the current user
+	os.Chmod(dir, 0755)
+
+	caKey, caCert := integrationCertificate(t, nil, nil, "zbx test ca")
+	serverKey, serverCert := integrationCertificate(t, caKey, caCert, "zbx test agent")
+	clientKey, clientCert := integrationCertificate(t, caKey, caCert, "zbx test server")
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caCert.Raw)
+	writePEM(t, filepath.Join(dir, "client.pem"), "CERTIFICATE", clientCert.Raw)
+	clientKeyBytes, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("Error encoding key: %s", err.Error())
+	}
+	writePEM(t, filepath.Join(dir, "client.key"), "EC PRIVATE KEY", clientKeyBytes)
+
+	server := &zbx.Server{ItemFunc: integrationItems}
+	addr := startTestTLSServer(t, server, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+	})
+	host, port, _ := net.SplitHostPort(addr)
+
+	output := zabbixGet(t, dir, "-s", host, "-p", port, "-k", "agent.ping",
+		"--tls-connect", "cert",
+		"--tls-ca-file", "/certs/ca.pem",
+		"--tls-cert-file", "/certs/client.pem",
+		"--tls-key-file", "/certs/client.key",
+		"--tls-server-cert-subject", "CN=zbx test agent")
+	if output != "1" {
+		t.Errorf("Unexpected output for agent.ping. Expected '1' got '%s'", output)
+	}
+}
+
+// integrationCertificate returns a new key and certificate signed by parent, or a self-signed CA if parent is nil
+func integrationCertificate(t *testing.T, parentKey *ecdsa.PrivateKey, parent *x509.Certificate, commonName string) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("Error generating serial: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err.Error())
+	}
+	return key, certificate
+}
+
+func writePEM(t *testing.T, fileName string, blockType string, data []byte) {
+	if err := os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0644); err != nil {
+		t.Fatalf("Error writing %s: %s", fileName, err.Error())
+	}
+}
@@ -0,0 +1,83 @@
+package zbx
+
+import (
+	"time"
+)
+
+// Inventory describes information about the host that the Zabbix server can use to automatically populate the host
+// inventory. Each field is served under the standard item key noted with it, which is the key that templates link
+// to the inventory field. Empty fields are treated as unknown keys.
+type Inventory struct {
+	// Hostname is served as system.hostname, for the "Name" inventory field
+	Hostname string
+	// OS is served as system.sw.os, for the "OS" inventory field
+	OS string
+	// OSFull is served as system.sw.os[full], for the "OS (Full details)" inventory field
+	OSFull string
+	// OSShort is served as system.sw.os[short], for the "OS (Short)" inventory field
+	OSShort string
+	// Architecture is served as system.sw.arch, for the "HW architecture" inventory field
+	Architecture string
+	// Packages is served as system.sw.packages, for the "Software (Full details)" inventory field
+	Packages string
+	// Chassis is served as system.hw.chassis[full], for the "Hardware (Full details)" inventory field
+	Chassis string
+	// ChassisType is served as system.hw.chassis[type], for the "Type" inventory field
+	ChassisType string
+	// Vendor is served as system.hw.chassis[vendor], for the "Vendor" inventory field
+	Vendor string
+	// Model is served as system.hw.chassis[model], for the "Model" inventory field
+	Model string
+	// Serial is served as system.hw.chassis[serial], for the "Serial number A" inventory field
+	Serial string
+	// MACAddresses is served as system.hw.macaddr, for the "MAC address A" inventory field
+	MACAddresses string
+}
+
+// InventoryFunc describes a method that collects the inventory of the host
+type InventoryFunc func() (Inventory, error)
+
+// items returns the inventory as a map of item keys to values, omitting empty fields
+func (i Inventory) items() map[string]interface{} {
+	all := map[string]string{
+		"system.hostname":           i.Hostname,
+		"system.sw.os":              i.OS,
+		"system.sw.os[full]":        i.OSFull,
+		"system.sw.os[short]":       i.OSShort,
+		"system.sw.arch":            i.Architecture,
+		"system.sw.packages":        i.Packages,
+		"system.hw.chassis":         i.Chassis,
+		"system.hw.chassis[full]":   i.Chassis,
+		"system.hw.chassis[type]":   i.ChassisType,
+		"system.hw.chassis[vendor]": i.Vendor,
+		"system.hw.chassis[model]":  i.Model,
+		"system.hw.chassis[serial]": i.Serial,
+		"system.hw.macaddr":         i.MACAddresses,
+	}
+
+	items := map[string]interface{}{}
+	for key, value := range all {
+		if value != "" {
+			items[key] = value
+		}
+	}
+	return items
+}
+
+// InventoryItems returns an ItemFunc that serves the inventory returned by inventoryFunc under the standard item
+// keys described by Inventory. Inventory rarely changes, so the result of inventoryFunc is cached for the duration
+// of cacheFor. Use the item on the server with a long update interval, such as one hour, and set the item's
+// "Populates host inventory field" option.
+func InventoryItems(inventoryFunc InventoryFunc, cacheFor time.Duration) ItemFunc {
+	if inventoryFunc == nil {
+		panic("inventoryFunc is nil")
+	}
+
+	return BulkItem(func() (map[string]interface{}, error) {
+		inventory, err := inventoryFunc()
+		if err != nil {
+			return nil, err
+		}
+		return inventory.items(), nil
+	}, cacheFor, nil)
+}
@@ -0,0 +1,47 @@
+package zbx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestInventoryItems(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	itemFunc := zbx.InventoryItems(func() (zbx.Inventory, error) {
+		calls++
+		return zbx.Inventory{
+			Hostname:     "web-01",
+			OS:           "Linux version 6.1.0",
+			OSShort:      "Debian 12",
+			Architecture: "amd64",
+			Serial:       "ABC123",
+		}, nil
+	}, time.Hour)
+
+	expected := map[string]interface{}{
+		"system.hostname":           "web-01",
+		"system.sw.os":              "Linux version 6.1.0",
+		"system.sw.os[short]":       "Debian 12",
+		"system.sw.arch":            "amd64",
+		"system.hw.chassis[serial]": "ABC123",
+		"system.sw.os[full]":        nil,
+		"system.hw.macaddr":         nil,
+		"agent.ping":                nil,
+	}
+	for key, expectedValue := range expected {
+		value, err := itemFunc(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%v' got '%v'", key, expectedValue, value)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Unexpected number of inventory calls. Expected 1 got %d", calls)
+	}
+}
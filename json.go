@@ -0,0 +1,39 @@
+package zbx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MaxJSONValueLength is the maximum length of a JSON value that the Zabbix server will accept for an item of
+// type text, such as a master item used with JSONPath preprocessing.
+const MaxJSONValueLength = 16 * 1024 * 1024
+
+// ErrValueTooLarge is returned when an encoded item value exceeds the maximum length accepted by the server.
+var ErrValueTooLarge = fmt.Errorf("value exceeds maximum length")
+
+// JSON encodes v as JSON for use as an item value, such as the value of a master item whose dependent items
+// use JSONPath preprocessing. Maps are encoded with their keys sorted and structs with their fields in declaration
+// order, so the output for the same value is always the same. HTML characters are not escaped.
+//
+// An error is returned if v cannot be encoded or the encoded value is longer than MaxJSONValueLength.
+//
+// The result can be returned directly from an ItemFunc:
+//
+//	return zbx.JSON(stats)
+func JSON(v interface{}) (string, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+
+	// Encode always appends a newline
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if len(data) > MaxJSONValueLength {
+		return "", ErrValueTooLarge
+	}
+	return string(data), nil
+}
@@ -0,0 +1,48 @@
+package zbx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	type queue struct {
+		Name  string `json:"name"`
+		Depth int    `json:"depth"`
+	}
+
+	value, err := zbx.JSON(map[string]interface{}{
+		"queues": []queue{
+			{Name: "<default>", Depth: 4},
+		},
+		"connections": 10,
+		"agent":       "zbx",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := `{"agent":"zbx","connections":10,"queues":[{"name":"<default>","depth":4}]}`
+	if value != expected {
+		t.Errorf("Unexpected value. Expected:\n%s\nGot:\n%s", expected, value)
+	}
+}
+
+func TestJSONTooLarge(t *testing.T) {
+	t.Parallel()
+
+	if _, err := zbx.JSON(strings.Repeat("a", zbx.MaxJSONValueLength)); err != zbx.ErrValueTooLarge {
+		t.Errorf("Unexpected error. Expected '%v' got '%v'", zbx.ErrValueTooLarge, err)
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := zbx.JSON(make(chan int)); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+}
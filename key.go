@@ -0,0 +1,124 @@
+package zbx
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxKeyLength is the maximum number of characters in an item key, including any parameters
+const MaxKeyLength = 2048
+
+// MaxKeyNameLength is the maximum number of characters in the name of an item key, which is the portion before any
+// parameters
+const MaxKeyNameLength = 256
+
+// ValidateKey returns an error if key exceeds the length limits that Zabbix places on item keys. Requests for keys
+// that fail validation are answered with the error without calling the ItemFunc.
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("item key is empty")
+	}
+	if utf8.RuneCountInString(key) > MaxKeyLength {
+		return fmt.Errorf("item key is longer than %d characters", MaxKeyLength)
+	}
+	name := key
+	if i := strings.IndexByte(key, '['); i != -1 {
+		name = key[:i]
+	}
+	if utf8.RuneCountInString(name) > MaxKeyNameLength {
+		return fmt.Errorf("item key name is longer than %d characters", MaxKeyNameLength)
+	}
+	return nil
+}
+
+// ParseKey splits an item key into its name and parameters, following the Zabbix item key format. For example
+// `vfs.fs.size[/,"free"]` returns the name vfs.fs.size and the parameters "/" and "free". A key without parameters
+// returns nil parameters, and a key with empty brackets returns a single empty parameter.
+//
+// Parameters may be quoted with double quotes, in which case they can contain commas and brackets, and \" is a
+// literal quote. Array parameters, such as [a,b], are returned as their contents without the brackets. Leading spaces
+// of unquoted parameters are ignored.
+func ParseKey(key string) (string, []string, error) {
+	start := strings.IndexByte(key, '[')
+	if start == -1 {
+		if key == "" {
+			return "", nil, fmt.Errorf("invalid item key format: key is empty")
+		}
+		return key, nil, nil
+	}
+	name := key[:start]
+	if name == "" || !strings.HasSuffix(key, "]") {
+		return "", nil, fmt.Errorf("invalid item key format: '%s'", key)
+	}
+
+	params := []string{}
+	remaining := key[start+1 : len(key)-1]
+	for {
+		remaining = strings.TrimLeft(remaining, " ")
+
+		var param string
+		switch {
+		case strings.HasPrefix(remaining, "\""):
+			end := 1
+			value := &strings.Builder{}
+			for ; end < len(remaining) && remaining[end] != '"'; end++ {
+				if remaining[end] == '\\' && end+1 < len(remaining) && remaining[end+1] == '"' {
+					end++
+				}
+				value.WriteByte(remaining[end])
+			}
+			if end >= len(remaining) {
+				return "", nil, fmt.Errorf("invalid item key format: unterminated quoted parameter in '%s'", key)
+			}
+			param = value.String()
+			remaining = strings.TrimLeft(remaining[end+1:], " ")
+			if remaining != "" && remaining[0] != ',' {
+				return "", nil, fmt.Errorf("invalid item key format: unexpected character after quoted parameter in '%s'", key)
+			}
+		case strings.HasPrefix(remaining, "["):
+			end := arrayEnd(remaining)
+			if end == -1 {
+				return "", nil, fmt.Errorf("invalid item key format: unterminated array parameter in '%s'", key)
+			}
+			param = remaining[1:end]
+			remaining = strings.TrimLeft(remaining[end+1:], " ")
+			if remaining != "" && remaining[0] != ',' {
+				return "", nil, fmt.Errorf("invalid item key format: unexpected character after array parameter in '%s'", key)
+			}
+		default:
+			end := strings.IndexByte(remaining, ',')
+			if end == -1 {
+				end = len(remaining)
+			}
+			param = remaining[:end]
+			if strings.ContainsAny(param, "[]") {
+				return "", nil, fmt.Errorf("invalid item key format: unquoted parameter contains a bracket in '%s'", key)
+			}
+			remaining = remaining[end:]
+		}
+
+		params = append(params, param)
+		if remaining == "" {
+			return name, params, nil
+		}
+		// Skip the comma
+		remaining = remaining[1:]
+	}
+}
+
+// arrayEnd returns the index of the bracket that closes the array at the start of s, or -1
+func arrayEnd(s string) int {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\' && i+1 < len(s) && s[i+1] == '"':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,114 @@
+package zbx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestValidateKey(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"agent.ping",
+		"vfs.fs.size[/,free]",
+		strings.Repeat("a", zbx.MaxKeyNameLength),
+		strings.Repeat("é", zbx.MaxKeyNameLength),
+		"key[" + strings.Repeat("a", zbx.MaxKeyLength-5) + "]",
+	}
+	for _, key := range valid {
+		if err := zbx.ValidateKey(key); err != nil {
+			t.Errorf("Unexpected error for valid key '%.20s...': %s", key, err.Error())
+		}
+	}
+
+	invalid := []string{
+		"",
+		strings.Repeat("a", zbx.MaxKeyNameLength+1),
+		strings.Repeat("a", zbx.MaxKeyNameLength+1) + "[1]",
+		"key[" + strings.Repeat("a", zbx.MaxKeyLength-4) + "]",
+	}
+	for _, key := range invalid {
+		if err := zbx.ValidateKey(key); err == nil {
+			t.Errorf("No error seen for invalid key '%.20s...'", key)
+		}
+	}
+}
+
+func TestLongKeyRejected(t *testing.T) {
+	t.Parallel()
+
+	called := make(chan string, 1)
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			called <- key
+			return 1, nil
+		},
+	})
+
+	reply := queryKey(t, addr, strings.Repeat("a", zbx.MaxKeyNameLength+1))
+	expected := "ZBX_NOTSUPPORTED\x00item key name is longer than 256 characters"
+	if reply != expected {
+		t.Errorf("Unexpected reply. Expected '%q' got '%q'", expected, reply)
+	}
+	select {
+	case key := <-called:
+		t.Errorf("ItemFunc called for invalid key '%.20s...'", key)
+	default:
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	t.Parallel()
+
+	type parsed struct {
+		name   string
+		params []string
+	}
+	valid := map[string]parsed{
+		"agent.ping":                     {"agent.ping", nil},
+		"key[]":                          {"key", []string{""}},
+		"vfs.fs.size[/,free]":            {"vfs.fs.size", []string{"/", "free"}},
+		`vfs.fs.size["/", "free"]`:       {"vfs.fs.size", []string{"/", "free"}},
+		`log["a,b]",,  c]`:               {"log", []string{"a,b]", "", "c"}},
+		`key["say \"hi\""]`:              {"key", []string{`say "hi"`}},
+		"net.if.in[[eth0,eth1],bytes]":   {"net.if.in", []string{"eth0,eth1", "bytes"}},
+		`system.sw.packages[^lib,dpkg,]`: {"system.sw.packages", []string{"^lib", "dpkg", ""}},
+	}
+	for key, expected := range valid {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+			continue
+		}
+		if name != expected.name {
+			t.Errorf("Unexpected name for '%s'. Expected '%s' got '%s'", key, expected.name, name)
+		}
+		if len(params) != len(expected.params) || (params == nil) != (expected.params == nil) {
+			t.Errorf("Unexpected params for '%s'. Expected %q got %q", key, expected.params, params)
+			continue
+		}
+		for i := range params {
+			if params[i] != expected.params[i] {
+				t.Errorf("Unexpected params for '%s'. Expected %q got %q", key, expected.params, params)
+				break
+			}
+		}
+	}
+
+	invalid := []string{
+		"",
+		"[a]",
+		"key[a",
+		`key["a]`,
+		`key["a"b]`,
+		"key[a]b]",
+		"key[[a,b]",
+	}
+	for _, key := range invalid {
+		if _, _, err := zbx.ParseKey(key); err == nil {
+			t.Errorf("No error seen for invalid key '%s'", key)
+		}
+	}
+}
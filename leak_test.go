@@ -0,0 +1,195 @@
+package zbx_test
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
+	"go.uber.org/goleak"
+)
+
+// These tests are not parallel, so that goroutines started by other tests are not mistaken for leaks. Goroutines are
+// checked while the client side of connections is still open, as closing it would end them regardless of the server.
+
+// serveUntilClosed starts server with serve, waits for it to be ready, and returns a channel that receives the error
+// returned by serve once the server is closed
+func serveUntilClosed(t *testing.T, server *zbx.Server, serve func() error) <-chan error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve()
+	}()
+	select {
+	case <-server.Ready():
+	case err := <-errs:
+		t.Fatalf("Error starting server: %s", err.Error())
+	}
+	return errs
+}
+
+func closeAndWait(t *testing.T, server *zbx.Server, errs <-chan error) {
+	if err := server.Close(); err != nil {
+		t.Errorf("Error closing server: %s", err.Error())
+	}
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Server did not return after being closed")
+	}
+}
+
+func TestCloseLeak(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		ConnectionIdleTimeout: time.Minute,
+	}
+	errs := serveUntilClosed(t, server, func() error {
+		return server.ListenAndServe("127.0.0.1:0")
+	})
+
+	// A persistent connection waiting for its next request
+	persistent, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer persistent.Close()
+	if err := zbxproto.WriteMessage(persistent, []byte("agent.ping"), nil); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	if _, err := zbxproto.ReadMessage(persistent, nil); err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+
+	// A connection that never sends a request
+	silent, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer silent.Close()
+
+	closeAndWait(t, server, errs)
+	goleak.VerifyNone(t, ignore)
+
+	// Closed connections are seen by the client
+	persistent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := persistent.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Persistent connection was not closed")
+	}
+}
+
+func TestCloseLeakTLS(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		ConnectionIdleTimeout: time.Minute,
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}}
+	errs := serveUntilClosed(t, server, func() error {
+		return server.ListenAndServeTLSConfig("127.0.0.1:0", config)
+	})
+
+	c, err := tls.Dial("tcp", server.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if err := zbxproto.WriteMessage(c, []byte("agent.ping"), nil); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	if _, err := zbxproto.ReadMessage(c, nil); err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+
+	closeAndWait(t, server, errs)
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestCloseLeakInFlight(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			close(started)
+			<-release
+			return 1, nil
+		},
+	}
+	errs := serveUntilClosed(t, server, func() error {
+		return server.ListenAndServe("127.0.0.1:0")
+	})
+
+	c, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if err := zbxproto.WriteMessage(c, []byte("agent.ping"), nil); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	<-started
+
+	closeAndWait(t, server, errs)
+	close(release)
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestCloseLeakMemoryWait(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			if key == "block" {
+				close(started)
+				<-release
+			}
+			return 1, nil
+		},
+		MaxInFlightMemory: 5,
+	}
+	errs := serveUntilClosed(t, server, func() error {
+		return server.ListenAndServe("127.0.0.1:0")
+	})
+
+	// Holds all of the memory until released, which does not happen when the server is closed
+	blocked, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer blocked.Close()
+	if err := zbxproto.WriteMessage(blocked, []byte("block"), nil); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	<-started
+	defer close(release)
+	ignore := goleak.IgnoreCurrent()
+
+	// Waits for the memory of the blocked request until the server is closed
+	c, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if err := zbxproto.WriteMessage(c, []byte("agent"), nil); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	for i := 0; i < 100 && server.Stats().MemoryWaits == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.Stats().MemoryWaits == 0 {
+		t.Fatalf("Request did not wait for memory")
+	}
+
+	closeAndWait(t, server, errs)
+	goleak.VerifyNone(t, ignore)
+}
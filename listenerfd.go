@@ -0,0 +1,35 @@
+package zbx
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// ListenerFromFD returns a listener for the listening socket with the file descriptor fd, which was opened by another
+// process or before the process dropped its privileges, such as a socket passed in by systemd socket activation
+// (which starts at descriptor 3) or by a supervisor. The listener uses a duplicate of the descriptor, and fd is closed
+// once the listener has been created. Not supported on Windows.
+func ListenerFromFD(fd uintptr) (net.Listener, error) {
+	file := os.NewFile(fd, fmt.Sprintf("fd%d", fd))
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer file.Close()
+
+	l, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not a listening socket: %s", fd, err.Error())
+	}
+	return l, nil
+}
+
+// ServeFD starts the Zabbix agent on the listening socket with the file descriptor fd. See ListenerFromFD and Serve
+// for details.
+func (s *Server) ServeFD(fd uintptr) error {
+	l, err := ListenerFromFD(fd)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+// +build !windows
+
+package zbx_test
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestServeFD(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	addr := l.Addr().String()
+	file, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("Error getting listener file: %s", err.Error())
+	}
+	// Pass a descriptor that is not owned by an *os.File, as ListenerFromFD closes it
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("Error duplicating listener file: %s", err.Error())
+	}
+	file.Close()
+	l.Close()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return "1", nil
+		},
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ServeFD(uintptr(fd))
+	}()
+	select {
+	case <-server.Ready():
+	case err := <-served:
+		t.Fatalf("Error serving file descriptor: %v", err)
+	}
+	defer func() {
+		server.Close()
+		<-served
+	}()
+
+	if reply := queryKey(t, addr, "agent.ping"); reply != "1" {
+		t.Errorf("Unexpected reply. Expected '1' got '%s'", reply)
+	}
+}
+
+func TestListenerFromFDNotSocket(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "zbx")
+	if err != nil {
+		t.Fatalf("Error creating file: %s", err.Error())
+	}
+	defer file.Close()
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("Error duplicating file: %s", err.Error())
+	}
+
+	if _, err := zbx.ListenerFromFD(uintptr(fd)); err == nil {
+		t.Errorf("No error seen for file descriptor that is not a socket")
+	}
+}
@@ -0,0 +1,44 @@
+package zbx
+
+import "fmt"
+
+// ErrMaintenance is sent to the server for every key while the server is in maintenance mode, unless the server has a
+// MaintenanceMessage. Use errors.Is to check for it, as the message may differ.
+var ErrMaintenance = fmt.Errorf("agent in maintenance")
+
+type maintenanceMessage string
+
+func (e maintenanceMessage) Error() string {
+	return string(e)
+}
+
+func (e maintenanceMessage) Is(target error) bool {
+	return target == ErrMaintenance
+}
+
+// SetMaintenance enables or disables maintenance mode. While in maintenance mode the server answers every request
+// with ErrMaintenance, or the MaintenanceMessage, instead of calling the ItemFunc, so that the host can be drained
+// during a deploy while still accepting connections. These errors are not counted or logged as item errors.
+func (s *Server) SetMaintenance(maintenance bool) {
+	s.maintenanceLock.Lock()
+	s.maintenance = maintenance
+	s.maintenanceLock.Unlock()
+}
+
+// Maintenance returns true if the server is in maintenance mode
+func (s *Server) Maintenance() bool {
+	s.maintenanceLock.Lock()
+	defer s.maintenanceLock.Unlock()
+	return s.maintenance
+}
+
+// maintenanceError returns the error to send for every key if the server is in maintenance mode, otherwise nil
+func (s *Server) maintenanceError() error {
+	if !s.Maintenance() {
+		return nil
+	}
+	if s.MaintenanceMessage != "" {
+		return maintenanceMessage(s.MaintenanceMessage)
+	}
+	return ErrMaintenance
+}
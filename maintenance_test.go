@@ -0,0 +1,64 @@
+package zbx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			calls++
+			return 1, nil
+		},
+	}
+	addr := startTestServer(t, server)
+
+	if value := queryKey(t, addr, "agent.ping"); value != "1" {
+		t.Errorf("Unexpected value before maintenance '%s'", value)
+	}
+
+	server.SetMaintenance(true)
+	if !server.Maintenance() || !server.Health().Maintenance {
+		t.Errorf("Server not reported as in maintenance")
+	}
+	if value := queryKey(t, addr, "agent.ping"); value != "ZBX_NOTSUPPORTED\x00agent in maintenance" {
+		t.Errorf("Unexpected value during maintenance %q", value)
+	}
+	if stats := server.Stats(); stats.ItemErrors != 0 {
+		t.Errorf("Maintenance errors counted as item errors: %d", stats.ItemErrors)
+	}
+
+	server.SetMaintenance(false)
+	if value := queryKey(t, addr, "agent.ping"); value != "1" {
+		t.Errorf("Unexpected value after maintenance '%s'", value)
+	}
+	if calls != 2 {
+		t.Errorf("Unexpected number of calls. Expected 2 got %d", calls)
+	}
+}
+
+func TestMaintenanceMessage(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		MaintenanceMessage: "deploying, back soon",
+	}
+	server.SetMaintenance(true)
+
+	result := server.Validate([]string{"agent.ping"})[0]
+	if result.Status != zbx.ValidationError || result.Error.Error() != "deploying, back soon" {
+		t.Errorf("Unexpected result during maintenance: %+v", result)
+	}
+	if !errors.Is(result.Error, zbx.ErrMaintenance) {
+		t.Errorf("Maintenance error is not ErrMaintenance")
+	}
+}
@@ -0,0 +1,227 @@
+// Package memcached provides the memcached.ping and memcached.stats items used by the "Memcached by Zabbix agent 2"
+// template, using a client for the text protocol of memcached.
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// queryTimeout is how long a command can take, which is below the longest item timeout of the Zabbix server
+const queryTimeout = 10 * time.Second
+
+// statsTypes are the types of memcached.stats, which are sent to the server as the argument of the stats command
+var statsTypes = map[string]bool{
+	"":         true,
+	"items":    true,
+	"slabs":    true,
+	"sizes":    true,
+	"settings": true,
+}
+
+// Client describes a memcached server. Idle connections are kept for reuse by later requests.
+type Client struct {
+	// Address is the host and port of the server, such as "127.0.0.1:11211"
+	Address string
+	// MaxIdle is the number of idle connections that are kept, 2 if zero
+	MaxIdle int
+
+	lock sync.Mutex
+	idle []net.Conn
+}
+
+// Version returns the version of the server
+func (c *Client) Version() (string, error) {
+	var version string
+	err := c.do("version", func(line string) (bool, error) {
+		if !strings.HasPrefix(line, "VERSION ") {
+			return false, fmt.Errorf("unexpected reply '%s'", line)
+		}
+		version = strings.TrimPrefix(line, "VERSION ")
+		return true, nil
+	})
+	return version, err
+}
+
+// Stats returns the statistics of statsType, which is one of "", "items", "slabs", "sizes" or "settings"
+func (c *Client) Stats(statsType string) (map[string]string, error) {
+	if !statsTypes[statsType] {
+		return nil, fmt.Errorf("unknown stats type '%s'", statsType)
+	}
+
+	stats := map[string]string{}
+	err := c.do(strings.TrimSpace("stats "+statsType), func(line string) (bool, error) {
+		if line == "END" {
+			return true, nil
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			return false, fmt.Errorf("unexpected reply '%s'", line)
+		}
+		stats[fields[1]] = fields[2]
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Close closes the idle connections
+func (c *Client) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, conn := range c.idle {
+		conn.Close()
+	}
+	c.idle = nil
+}
+
+// do sends command to the server, and calls read for each line of the reply until it returns true or an error. A
+// connection that was idle may have been closed by the server, so the command is retried once on a new connection.
+func (c *Client) do(command string, read func(line string) (bool, error)) error {
+	conn, reused := c.getConn()
+	err := c.doConn(conn, command, read)
+	if err != nil && reused {
+		if _, ok := err.(replyError); !ok {
+			err = c.doConn(nil, command, read)
+		}
+	}
+	return err
+}
+
+// replyError is an error reply from the server, after which the connection can still be used
+type replyError string
+
+func (e replyError) Error() string {
+	return string(e)
+}
+
+// doConn sends command on conn, or on a new connection if conn is nil. Conn is kept for reuse if the reply was read
+// without error.
+func (c *Client) doConn(conn net.Conn, command string, read func(line string) (bool, error)) error {
+	if conn == nil {
+		var err error
+		if conn, err = c.dial(); err != nil {
+			return err
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+	if _, err := conn.Write([]byte(command + "\r\n")); err != nil {
+		conn.Close()
+		return fmt.Errorf("error sending command: %s", err.Error())
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("error reading reply: %s", err.Error())
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR ") || strings.HasPrefix(line, "SERVER_ERROR ") {
+			c.putConn(conn, reader)
+			return replyError(line)
+		}
+		done, err := read(line)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if done {
+			c.putConn(conn, reader)
+			return nil
+		}
+	}
+}
+
+// getConn returns an idle connection, or nil if there are none
+func (c *Client) getConn() (net.Conn, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.idle) == 0 {
+		return nil, false
+	}
+	conn := c.idle[len(c.idle)-1]
+	c.idle = c.idle[:len(c.idle)-1]
+	return conn, true
+}
+
+// putConn keeps conn for reuse if there is room and nothing is left unread, otherwise it is closed
+func (c *Client) putConn(conn net.Conn, reader *bufio.Reader) {
+	maxIdle := c.MaxIdle
+	if maxIdle == 0 {
+		maxIdle = 2
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if reader.Buffered() > 0 || len(c.idle) >= maxIdle {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", c.Address, queryTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to '%s': %s", c.Address, err.Error())
+	}
+	return conn, nil
+}
+
+// Items returns an ItemFunc for the memcached.* keys below, using the one of clients named by the session parameter.
+// Anything else, such as another key or one that cannot be parsed, is handled by next, or is unknown if next is nil.
+//
+//   - memcached.ping[<session>,<user>,<password>], 1 if the server is reachable, otherwise 0.
+//   - memcached.stats[<session>,<user>,<password>,<type>], a JSON object of the statistics of type, which is one of
+//     items, slabs, sizes or settings, or the general statistics if type is empty.
+//
+// Memcached has no authentication in its text protocol, so the user and password parameters are ignored.
+func Items(clients map[string]*Client, next zbx.ItemFunc) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil || (name != "memcached.ping" && name != "memcached.stats") {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		if len(params) == 0 || params[0] == "" {
+			return nil, fmt.Errorf("missing session")
+		}
+		if (name == "memcached.ping" && len(params) > 3) || len(params) > 4 {
+			return nil, fmt.Errorf("too many parameters")
+		}
+		client, ok := clients[params[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown session '%s'", params[0])
+		}
+
+		if name == "memcached.ping" {
+			if _, err := client.Version(); err != nil {
+				return 0, nil
+			}
+			return 1, nil
+		}
+
+		statsType := ""
+		if len(params) == 4 {
+			statsType = params[3]
+		}
+		stats, err := client.Stats(statsType)
+		if err != nil {
+			return nil, fmt.Errorf("error getting stats: %s", err.Error())
+		}
+		return zbx.JSON(stats)
+	}
+}
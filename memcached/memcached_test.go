@@ -0,0 +1,176 @@
+package memcached_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ecnepsnai/zbx/memcached"
+)
+
+// startTestServer starts a server that replies to version and stats like memcached, and returns its address and the
+// number of connections it has accepted. If hangUp is true each connection is closed after its first reply.
+func startTestServer(t *testing.T, hangUp bool) (string, *int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	connections := new(int32)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(connections, 1)
+			go serveTestConn(conn, hangUp)
+		}
+	}()
+	return l.Addr().String(), connections
+}
+
+func serveTestConn(conn net.Conn, hangUp bool) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch strings.TrimSpace(line) {
+		case "version":
+			io.WriteString(conn, "VERSION 1.6.21\r\n")
+		case "stats":
+			io.WriteString(conn, "STAT pid 1\r\nSTAT uptime 3600\r\nSTAT version 1.6.21\r\nEND\r\n")
+		case "stats items":
+			io.WriteString(conn, "STAT items:1:number 5\r\nSTAT items:1:age 120\r\nEND\r\n")
+		case "stats sizes":
+			io.WriteString(conn, "STAT sizes_status disabled\r\nEND\r\n")
+		default:
+			io.WriteString(conn, "ERROR\r\n")
+		}
+		if hangUp {
+			return
+		}
+	}
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	address, connections := startTestServer(t, false)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	closed.Close()
+
+	clients := map[string]*memcached.Client{
+		"primary": {Address: address},
+		"down":    {Address: closed.Addr().String()},
+	}
+	defer clients["primary"].Close()
+	items := memcached.Items(clients, func(key string) (interface{}, error) {
+		if key == "agent.ping" {
+			return 1, nil
+		}
+		return nil, nil
+	})
+
+	expected := []struct {
+		key   string
+		value interface{}
+	}{
+		{`memcached.ping[primary,"{$MEMCACHED.USER}","{$MEMCACHED.PASSWORD}"]`, 1},
+		{"memcached.ping[down]", 0},
+		{"memcached.stats[primary]", `{"pid":"1","uptime":"3600","version":"1.6.21"}`},
+		{"memcached.stats[primary,,,items]", `{"items:1:age":"120","items:1:number":"5"}`},
+		{"memcached.stats[primary,,,sizes]", `{"sizes_status":"disabled"}`},
+		{"agent.ping", 1},
+	}
+	for _, test := range expected {
+		value, err := items(test.key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", test.key, err.Error())
+		} else if value != test.value {
+			t.Errorf("Unexpected value for '%s'. Expected %v got %v", test.key, test.value, value)
+		}
+	}
+
+	// Connections are returned to the pool and reused by later requests
+	if count := atomic.LoadInt32(connections); count != 1 {
+		t.Errorf("Unexpected number of connections. Expected 1 got %d", count)
+	}
+}
+
+func TestItemsInvalid(t *testing.T) {
+	t.Parallel()
+
+	address, _ := startTestServer(t, false)
+	client := &memcached.Client{Address: address}
+	defer client.Close()
+	items := memcached.Items(map[string]*memcached.Client{"primary": client}, nil)
+
+	for _, key := range []string{
+		"memcached.stats",
+		"memcached.stats[replica]",
+		"memcached.ping[primary,,,extra]",
+		"memcached.stats[primary,,,items,extra]",
+		"memcached.stats[primary,,,detail]",
+		"memcached.stats[\"primary\",,,\"items\r\nflush_all\"]",
+	} {
+		if _, err := items(key); err == nil {
+			t.Errorf("No error seen for '%s'", key)
+		}
+	}
+	if value, err := items("agent.ping"); value != nil || err != nil {
+		t.Errorf("Unexpected result for unknown key: %v %v", value, err)
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	t.Parallel()
+
+	address, connections := startTestServer(t, true)
+	client := &memcached.Client{Address: address}
+	defer client.Close()
+
+	// The idle connection from each request has been closed by the server when it is reused
+	for i := 0; i < 3; i++ {
+		version, err := client.Version()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if version != "1.6.21" {
+			t.Errorf("Unexpected version. Expected '1.6.21' got '%s'", version)
+		}
+	}
+	if count := atomic.LoadInt32(connections); count != 3 {
+		t.Errorf("Unexpected number of connections. Expected 3 got %d", count)
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := memcached.Items(map[string]*memcached.Client{}, func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	})
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := memcached.Items(map[string]*memcached.Client{}, nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
@@ -0,0 +1,111 @@
+package zbx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+var (
+	// errMemoryLimit is returned by acquireMemory for sizes larger than MaxInFlightMemory
+	errMemoryLimit = errors.New("larger than the in-flight memory limit")
+	// errMemoryTimeout is returned by acquireMemory if memory was not released in time
+	errMemoryTimeout = errors.New("timed out waiting for in-flight memory")
+	// errMemoryClosed is returned by acquireMemory if the server was closed while waiting
+	errMemoryClosed = errors.New("server closed while waiting for in-flight memory")
+)
+
+// acquireMemory reserves n bytes of the MaxInFlightMemory of the server, waiting until enough has been released by
+// other connections. Returns errMemoryLimit without waiting if n is larger than MaxInFlightMemory, as it could never be
+// reserved, errMemoryTimeout if the memory was not released before deadline, or errMemoryClosed if the server was
+// closed while waiting. Always succeeds if the server does not have a memory limit.
+func (s *Server) acquireMemory(n int64, deadline time.Time) error {
+	if s.MaxInFlightMemory <= 0 || n <= 0 {
+		return nil
+	}
+	if n > s.MaxInFlightMemory {
+		return errMemoryLimit
+	}
+
+	s.memoryLock.Lock()
+	defer s.memoryLock.Unlock()
+
+	var timer *time.Timer
+	for s.memoryInUse+n > s.MaxInFlightMemory {
+		if s.isClosing() {
+			return errMemoryClosed
+		}
+		if !time.Now().Before(deadline) {
+			return errMemoryTimeout
+		}
+		if timer == nil {
+			s.updateStats(func(stats *Stats) {
+				stats.MemoryWaits++
+			})
+			// Wake up the waiters once the deadline has passed, as the condition cannot time out by itself
+			timer = time.AfterFunc(time.Until(deadline), s.wakeMemoryWaiters)
+			defer timer.Stop()
+		}
+		s.memoryCond().Wait()
+	}
+	s.memoryInUse += n
+	return nil
+}
+
+// wakeMemoryWaiters wakes up connections waiting in acquireMemory, so that they check if they should stop waiting
+func (s *Server) wakeMemoryWaiters() {
+	s.memoryLock.Lock()
+	s.memoryCond().Broadcast()
+	s.memoryLock.Unlock()
+}
+
+// writeReply writes reply to w once memory for it has been reserved, sending ErrValueTooLarge instead if the reply is
+// larger than MaxInFlightMemory. Returns an error without writing if the memory could not be reserved in time. Reply
+// has already been allocated, so this limits how many replies are being written at once rather than their allocation.
+func (s *Server) writeReply(w io.Writer, reply []byte, who string) (int64, error) {
+	err := s.acquireMemory(int64(len(reply)), time.Now().Add(s.requestTimeout()))
+	if err == errMemoryLimit {
+		peerErrorWrite(who, "Rejecting oversized reply: %s,%s", fmt.Sprintf("max_in_flight_memory=%d", s.MaxInFlightMemory), fmt.Sprintf("reply_size=%d", len(reply)))
+		var options *zbxproto.Options
+		if len(reply) > 4 && reply[4]&zbxproto.FlagLargePacket != 0 {
+			options = &zbxproto.Options{LargePacket: true}
+		}
+		replacement, err := zbxproto.Encode([]byte("ZBX_NOTSUPPORTED\x00"+ErrValueTooLarge.Error()), options)
+		if err != nil {
+			return 0, err
+		}
+		reply = replacement
+	} else if err != nil {
+		return 0, err
+	} else {
+		defer s.releaseMemory(int64(len(reply)))
+	}
+
+	n, err := w.Write(reply)
+	return int64(n), err
+}
+
+// releaseMemory returns n bytes reserved with acquireMemory
+func (s *Server) releaseMemory(n int64) {
+	if s.MaxInFlightMemory <= 0 || n <= 0 {
+		return
+	}
+
+	s.memoryLock.Lock()
+	s.memoryInUse -= n
+	s.memoryCond().Broadcast()
+	s.memoryLock.Unlock()
+}
+
+// memoryCond returns the condition that is signalled when memory is released, creating it if needed. The caller must
+// hold memoryLock.
+func (s *Server) memoryCond() *sync.Cond {
+	if s.memoryReleased == nil {
+		s.memoryReleased = sync.NewCond(&s.memoryLock)
+	}
+	return s.memoryReleased
+}
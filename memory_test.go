@@ -0,0 +1,152 @@
+package zbx_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// assertRejected checks that the agent at addr does not reply to a request for key
+func assertRejected(t *testing.T, addr string, key string) {
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if _, err := c.Write(requestForKey(key)); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	if reply, err := io.ReadAll(c); err == nil && len(reply) > 0 {
+		t.Errorf("Unexpected reply for rejected request: %q", reply)
+	}
+}
+
+func TestMaxRequestSize(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		MaxRequestSize: 10,
+	})
+
+	if value := queryKey(t, addr, "agent.ping"); value != "1" {
+		t.Errorf("Unexpected value '%s'", value)
+	}
+	assertRejected(t, addr, "agent.ping2")
+}
+
+func TestMaxInFlightMemory(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch {
+			case key == "large.value":
+				return strings.Repeat("x", 200), nil
+			case strings.HasPrefix(key, "block"):
+				started <- struct{}{}
+				<-release
+				return 1, nil
+			}
+			return nil, nil
+		},
+		MaxInFlightMemory: 100,
+	}
+	addr := startTestServer(t, server)
+
+	if value := queryKey(t, addr, "large.value"); value != "ZBX_NOTSUPPORTED\x00"+zbx.ErrValueTooLarge.Error() {
+		t.Errorf("Unexpected reply for large value '%s'", value)
+	}
+	assertRejected(t, addr, strings.Repeat("k", 101))
+
+	// The second request waits for the memory held by the first
+	key := "block[" + strings.Repeat("k", 54) + "]"
+	values := make(chan string, 2)
+	go func() {
+		values <- queryKey(t, addr, key)
+	}()
+	<-started
+	go func() {
+		values <- queryKey(t, addr, key)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Stats().MemoryWaits == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Request did not wait for memory")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-started:
+		t.Fatalf("Request started without waiting for memory")
+	default:
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if value := <-values; value != "1" {
+			t.Errorf("Unexpected value '%s'", value)
+		}
+	}
+}
+
+// Ensure that a request whose data never arrives only holds its memory until RequestTimeout
+func TestStalledRequestMemory(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		MaxInFlightMemory: 100,
+		RequestTimeout:    500 * time.Millisecond,
+	}
+	addr := startTestServer(t, server)
+
+	// Claims all of the memory, then never sends the data
+	stalled, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer stalled.Close()
+	header, _ := zbxproto.EncodeHeader(100, nil)
+	if _, err := stalled.Write(header); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if _, err := c.Write(requestForKey("agent.ping")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := zbxproto.ReadMessage(c, nil)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	if string(reply.Data) != "1" {
+		t.Errorf("Unexpected value '%s'", reply.Data)
+	}
+	if server.Stats().MemoryWaits == 0 {
+		t.Errorf("Request did not wait for memory")
+	}
+
+	// The stalled connection is closed once its request times out
+	stalled.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stalled.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Stalled connection not closed, read returned: %v", err)
+	}
+}
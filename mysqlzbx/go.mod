@@ -0,0 +1,9 @@
+module github.com/ecnepsnai/zbx/mysqlzbx
+
+go 1.17
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/ecnepsnai/zbx v0.0.0-20261016123541-ef93aff01069
+	github.com/go-sql-driver/mysql v1.7.1
+)
@@ -0,0 +1,157 @@
+// Package mysqlzbx provides the mysql.* items used by the "MySQL by Zabbix agent 2" template, for MySQL and MariaDB
+// servers.
+//
+// It is a separate module so that the zbx package itself does not depend on a MySQL driver.
+package mysqlzbx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+	// Registers the mysql driver used by Open
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// queryTimeout is how long a query can take, which is below the longest item timeout of the Zabbix server
+const queryTimeout = 10 * time.Second
+
+// Open opens a database for each of the data source names in dsns, keyed by session name, for use with Items. Data
+// source names use the format of github.com/go-sql-driver/mysql, such as "zabbix:password@tcp(127.0.0.1:3306)/".
+// Connections are made when needed, and pooled by each database.
+func Open(dsns map[string]string) (map[string]*sql.DB, error) {
+	databases := map[string]*sql.DB{}
+	for session, dsn := range dsns {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			for _, db := range databases {
+				db.Close()
+			}
+			return nil, fmt.Errorf("invalid data source name for session '%s': %s", session, err.Error())
+		}
+		databases[session] = db
+	}
+	return databases, nil
+}
+
+// Items returns an ItemFunc for the mysql.* keys below, which are answered by the one of databases named by their
+// session parameter. Keys it does not serve, or cannot parse, are passed to next, or are unknown if next is nil.
+//
+//   - mysql.ping[<session>], 1 if the server is reachable, otherwise 0.
+//   - mysql.version[<session>], the version of the server.
+//   - mysql.get_status_variables[<session>], a JSON object of the global status variables.
+//   - mysql.db.discovery[<session>], a JSON array for low-level discovery of databases.
+//   - mysql.db.size[<session>,<user>,<password>,<database>], the size of the tables of database in bytes.
+//
+// The user and password parameters are accepted for compatibility with the template and ignored, as each session
+// has its own credentials.
+func Items(databases map[string]*sql.DB, next zbx.ItemFunc) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		query, ok := items[name]
+		if err != nil || !ok {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		if len(params) == 0 || params[0] == "" {
+			return nil, fmt.Errorf("missing session")
+		}
+		if len(params) > 4 {
+			return nil, fmt.Errorf("too many parameters")
+		}
+		db, ok := databases[params[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown session '%s'", params[0])
+		}
+		database := ""
+		if len(params) == 4 {
+			database = params[3]
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		defer cancel()
+		return query(ctx, db, database)
+	}
+}
+
+var items = map[string]func(ctx context.Context, db *sql.DB, database string) (interface{}, error){
+	"mysql.ping":                 ping,
+	"mysql.version":              version,
+	"mysql.get_status_variables": statusVariables,
+	"mysql.db.discovery":         databaseDiscovery,
+	"mysql.db.size":              databaseSize,
+}
+
+func ping(ctx context.Context, db *sql.DB, _ string) (interface{}, error) {
+	if err := db.PingContext(ctx); err != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func version(ctx context.Context, db *sql.DB, _ string) (interface{}, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return nil, fmt.Errorf("error getting version: %s", err.Error())
+	}
+	return version, nil
+}
+
+func statusVariables(ctx context.Context, db *sql.DB, _ string) (interface{}, error) {
+	rows, err := db.QueryContext(ctx, "SHOW GLOBAL STATUS")
+	if err != nil {
+		return nil, fmt.Errorf("error getting status variables: %s", err.Error())
+	}
+	defer rows.Close()
+
+	variables := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("error getting status variables: %s", err.Error())
+		}
+		variables[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting status variables: %s", err.Error())
+	}
+	return zbx.JSON(variables)
+}
+
+func databaseDiscovery(ctx context.Context, db *sql.DB, _ string) (interface{}, error) {
+	rows, err := db.QueryContext(ctx, "SELECT schema_name FROM information_schema.schemata ORDER BY schema_name")
+	if err != nil {
+		return nil, fmt.Errorf("error listing databases: %s", err.Error())
+	}
+	defer rows.Close()
+
+	discovered := []map[string]string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error listing databases: %s", err.Error())
+		}
+		discovered = append(discovered, map[string]string{"{#DATABASE}": name})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing databases: %s", err.Error())
+	}
+	return zbx.JSON(discovered)
+}
+
+func databaseSize(ctx context.Context, db *sql.DB, database string) (interface{}, error) {
+	if database == "" {
+		return nil, fmt.Errorf("missing database")
+	}
+	var size int64
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE table_schema = ?", database).Scan(&size)
+	if err != nil {
+		return nil, fmt.Errorf("error getting size of database '%s': %s", database, err.Error())
+	}
+	return size, nil
+}
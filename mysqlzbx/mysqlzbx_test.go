@@ -0,0 +1,121 @@
+package mysqlzbx_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecnepsnai/zbx/mysqlzbx"
+)
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating mock database: %s", err.Error())
+	}
+	defer db.Close()
+	items := mysqlzbx.Items(map[string]*sql.DB{"primary": db}, func(key string) (interface{}, error) {
+		if key == "agent.ping" {
+			return 1, nil
+		}
+		return nil, nil
+	})
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(fmt.Errorf("connection refused"))
+	mock.ExpectQuery(`SELECT VERSION\(\)`).WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	mock.ExpectQuery("SHOW GLOBAL STATUS").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+		AddRow("Uptime", "3600").
+		AddRow("Threads_connected", "4"))
+	mock.ExpectQuery("FROM information_schema.schemata").WillReturnRows(sqlmock.NewRows([]string{"schema_name"}).
+		AddRow("app").
+		AddRow("mysql"))
+	mock.ExpectQuery("FROM information_schema.tables").WithArgs("app").WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(16384))
+
+	expected := []struct {
+		key   string
+		value interface{}
+	}{
+		{`mysql.ping[primary,"{$MYSQL.USER}","{$MYSQL.PASSWORD}"]`, 1},
+		{"mysql.ping[primary]", 0},
+		{"mysql.version[primary]", "8.0.36"},
+		{"mysql.get_status_variables[primary]", `{"Threads_connected":"4","Uptime":"3600"}`},
+		{"mysql.db.discovery[primary]", `[{"{#DATABASE}":"app"},{"{#DATABASE}":"mysql"}]`},
+		{"mysql.db.size[primary,,,app]", int64(16384)},
+		{"agent.ping", 1},
+	}
+	for _, test := range expected {
+		value, err := items(test.key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", test.key, err.Error())
+		} else if value != test.value {
+			t.Errorf("Unexpected value for '%s'. Expected %v got %v", test.key, test.value, value)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unexpected queries: %s", err.Error())
+	}
+}
+
+func TestItemsInvalid(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database: %s", err.Error())
+	}
+	defer db.Close()
+	items := mysqlzbx.Items(map[string]*sql.DB{"primary": db}, nil)
+
+	mock.ExpectQuery(`SELECT VERSION\(\)`).WillReturnError(fmt.Errorf("access denied"))
+
+	for _, key := range []string{
+		"mysql.version",
+		"mysql.version[replica]",
+		"mysql.version[primary,,,,extra]",
+		"mysql.db.size[primary]",
+		"mysql.version[primary]",
+	} {
+		if _, err := items(key); err == nil {
+			t.Errorf("No error seen for '%s'", key)
+		}
+	}
+	if value, err := items("agent.ping"); value != nil || err != nil {
+		t.Errorf("Unexpected result for unknown key: %v %v", value, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	databases, err := mysqlzbx.Open(map[string]string{"primary": "zabbix:password@tcp(127.0.0.1:3306)/"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	databases["primary"].Close()
+
+	if _, err := mysqlzbx.Open(map[string]string{"primary": "zabbix@tcp(127.0.0.1"}); err == nil {
+		t.Errorf("No error seen for invalid data source name")
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := mysqlzbx.Items(map[string]*sql.DB{}, func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	})
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := mysqlzbx.Items(map[string]*sql.DB{}, nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/ecnepsnai/zbx/otelzbx
+
+go 1.17
+
+require (
+	github.com/ecnepsnai/zbx v0.0.0-20261016123541-ef93aff01069
+	go.opentelemetry.io/otel v1.10.0
+	go.opentelemetry.io/otel/sdk v1.10.0
+	go.opentelemetry.io/otel/trace v1.10.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/sys v0.9.0 // indirect
+)
@@ -0,0 +1,66 @@
+/*
+Package otelzbx adds OpenTelemetry tracing to a zbx agent, with a span for each request from the Zabbix server.
+
+It is a separate module so that the zbx package does not depend on OpenTelemetry.
+*/
+package otelzbx
+
+import (
+	"context"
+
+	"github.com/ecnepsnai/zbx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer used for spans
+const InstrumentationName = "github.com/ecnepsnai/zbx/otelzbx"
+
+// Span attribute keys
+const (
+	// KeyAttribute is the requested item key
+	KeyAttribute = attribute.Key("zabbix.item.key")
+	// OutcomeAttribute is the outcome of the request, one of "value", "unsupported" or "error"
+	OutcomeAttribute = attribute.Key("zabbix.item.outcome")
+	// PeerAttribute is the remote address of the connection
+	PeerAttribute = attribute.Key("net.sock.peer.addr")
+)
+
+// Instrument sets the OnRequest hook of server to record a span for each request using a tracer from provider. Any
+// existing OnRequest hook is still called. Must be called before the server is started.
+//
+// Spans are named "zabbix.item" and have the KeyAttribute, PeerAttribute and OutcomeAttribute attributes. Requests
+// that return an error have the span status set to error.
+func Instrument(server *zbx.Server, provider trace.TracerProvider) {
+	tracer := provider.Tracer(InstrumentationName)
+	next := server.OnRequest
+
+	server.OnRequest = func(remoteAddr string, key string) func(value interface{}, err error) {
+		var nextDone func(value interface{}, err error)
+		if next != nil {
+			nextDone = next(remoteAddr, key)
+		}
+
+		_, span := tracer.Start(context.Background(), "zabbix.item",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(KeyAttribute.String(key), PeerAttribute.String(remoteAddr)))
+
+		return func(value interface{}, err error) {
+			if nextDone != nil {
+				nextDone(value, err)
+			}
+
+			if err != nil {
+				span.SetAttributes(OutcomeAttribute.String("error"))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else if value == nil {
+				span.SetAttributes(OutcomeAttribute.String("unsupported"))
+			} else {
+				span.SetAttributes(OutcomeAttribute.String("value"))
+			}
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package otelzbx_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/otelzbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrument(t *testing.T) {
+	zbx.ErrorLog = io.Discard
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "agent.ping":
+				return 1, nil
+			case "generate.error":
+				return nil, fmt.Errorf("this is an error")
+			}
+			return nil, nil
+		},
+	}
+	otelzbx.Instrument(server, provider)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	go server.Serve(l)
+	defer l.Close()
+
+	for _, key := range []string{"agent.ping", "generate.error", "unknown"} {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+		}
+		zbxproto.WriteMessage(c, []byte(key), nil)
+		io.ReadAll(c)
+		c.Close()
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Unexpected number of spans. Expected 3 got %d", len(spans))
+	}
+
+	expected := []struct {
+		key     string
+		outcome string
+		status  codes.Code
+	}{
+		{"agent.ping", "value", codes.Unset},
+		{"generate.error", "error", codes.Error},
+		{"unknown", "unsupported", codes.Unset},
+	}
+	for i, span := range spans {
+		attributes := map[string]string{}
+		for _, attribute := range span.Attributes() {
+			attributes[string(attribute.Key)] = attribute.Value.Emit()
+		}
+		if span.Name() != "zabbix.item" {
+			t.Errorf("Unexpected span name '%s'", span.Name())
+		}
+		if attributes[string(otelzbx.KeyAttribute)] != expected[i].key {
+			t.Errorf("Unexpected key attribute. Expected '%s' got '%s'", expected[i].key, attributes[string(otelzbx.KeyAttribute)])
+		}
+		if attributes[string(otelzbx.OutcomeAttribute)] != expected[i].outcome {
+			t.Errorf("Unexpected outcome attribute. Expected '%s' got '%s'", expected[i].outcome, attributes[string(otelzbx.OutcomeAttribute)])
+		}
+		if attributes[string(otelzbx.PeerAttribute)] == "" {
+			t.Errorf("No peer attribute on span")
+		}
+		if span.Status().Code != expected[i].status {
+			t.Errorf("Unexpected span status for '%s'. Expected %s got %s", expected[i].key, expected[i].status, span.Status().Code)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package zbx
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Peer describes the Zabbix server or proxy that connected to the agent
+type Peer struct {
+	// Addr is the remote address of the connection
+	Addr net.Addr
+	// TLS is the state of the TLS connection, including any certificates presented by the peer, or nil if the
+	// connection is not using TLS
+	TLS *tls.ConnectionState
+}
+
+// IP returns the IP address of the peer, or nil if the address is not an IP address
+func (p Peer) IP() net.IP {
+	switch addr := p.Addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	if p.Addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// CommonName returns the common name of the certificate presented by the peer, or an empty string if the peer did
+// not present a certificate
+func (p Peer) CommonName() string {
+	if p.TLS == nil || len(p.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return p.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+// NetworkSelector returns a function for the PeerSelector option that selects the ItemFunc for the first network in
+// routes that contains the IP address of the peer. Networks are IP addresses or CIDR ranges, such as "10.0.0.5" or
+// "10.1.0.0/16", and are matched from the most specific to the least specific. Peers that are not in any network use
+// the ItemFunc of the server.
+//
+// An error is returned if a network is invalid.
+func NetworkSelector(routes map[string]ItemFunc) (func(peer Peer) ItemFunc, error) {
+	type route struct {
+		network  *net.IPNet
+		itemFunc ItemFunc
+	}
+	parsed := []route{}
+	for network, itemFunc := range routes {
+		cidr := network
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s'", network)
+		}
+		parsed = append(parsed, route{network: ipNet, itemFunc: itemFunc})
+	}
+
+	return func(peer Peer) ItemFunc {
+		ip := peer.IP()
+		if ip == nil {
+			return nil
+		}
+		var selected ItemFunc
+		longest := -1
+		for _, r := range parsed {
+			if ones, _ := r.network.Mask.Size(); r.network.Contains(ip) && ones > longest {
+				selected = r.itemFunc
+				longest = ones
+			}
+		}
+		return selected
+	}, nil
+}
@@ -0,0 +1,100 @@
+package zbx_test
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func staticItemFunc(value string) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		return value, nil
+	}
+}
+
+func TestPeerSelectorNetwork(t *testing.T) {
+	t.Parallel()
+
+	selector, err := zbx.NetworkSelector(map[string]zbx.ItemFunc{
+		"127.0.0.0/8": staticItemFunc("loopback"),
+		"127.0.0.1":   staticItemFunc("localhost"),
+		"10.0.0.0/8":  staticItemFunc("private"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc:     staticItemFunc("default"),
+		PeerSelector: selector,
+	})
+
+	// The most specific network is used
+	if reply := queryKey(t, addr, "agent.ping"); reply != "localhost" {
+		t.Errorf("Unexpected reply. Expected 'localhost' got '%s'", reply)
+	}
+}
+
+func TestPeerSelectorTLS(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: staticItemFunc("production"),
+		PeerSelector: func(peer zbx.Peer) zbx.ItemFunc {
+			if peer.CommonName() == "zbx" {
+				return staticItemFunc("staging")
+			}
+			return nil
+		},
+	}
+	addr := startTestTLSServer(t, server, &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+		ClientAuth:   tls.RequestClientCert,
+	})
+
+	if reply := queryKeyTLS(t, addr, "agent.ping", &tls.Config{InsecureSkipVerify: true}); reply != "production" {
+		t.Errorf("Unexpected reply without certificate. Expected 'production' got '%s'", reply)
+	}
+	config := &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{testCertificate(t)}}
+	if reply := queryKeyTLS(t, addr, "agent.ping", config); reply != "staging" {
+		t.Errorf("Unexpected reply with certificate. Expected 'staging' got '%s'", reply)
+	}
+}
+
+func TestNetworkSelector(t *testing.T) {
+	t.Parallel()
+
+	selector, err := zbx.NetworkSelector(map[string]zbx.ItemFunc{
+		"fd00::/8": staticItemFunc("ula"),
+		"::1":      staticItemFunc("localhost"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"[::1]:1234":     "localhost",
+		"[fd12::1]:1234": "ula",
+		"192.0.2.1:1234": "",
+	}
+	for address, expectedValue := range expected {
+		addr, _ := net.ResolveTCPAddr("tcp", address)
+		itemFunc := selector(zbx.Peer{Addr: addr})
+		value := ""
+		if itemFunc != nil {
+			result, _ := itemFunc("agent.ping")
+			value = result.(string)
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected selection for %s. Expected '%s' got '%s'", address, expectedValue, value)
+		}
+	}
+
+	if _, err := zbx.NetworkSelector(map[string]zbx.ItemFunc{"10.0.0.0/33": nil}); err == nil {
+		t.Errorf("No error seen for invalid network")
+	}
+	if _, err := zbx.NetworkSelector(map[string]zbx.ItemFunc{"not-an-ip": nil}); err == nil {
+		t.Errorf("No error seen for invalid address")
+	}
+}
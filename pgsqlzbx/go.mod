@@ -0,0 +1,9 @@
+module github.com/ecnepsnai/zbx/pgsqlzbx
+
+go 1.17
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/ecnepsnai/zbx v0.0.0-20261016123541-ef93aff01069
+	github.com/lib/pq v1.10.9
+)
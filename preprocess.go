@@ -0,0 +1,285 @@
+package zbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PreprocessingStep describes a method that transforms the value of an item before it is sent to the server.
+// Steps are given the requested key and the current value, and return the new value. Returning an error stops
+// any remaining steps and the error is sent to the server.
+type PreprocessingStep func(key string, value interface{}) (interface{}, error)
+
+// Preprocess returns an ItemFunc that applies the preprocessing steps for the requested key, in order, to the value
+// returned by itemFunc. Keys without any steps, unknown keys, and errors from itemFunc are passed through unchanged.
+//
+// Preprocessing on the agent is an alternative to configuring preprocessing steps for the item on the server or
+// proxy.
+func Preprocess(itemFunc ItemFunc, steps map[string][]PreprocessingStep) ItemFunc {
+	if itemFunc == nil {
+		panic("itemFunc is nil")
+	}
+
+	return func(key string) (interface{}, error) {
+		value, err := itemFunc(key)
+		if err != nil || value == nil {
+			return value, err
+		}
+
+		for _, step := range steps[key] {
+			value, err = step(key, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+}
+
+// RegexExtract returns a preprocessing step that matches the value against pattern and returns output, where any
+// \N (where N=0..9) in output is replaced with the Nth capture group of the match (\0 being the entire match).
+// Returns an error if the value does not match. Panics if pattern is not a valid regular expression.
+func RegexExtract(pattern string, output string) PreprocessingStep {
+	re := regexp.MustCompile(pattern)
+
+	return func(key string, value interface{}) (interface{}, error) {
+		str := fmt.Sprintf("%v", value)
+		match := re.FindStringSubmatch(str)
+		if match == nil {
+			return nil, fmt.Errorf("cannot perform regular expression match: pattern does not match value")
+		}
+
+		result := &strings.Builder{}
+		for i := 0; i < len(output); i++ {
+			if output[i] == '\\' && i+1 < len(output) && output[i+1] >= '0' && output[i+1] <= '9' {
+				group := int(output[i+1] - '0')
+				if group < len(match) {
+					result.WriteString(match[group])
+				}
+				i++
+				continue
+			}
+			result.WriteByte(output[i])
+		}
+		return result.String(), nil
+	}
+}
+
+// Multiplier returns a preprocessing step that multiplies the numeric value by multiplier. Returns an error if the
+// value is not numeric.
+func Multiplier(multiplier float64) PreprocessingStep {
+	return func(key string, value interface{}) (interface{}, error) {
+		number, err := numericValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return formatFloat(number * multiplier), nil
+	}
+}
+
+// Delta returns a preprocessing step that returns the difference between the numeric value and the previous value
+// for the same key. The first value, and any value smaller than the previous value, returns ErrNoValue.
+func Delta() PreprocessingStep {
+	return counterStep(nil, func(counter *Counter, value float64) (float64, bool) {
+		return counter.Delta(value)
+	})
+}
+
+// ChangePerSecond returns a preprocessing step that returns the difference between the numeric value and the
+// previous value for the same key, divided by the number of seconds since the previous value. The first value, and
+// any value smaller than the previous value, returns ErrNoValue. The time of each value is read from clock, or from
+// SystemClock if clock is nil.
+func ChangePerSecond(clock Clock) PreprocessingStep {
+	return counterStep(clock, func(counter *Counter, value float64) (float64, bool) {
+		return counter.Rate(value)
+	})
+}
+
+// counterStep returns a preprocessing step that samples a Counter with clock for each key, and sends the result of
+// calculate
+func counterStep(clock Clock, calculate func(counter *Counter, value float64) (float64, bool)) PreprocessingStep {
+	lock := sync.Mutex{}
+	counters := map[string]*Counter{}
+
+	return func(key string, value interface{}) (interface{}, error) {
+		number, err := numericValue(value)
+		if err != nil {
+			return nil, err
+		}
+
+		lock.Lock()
+		counter, ok := counters[key]
+		if !ok {
+			counter = &Counter{Clock: clock}
+			counters[key] = counter
+		}
+		lock.Unlock()
+
+		result, ok := calculate(counter, number)
+		if !ok {
+			return nil, ErrNoValue
+		}
+		return formatFloat(result), nil
+	}
+}
+
+// JSONPath returns a preprocessing step that extracts data from a JSON value using a JSONPath expression, such
+// as "$.queues[0].depth" or "$['queues'][*].name". Supported are dot and bracket notation, array indexes, and the
+// * wildcard. Paths containing a wildcard return a JSON array of all matches, otherwise the matching value is
+// returned, with strings returned without quotes and objects or arrays as JSON. Returns an error if nothing matches.
+// Panics if path is not a valid JSONPath expression.
+func JSONPath(path string) PreprocessingStep {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(key string, value interface{}) (interface{}, error) {
+		decoder := json.NewDecoder(strings.NewReader(fmt.Sprintf("%v", value)))
+		decoder.UseNumber()
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return nil, fmt.Errorf("cannot extract value from json by path \"%s\": %s", path, err.Error())
+		}
+
+		nodes := []interface{}{document}
+		wildcard := false
+		for _, segment := range segments {
+			if segment.wildcard {
+				wildcard = true
+			}
+			nodes = segment.apply(nodes)
+		}
+
+		if wildcard {
+			return encodeJSONValue(nodes)
+		}
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("cannot extract value from json by path \"%s\": no data matches the specified path", path)
+		}
+		return encodeJSONValue(nodes[0])
+	}
+}
+
+type jsonPathSegment struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s jsonPathSegment) apply(nodes []interface{}) []interface{} {
+	results := []interface{}{}
+	for _, node := range nodes {
+		switch typed := node.(type) {
+		case map[string]interface{}:
+			if s.wildcard {
+				keys := make([]string, 0, len(typed))
+				for key := range typed {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					results = append(results, typed[key])
+				}
+			} else if !s.isIndex {
+				if child, ok := typed[s.name]; ok {
+					results = append(results, child)
+				}
+			}
+		case []interface{}:
+			if s.wildcard {
+				results = append(results, typed...)
+			} else if s.isIndex {
+				index := s.index
+				if index < 0 {
+					index += len(typed)
+				}
+				if index >= 0 && index < len(typed) {
+					results = append(results, typed[index])
+				}
+			}
+		}
+	}
+	return results
+}
+
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid jsonpath '%s': must start with $", path)
+	}
+
+	segments := []jsonPathSegment{}
+	remaining := path[1:]
+	for remaining != "" {
+		switch remaining[0] {
+		case '.':
+			remaining = remaining[1:]
+			end := strings.IndexAny(remaining, ".[")
+			if end == -1 {
+				end = len(remaining)
+			}
+			name := remaining[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid jsonpath '%s': empty name", path)
+			}
+			if name == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				segments = append(segments, jsonPathSegment{name: name})
+			}
+			remaining = remaining[end:]
+		case '[':
+			end := strings.Index(remaining, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonpath '%s': unterminated bracket", path)
+			}
+			inner := remaining[1:end]
+			remaining = remaining[end+1:]
+
+			if inner == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, jsonPathSegment{name: inner[1 : len(inner)-1]})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid jsonpath '%s': invalid index '%s'", path, inner)
+				}
+				segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid jsonpath '%s': unexpected character '%c'", path, remaining[0])
+		}
+	}
+
+	return segments, nil
+}
+
+func encodeJSONValue(value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case json.Number:
+		return typed.String(), nil
+	}
+	return JSON(value)
+}
+
+func numericValue(value interface{}) (float64, error) {
+	str := strings.TrimSpace(fmt.Sprintf("%v", value))
+	number, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value '%s' is not numeric", str)
+	}
+	return number, nil
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
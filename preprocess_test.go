@@ -0,0 +1,178 @@
+package zbx_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestPreprocess(t *testing.T) {
+	t.Parallel()
+
+	itemFunc := zbx.Preprocess(func(key string) (interface{}, error) {
+		switch key {
+		case "app.memory":
+			return "memory: 2048 KiB", nil
+		case "app.version":
+			return "1.2.3", nil
+		case "app.broken":
+			return nil, fmt.Errorf("broken")
+		}
+		return nil, nil
+	}, map[string][]zbx.PreprocessingStep{
+		"app.memory": {
+			zbx.RegexExtract(`memory: (\d+) KiB`, `\1`),
+			zbx.Multiplier(1024),
+		},
+		"app.broken": {
+			zbx.Multiplier(1024),
+		},
+	})
+
+	value, err := itemFunc("app.memory")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if value != "2097152" {
+		t.Errorf("Unexpected value. Expected '%s' got '%v'", "2097152", value)
+	}
+	value, err = itemFunc("app.version")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if value != "1.2.3" {
+		t.Errorf("Unexpected value. Expected '%s' got '%v'", "1.2.3", value)
+	}
+	if _, err := itemFunc("app.broken"); err == nil || err.Error() != "broken" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	value, err = itemFunc("app.unknown")
+	if value != nil || err != nil {
+		t.Errorf("Unexpected result for unknown key: %v, %v", value, err)
+	}
+}
+
+func TestRegexExtract(t *testing.T) {
+	t.Parallel()
+
+	step := zbx.RegexExtract(`(\w+)=(\d+)`, `\2 \1 \0`)
+	value, err := step("key", "a=1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if value != "1 a a=1" {
+		t.Errorf("Unexpected value. Expected '%s' got '%v'", "1 a a=1", value)
+	}
+	if _, err := step("key", "nothing here"); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+}
+
+func TestMultiplier(t *testing.T) {
+	t.Parallel()
+
+	value, err := zbx.Multiplier(0.5)("key", 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if value != "1.5" {
+		t.Errorf("Unexpected value. Expected '%s' got '%v'", "1.5", value)
+	}
+	if _, err := zbx.Multiplier(2)("key", "abc"); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+}
+
+func TestDelta(t *testing.T) {
+	t.Parallel()
+
+	step := zbx.Delta()
+	check := func(key string, input interface{}, expected string) {
+		value, err := step(key, input)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if value != expected {
+			t.Errorf("Unexpected value for input %v. Expected '%s' got '%v'", input, expected, value)
+		}
+	}
+	noValue := func(key string, input interface{}) {
+		if value, err := step(key, input); err != zbx.ErrNoValue {
+			t.Errorf("Unexpected result for input %v. Expected ErrNoValue got %v %v", input, value, err)
+		}
+	}
+
+	noValue("a", 10)
+	check("a", 15, "5")
+	noValue("b", 100)
+	check("a", 25, "10")
+	noValue("a", 5)
+	check("b", 101, "1")
+	check("a", 5, "0")
+}
+
+func TestChangePerSecond(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	step := zbx.ChangePerSecond(clock)
+	if _, err := step("key", 0); err != zbx.ErrNoValue {
+		t.Errorf("Unexpected error for first value: %v", err)
+	}
+	clock.Advance(4 * time.Second)
+	value, err := step("key", 1000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if value != "250" {
+		t.Errorf("Unexpected rate: %v", value)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	t.Parallel()
+
+	document := `{"queues":[{"name":"default","depth":4},{"name":"mail","depth":0.5}],"status":{"ok":true}}`
+	check := func(path string, expected string) {
+		value, err := zbx.JSONPath(path)("key", document)
+		if err != nil {
+			t.Errorf("Unexpected error for path '%s': %s", path, err.Error())
+			return
+		}
+		if value != expected {
+			t.Errorf("Unexpected value for path '%s'. Expected '%s' got '%v'", path, expected, value)
+		}
+	}
+
+	check("$.queues[0].name", "default")
+	check("$['queues'][1].depth", "0.5")
+	check(`$["queues"][-1].name`, "mail")
+	check("$.status", `{"ok":true}`)
+	check("$.status.ok", "true")
+	check("$.queues[*].depth", `[4,0.5]`)
+	check("$.status.*", `[true]`)
+
+	if _, err := zbx.JSONPath("$.missing")("key", document); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+	if _, err := zbx.JSONPath("$.status")("key", "not json"); err == nil {
+		t.Errorf("No error seen when one expected")
+	}
+}
+
+func TestJSONPathInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"queues", "$.", "$[abc]", "$[0", "$x"} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("No panic for invalid path '%s'", path)
+				}
+			}()
+			zbx.JSONPath(path)
+		}()
+	}
+}
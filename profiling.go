@@ -0,0 +1,193 @@
+package zbx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultProfileDuration is how long a CPU profile is recorded for when a duration is not requested
+const DefaultProfileDuration = 30 * time.Second
+
+// MaxProfileDuration is the longest that a CPU profile can be recorded for
+const MaxProfileDuration = 5 * time.Minute
+
+// ProfilingHandler returns an HTTP handler that serves runtime profiles of the application in the format read by
+// `go tool pprof`, if the Profiling option of the server is enabled. Otherwise it responds with status 404. The last
+// element of the path names the profile, so the handler can be mounted under any prefix:
+//
+//	http.Handle("/debug/pprof/", server.ProfilingHandler())
+//
+// "profile" records a CPU profile for the number of seconds given by the seconds query parameter, defaulting to
+// DefaultProfileDuration. Any other name is a profile from runtime/pprof, such as goroutine, heap, allocs, block or
+// mutex, with the debug query parameter selecting its format. Requesting the prefix itself lists the profiles.
+//
+// The handler exposes details of the application, so it should only be served to trusted clients. ServeProfiling
+// serves it on a local unix socket.
+func (s *Server) ProfilingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Profiling {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		name := path.Base(r.URL.Path)
+		switch {
+		case name == "profile":
+			serveCPUProfile(w, r)
+		case pprof.Lookup(name) != nil:
+			debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+			if debug > 0 {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			} else {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+			}
+			pprof.Lookup(name).WriteTo(w, debug)
+		case r.URL.Path == "/" || len(r.URL.Path) > 0 && r.URL.Path[len(r.URL.Path)-1] == '/':
+			names := []string{"profile"}
+			for _, profile := range pprof.Profiles() {
+				names = append(names, profile.Name())
+			}
+			sort.Strings(names)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, name := range names {
+				fmt.Fprintln(w, name)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func serveCPUProfile(w http.ResponseWriter, r *http.Request) {
+	duration := DefaultProfileDuration
+	if seconds := r.URL.Query().Get("seconds"); seconds != "" {
+		n, err := strconv.Atoi(seconds)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid seconds", http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(n) * time.Second
+	}
+	if duration > MaxProfileDuration {
+		duration = MaxProfileDuration
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	if err := pprof.StartCPUProfile(w); err != nil {
+		// Usually because a profile is already being recorded
+		w.Header().Del("Content-Disposition")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+	pprof.StopCPUProfile()
+}
+
+// ServeProfiling serves the ProfilingHandler of the server on a unix socket at socketPath, which only users with
+// access to the file can connect to, such as with:
+//
+//	curl --unix-socket /run/myapp/pprof.sock http://localhost/heap > heap.pprof
+//
+// Will block until the server is closed, and always returns an error. A socket left at socketPath is only removed if
+// nothing is listening on it, and the socket is removed when the server is closed. The Profiling option must be
+// enabled for profiles to be served.
+func (s *Server) ServeProfiling(socketPath string) error {
+	if err := removeStaleSocket(socketPath); err != nil {
+		return err
+	}
+
+	// The socket is created in a directory that only this user can access, and moved into place once its permissions
+	// are set, so there is no time at which other users can connect to it
+	dir, err := os.MkdirTemp(filepath.Dir(socketPath), ".zbx-profiling-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	tempPath := filepath.Join(dir, "pprof.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: tempPath, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	// The socket is removed from socketPath by profilingListener rather than from the path it was created at
+	l.SetUnlinkOnClose(false)
+	if err := os.Chmod(tempPath, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	if err := os.Rename(tempPath, socketPath); err != nil {
+		l.Close()
+		return err
+	}
+	listener := &profilingListener{Listener: l, path: socketPath}
+
+	s.listenerLock.Lock()
+	s.profilingListeners = append(s.profilingListeners, listener)
+	s.listenerLock.Unlock()
+
+	return http.Serve(listener, s.ProfilingHandler())
+}
+
+// removeStaleSocket removes the socket at socketPath if nothing is listening on it. Returns an error if something is,
+// or if socketPath is not a socket.
+func removeStaleSocket(socketPath string) error {
+	info, err := os.Lstat(socketPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", socketPath)
+	}
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use", socketPath)
+	}
+	return os.Remove(socketPath)
+}
+
+// profilingListener is a listener on the unix socket at path, which is removed when the listener is closed
+type profilingListener struct {
+	net.Listener
+	path      string
+	closeOnce sync.Once
+}
+
+func (l *profilingListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() {
+		os.Remove(l.path)
+	})
+	return err
+}
+
+// callWithLabels calls f with the profiler labels for the item key, if profiling is enabled, so that profiles can be
+// filtered by key with `go tool pprof -tagfocus zbx_key=<key>`
+func (s *Server) callWithLabels(key string, f func()) {
+	if !s.Profiling {
+		f()
+		return
+	}
+	pprof.Do(context.Background(), pprof.Labels("zbx_key", key), func(context.Context) {
+		f()
+	})
+}
@@ -0,0 +1,203 @@
+package zbx_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestProfilingHandler(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			close(started)
+			<-release
+			return 1, nil
+		},
+	}
+	handler := server.ProfilingHandler()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	if w := get("/debug/pprof/heap"); w.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status with profiling disabled: %d", w.Code)
+	}
+
+	server.Profiling = true
+	addr := startTestServer(t, server)
+	values := make(chan string, 1)
+	go func() {
+		values <- queryKey(t, addr, "slow.item")
+	}()
+	<-started
+
+	if w := get("/debug/pprof/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap\n") {
+		t.Errorf("Unexpected index: %d %s", w.Code, w.Body.String())
+	}
+	if w := get("/debug/pprof/heap"); w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("Unexpected heap profile: %d %d bytes", w.Code, w.Body.Len())
+	}
+	// Goroutines running an item are labelled with its key
+	if w := get("/debug/pprof/goroutine?debug=1"); !strings.Contains(w.Body.String(), `"zbx_key":"slow.item"`) {
+		t.Errorf("Goroutine profile does not include item key label")
+	}
+	if w := get("/debug/pprof/profile?seconds=-1"); w.Code != http.StatusBadRequest {
+		t.Errorf("Unexpected status for invalid seconds: %d", w.Code)
+	}
+	if w := get("/debug/pprof/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status for unknown profile: %d", w.Code)
+	}
+
+	close(release)
+	if value := <-values; value != "1" {
+		t.Errorf("Unexpected value '%s'", value)
+	}
+}
+
+func TestServeProfiling(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "pprof.sock")
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return nil, nil
+		},
+		Profiling: true,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ServeProfiling(socketPath)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	var response *http.Response
+	var err error
+	for tries := 0; tries < 100; tries++ {
+		if response, err = client.Get("http://localhost/goroutine"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Error requesting profile: %s", err.Error())
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("Unexpected response: %d %d bytes", response.StatusCode, len(body))
+	}
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Error checking socket: %s", err.Error())
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected socket permissions %s", info.Mode().Perm())
+	}
+
+	server.Close()
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ServeProfiling did not return after the server was closed")
+	}
+	if _, err := os.Stat(socketPath); err == nil {
+		t.Errorf("Socket was not removed")
+	}
+}
+
+func TestServeProfilingExistingPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return nil, nil
+		},
+		Profiling: true,
+	}
+	defer server.Close()
+
+	// A file that is not a socket is left alone
+	filePath := filepath.Join(dir, "file")
+	if err := os.WriteFile(filePath, []byte("data"), 0644); err != nil {
+		t.Fatalf("Error writing file: %s", err.Error())
+	}
+	if err := server.ServeProfiling(filePath); err == nil {
+		t.Errorf("No error seen for a path that is not a socket")
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "data" {
+		t.Errorf("File was modified")
+	}
+
+	// A socket that is in use is left alone
+	inUsePath := filepath.Join(dir, "in-use.sock")
+	l, err := net.Listen("unix", inUsePath)
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	defer l.Close()
+	if err := server.ServeProfiling(inUsePath); err == nil {
+		t.Errorf("No error seen for a socket that is in use")
+	}
+	if _, err := os.Stat(inUsePath); err != nil {
+		t.Errorf("Socket in use was removed")
+	}
+
+	// A stale socket is replaced
+	stalePath := filepath.Join(dir, "stale.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: stalePath, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ServeProfiling(stalePath)
+	}()
+	var conn net.Conn
+	for tries := 0; tries < 100; tries++ {
+		if conn, err = net.Dial("unix", stalePath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Error connecting to socket: %s", err.Error())
+	}
+	conn.Close()
+
+	server.Close()
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ServeProfiling did not return after the server was closed")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Unexpected files left in directory: %d", len(entries))
+	}
+}
@@ -0,0 +1,105 @@
+package zbx_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+func TestProtocolErrorReplies(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		ProtocolErrorReplies: true,
+	})
+
+	oversized := make([]byte, 13)
+	copy(oversized, "ZBXD\x01")
+	binary.LittleEndian.PutUint64(oversized[5:], 134217729)
+
+	short := make([]byte, 13)
+	copy(short, "ZBXD\x01")
+	binary.LittleEndian.PutUint64(short[5:], 100)
+	short = append(short, []byte("agent.ping")...)
+
+	requests := map[string][]byte{
+		"Unsupported request flags 0x03: compression is not supported":   []byte("ZBXD\x03\x0a\x00\x00\x00\x00\x00\x00\x00agent.ping"),
+		"Request too large: 134217729 bytes":                             oversized,
+		"Incorrect request size: header reported 100 bytes, received 10": short,
+	}
+	for expected, request := range requests {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+		}
+		if _, err := c.Write(request); err != nil {
+			t.Fatalf("Error writing request: %s", err.Error())
+		}
+		if tcpConn, ok := c.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+		reply, err := zbxproto.ReadMessage(c, nil)
+		c.Close()
+		if err != nil {
+			t.Errorf("Error reading reply for '%s': %s", expected, err.Error())
+			continue
+		}
+		if !strings.HasPrefix(string(reply.Data), "ZBX_NOTSUPPORTED\x00"+expected) {
+			t.Errorf("Unexpected reply. Expected '%s' got %q", expected, reply.Data)
+		}
+	}
+
+	// Requests without the header are not replied to
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	if reply, _ := io.ReadAll(c); len(reply) > 0 {
+		t.Errorf("Unexpected reply for request without header: %q", reply)
+	}
+}
+
+func TestStrictReservedBytes(t *testing.T) {
+	t.Parallel()
+
+	itemFunc := func(key string) (interface{}, error) {
+		return 1, nil
+	}
+	request := []byte("ZBXD\x01\x0a\x00\x00\x00\x07\x00\x00\x00agent.ping")
+
+	query := func(addr string) string {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+		}
+		defer c.Close()
+		if _, err := c.Write(request); err != nil {
+			t.Fatalf("Error writing request: %s", err.Error())
+		}
+		reply, err := zbxproto.ReadMessage(c, nil)
+		if err != nil {
+			t.Fatalf("Error reading reply: %s", err.Error())
+		}
+		return string(reply.Data)
+	}
+
+	lenient := startTestServer(t, &zbx.Server{ItemFunc: itemFunc})
+	if reply := query(lenient); reply != "1" {
+		t.Errorf("Unexpected reply with non-zero reserved bytes. Expected '1' got %q", reply)
+	}
+
+	strict := startTestServer(t, &zbx.Server{ItemFunc: itemFunc, StrictReservedBytes: true, ProtocolErrorReplies: true})
+	if reply := query(strict); !strings.HasPrefix(reply, "ZBX_NOTSUPPORTED\x00Non-zero reserved bytes") {
+		t.Errorf("Unexpected reply with non-zero reserved bytes in strict mode: %q", reply)
+	}
+}
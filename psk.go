@@ -0,0 +1,77 @@
+package zbx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// The limits that Zabbix places on pre-shared keys and their identities
+const (
+	// MinPSKBits is the minimum length of a pre-shared key in bits
+	MinPSKBits = 128
+	// MaxPSKBits is the maximum length of a pre-shared key in bits
+	MaxPSKBits = 2048
+	// MaxPSKIdentityLength is the maximum length of a pre-shared key identity in bytes
+	MaxPSKIdentityLength = 128
+)
+
+// ParsePSK decodes a pre-shared key in the hex encoded format used by TLSPSKFile and the Zabbix frontend, returning
+// an error if it is not valid hex or its length is outside of MinPSKBits and MaxPSKBits. Surrounding whitespace is
+// ignored.
+//
+// As crypto/tls does not support PSK cipher suites the agent cannot use pre-shared keys itself. The PSK helpers are
+// for applications that manage the configuration of other Zabbix components, or use a TLS implementation that does.
+func ParsePSK(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid psk: %s", err.Error())
+	}
+	if bits := len(key) * 8; bits < MinPSKBits || bits > MaxPSKBits {
+		return nil, fmt.Errorf("invalid psk: length of %d bits is not between %d and %d bits", bits, MinPSKBits, MaxPSKBits)
+	}
+	return key, nil
+}
+
+// LoadPSKFile reads and decodes the pre-shared key in fileName, which uses the same format as the TLSPSKFile option
+// of zabbix_agentd: a single line containing the hex encoded key.
+func LoadPSKFile(fileName string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePSK(string(data))
+}
+
+// ValidatePSKIdentity returns an error if identity is not a valid TLSPSKIdentity. Identities must be UTF-8 and
+// between 1 and MaxPSKIdentityLength bytes.
+func ValidatePSKIdentity(identity string) error {
+	if identity == "" {
+		return fmt.Errorf("invalid psk identity: identity is empty")
+	}
+	if len(identity) > MaxPSKIdentityLength {
+		return fmt.Errorf("invalid psk identity: identity is longer than %d bytes", MaxPSKIdentityLength)
+	}
+	if !utf8.ValidString(identity) {
+		return fmt.Errorf("invalid psk identity: identity is not valid UTF-8")
+	}
+	return nil
+}
+
+// GeneratePSK returns a new random pre-shared key of the given length in bits, hex encoded for use in a TLSPSKFile or
+// the Zabbix frontend. bits must be a multiple of 8 between MinPSKBits and MaxPSKBits. 256 bits is recommended.
+func GeneratePSK(bits int) (string, error) {
+	if bits < MinPSKBits || bits > MaxPSKBits || bits%8 != 0 {
+		return "", fmt.Errorf("invalid psk length: %d bits is not a multiple of 8 between %d and %d", bits, MinPSKBits, MaxPSKBits)
+	}
+
+	key := make([]byte, bits/8)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
@@ -0,0 +1,90 @@
+package zbx_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestParsePSK(t *testing.T) {
+	t.Parallel()
+
+	key, err := zbx.ParsePSK("1f87b595725ac58dd977beef14b97461a7c1045b9a1c963065002c5473194952\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(key) != 32 {
+		t.Errorf("Unexpected key length. Expected 32 got %d", len(key))
+	}
+
+	invalid := []string{
+		"",
+		"not hex",
+		"1f87b595725ac58dd977beef14b974",
+		strings.Repeat("ab", 257),
+	}
+	for _, encoded := range invalid {
+		if _, err := zbx.ParsePSK(encoded); err == nil {
+			t.Errorf("No error seen for invalid psk '%.20s'", encoded)
+		}
+	}
+}
+
+func TestLoadPSKFile(t *testing.T) {
+	t.Parallel()
+
+	fileName := filepath.Join(t.TempDir(), "agent.psk")
+	encoded, err := zbx.GeneratePSK(256)
+	if err != nil {
+		t.Fatalf("Error generating psk: %s", err.Error())
+	}
+	if err := os.WriteFile(fileName, []byte(encoded+"\n"), 0600); err != nil {
+		t.Fatalf("Error writing psk file: %s", err.Error())
+	}
+
+	key, err := zbx.LoadPSKFile(fileName)
+	if err != nil {
+		t.Fatalf("Error loading psk file: %s", err.Error())
+	}
+	if len(key) != 32 {
+		t.Errorf("Unexpected key length. Expected 32 got %d", len(key))
+	}
+}
+
+func TestGeneratePSK(t *testing.T) {
+	t.Parallel()
+
+	a, err := zbx.GeneratePSK(128)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	b, _ := zbx.GeneratePSK(128)
+	if len(a) != 32 {
+		t.Errorf("Unexpected encoded length. Expected 32 got %d", len(a))
+	}
+	if a == b {
+		t.Errorf("Generated keys are not random")
+	}
+
+	for _, bits := range []int{0, 64, 130, 4096} {
+		if _, err := zbx.GeneratePSK(bits); err == nil {
+			t.Errorf("No error seen for invalid length %d", bits)
+		}
+	}
+}
+
+func TestValidatePSKIdentity(t *testing.T) {
+	t.Parallel()
+
+	if err := zbx.ValidatePSKIdentity("PSK 001"); err != nil {
+		t.Errorf("Unexpected error for valid identity: %s", err.Error())
+	}
+	for _, identity := range []string{"", strings.Repeat("a", zbx.MaxPSKIdentityLength+1), "bad \xff"} {
+		if err := zbx.ValidatePSKIdentity(identity); err == nil {
+			t.Errorf("No error seen for invalid identity '%.20s'", identity)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package rediszbx provides the redis.ping and redis.info items used by the "Redis by Zabbix agent 2" template.
+//
+// It is a separate module so that the zbx package itself does not depend on redigo.
+package rediszbx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/gomodule/redigo/redis"
+)
+
+// queryTimeout is how long a command can take, which is below the longest item timeout of the Zabbix server
+const queryTimeout = 10 * time.Second
+
+// Open returns a connection pool for each of the URLs in urls, keyed by session name, for use with Items. URLs use
+// the redis or rediss scheme, such as "redis://:password@127.0.0.1:6379/0". Connections are made when needed, and
+// idle connections are kept for reuse by later requests.
+func Open(urls map[string]string) (map[string]*redis.Pool, error) {
+	pools := map[string]*redis.Pool{}
+	for session, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL for session '%s': %s", session, err.Error())
+		}
+		if u.Scheme != "redis" && u.Scheme != "rediss" {
+			return nil, fmt.Errorf("invalid URL for session '%s': unknown scheme '%s'", session, u.Scheme)
+		}
+		rawURL := rawURL
+		pools[session] = &redis.Pool{
+			DialContext: func(ctx context.Context) (redis.Conn, error) {
+				return redis.DialURLContext(ctx, rawURL, redis.DialReadTimeout(queryTimeout), redis.DialWriteTimeout(queryTimeout))
+			},
+			MaxIdle:     3,
+			IdleTimeout: 5 * time.Minute,
+		}
+	}
+	return pools, nil
+}
+
+// Items returns an ItemFunc that runs the keys below on one of pools, chosen by the session parameter. Other keys and
+// keys that cannot be parsed are passed to next, or are unknown if next is nil.
+//
+//   - redis.ping[<session>,<password>], 1 if the server is reachable, otherwise 0.
+//   - redis.info[<session>,<password>,<section>], a JSON object of the INFO section, or of the default sections if
+//     section is empty. Values of the form "keys=1,expires=0", such as those in the Keyspace section, are objects.
+//
+// The password parameter of the template is ignored, as the URL of each session includes its password.
+func Items(pools map[string]*redis.Pool, next zbx.ItemFunc) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil || (name != "redis.ping" && name != "redis.info") {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		if len(params) == 0 || params[0] == "" {
+			return nil, fmt.Errorf("missing session")
+		}
+		if (name == "redis.ping" && len(params) > 2) || len(params) > 3 {
+			return nil, fmt.Errorf("too many parameters")
+		}
+		pool, ok := pools[params[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown session '%s'", params[0])
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		defer cancel()
+		conn, err := pool.GetContext(ctx)
+		if err != nil {
+			if name == "redis.ping" {
+				return 0, nil
+			}
+			return nil, fmt.Errorf("error connecting to session '%s': %s", params[0], err.Error())
+		}
+		defer conn.Close()
+
+		if name == "redis.ping" {
+			if _, err := redis.DoContext(conn, ctx, "PING"); err != nil {
+				return 0, nil
+			}
+			return 1, nil
+		}
+
+		section := "default"
+		if len(params) == 3 && params[2] != "" {
+			section = params[2]
+		}
+		info, err := redis.String(redis.DoContext(conn, ctx, "INFO", section))
+		if err != nil {
+			return nil, fmt.Errorf("error getting info: %s", err.Error())
+		}
+		return zbx.JSON(parseInfo(info))
+	}
+}
+
+// parseInfo parses the reply to INFO into a map of each section, such as "Server", to its fields
+func parseInfo(info string) map[string]map[string]interface{} {
+	sections := map[string]map[string]interface{}{}
+	var fields map[string]interface{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields = map[string]interface{}{}
+			sections[strings.TrimSpace(line[1:])] = fields
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || fields == nil {
+			continue
+		}
+		fields[parts[0]] = parseInfoValue(parts[1])
+	}
+	return sections
+}
+
+// parseInfoValue returns value as a map if it is a list of name=value pairs, otherwise value itself
+func parseInfoValue(value string) interface{} {
+	if !strings.Contains(value, "=") {
+		return value
+	}
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return value
+		}
+		pairs[parts[0]] = parts[1]
+	}
+	return pairs
+}
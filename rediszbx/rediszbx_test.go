@@ -0,0 +1,206 @@
+package rediszbx_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ecnepsnai/zbx/rediszbx"
+	"github.com/gomodule/redigo/redis"
+)
+
+const testInfo = "# Server\r\n" +
+	"redis_version:7.2.4\r\n" +
+	"uptime_in_seconds:3600\r\n" +
+	"\r\n" +
+	"# Keyspace\r\n" +
+	"db0:keys=12,expires=1,avg_ttl=0\r\n"
+
+// startTestServer starts a server that replies to PING and INFO like Redis, and returns its address and the number
+// of connections it has accepted
+func startTestServer(t *testing.T) (string, *int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	connections := new(int32)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(connections, 1)
+			go serveTestConn(conn)
+		}
+	}()
+	return l.Addr().String(), connections
+}
+
+func serveTestConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(command[0]) {
+		case "PING":
+			io.WriteString(conn, "+PONG\r\n")
+		case "INFO":
+			if len(command) != 2 || command[1] != "default" {
+				io.WriteString(conn, "-ERR unexpected section\r\n")
+				continue
+			}
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(testInfo), testInfo)
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", command[0])
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("bad command")
+	}
+	command := make([]string, count)
+	for i := range command {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad argument")
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		command[i] = string(data[:length])
+	}
+	return command, nil
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	address, connections := startTestServer(t)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	closed.Close()
+
+	pools, err := rediszbx.Open(map[string]string{
+		"primary": "redis://" + address,
+		"down":    "redis://" + closed.Addr().String(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	items := rediszbx.Items(pools, func(key string) (interface{}, error) {
+		if key == "agent.ping" {
+			return 1, nil
+		}
+		return nil, nil
+	})
+
+	expected := []struct {
+		key   string
+		value interface{}
+	}{
+		{`redis.ping[primary,"{$REDIS.PASSWORD}"]`, 1},
+		{"redis.ping[down]", 0},
+		{"redis.info[primary]", `{"Keyspace":{"db0":{"avg_ttl":"0","expires":"1","keys":"12"}},"Server":{"redis_version":"7.2.4","uptime_in_seconds":"3600"}}`},
+		{"redis.info[primary,,default]", `{"Keyspace":{"db0":{"avg_ttl":"0","expires":"1","keys":"12"}},"Server":{"redis_version":"7.2.4","uptime_in_seconds":"3600"}}`},
+		{"agent.ping", 1},
+	}
+	for _, test := range expected {
+		value, err := items(test.key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", test.key, err.Error())
+		} else if value != test.value {
+			t.Errorf("Unexpected value for '%s'. Expected %v got %v", test.key, test.value, value)
+		}
+	}
+
+	// Connections are returned to the pool and reused by later requests
+	if count := atomic.LoadInt32(connections); count != 1 {
+		t.Errorf("Unexpected number of connections. Expected 1 got %d", count)
+	}
+}
+
+func TestItemsInvalid(t *testing.T) {
+	t.Parallel()
+
+	address, _ := startTestServer(t)
+	pools, err := rediszbx.Open(map[string]string{"primary": "redis://" + address})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	items := rediszbx.Items(pools, nil)
+
+	for _, key := range []string{
+		"redis.info",
+		"redis.info[replica]",
+		"redis.ping[primary,,extra]",
+		"redis.info[primary,,default,extra]",
+		"redis.info[primary,,commandstats]",
+	} {
+		if _, err := items(key); err == nil {
+			t.Errorf("No error seen for '%s'", key)
+		}
+	}
+	if value, err := items("agent.ping"); value != nil || err != nil {
+		t.Errorf("Unexpected result for unknown key: %v %v", value, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	for _, rawURL := range []string{
+		"127.0.0.1:6379",
+		"http://127.0.0.1:6379",
+		"redis://127.0.0.1:6379/%",
+	} {
+		if _, err := rediszbx.Open(map[string]string{"primary": rawURL}); err == nil {
+			t.Errorf("No error seen for '%s'", rawURL)
+		}
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := rediszbx.Items(map[string]*redis.Pool{}, func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	})
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := rediszbx.Items(map[string]*redis.Pool{}, nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
@@ -0,0 +1,96 @@
+package zbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+type remoteStatsRequest struct {
+	Request string                 `json:"request"`
+	Type    string                 `json:"type,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+type remoteStatsResponse struct {
+	Response string          `json:"response"`
+	Info     string          `json:"info"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// QueryStats requests the internal statistics of the Zabbix server or proxy at address (normally port 10051), the
+// same data returned by the zabbix.stats[<ip>,<port>] item of the Zabbix agent. The address of this host must be
+// included in the StatsAllowedIP setting of the server or proxy.
+//
+// timeout applies to the entire exchange, including connecting.
+func QueryStats(address string, timeout time.Duration) (map[string]interface{}, error) {
+	stats := map[string]interface{}{}
+	if err := queryRemoteStats(address, timeout, remoteStatsRequest{Request: "zabbix.stats"}, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// QueryQueueStats requests the number of items in the queue of the Zabbix server or proxy at address that are
+// delayed by at least from and at most to, the same data returned by the zabbix.stats[<ip>,<port>,queue,<from>,<to>]
+// item of the Zabbix agent. from and to are given in Zabbix time format, such as "10m", and either may be empty.
+//
+// timeout applies to the entire exchange, including connecting.
+func QueryQueueStats(address string, from string, to string, timeout time.Duration) (int, error) {
+	request := remoteStatsRequest{
+		Request: "zabbix.stats",
+		Type:    "queue",
+		Params: map[string]interface{}{
+			"from": from,
+			"to":   to,
+		},
+	}
+
+	queue := struct {
+		Count int `json:"count"`
+	}{}
+	if err := queryRemoteStats(address, timeout, request, &queue); err != nil {
+		return 0, err
+	}
+	return queue.Count, nil
+}
+
+func queryRemoteStats(address string, timeout time.Duration, request remoteStatsRequest, data interface{}) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	requestData, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if err := zbxproto.WriteMessage(conn, requestData, nil); err != nil {
+		return err
+	}
+
+	message, err := zbxproto.ReadMessage(conn, nil)
+	if err != nil {
+		return err
+	}
+
+	response := remoteStatsResponse{}
+	if err := json.Unmarshal(message.Data, &response); err != nil {
+		return fmt.Errorf("invalid response: %s", err.Error())
+	}
+	if response.Response != "success" {
+		if response.Info != "" {
+			return fmt.Errorf("unsuccessful response: %s", response.Info)
+		}
+		return fmt.Errorf("unsuccessful response")
+	}
+	if err := json.Unmarshal(response.Data, data); err != nil {
+		return fmt.Errorf("invalid response data: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package zbx_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// startFakeZabbixServer starts a server that replies to a single request with the given JSON response and sends
+// the data of the request it received on the returned channel
+func startFakeZabbixServer(t *testing.T, response string) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	requests := make(chan string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		header := make([]byte, 13)
+		if _, err := io.ReadFull(c, header); err != nil {
+			return
+		}
+		data := make([]byte, header[5])
+		io.ReadFull(c, data)
+		requests <- string(data)
+
+		length := make([]byte, 8)
+		length[0] = byte(len(response))
+		c.Write(bytes.Join([][]byte{[]byte("ZBXD\x01"), length, []byte(response)}, nil))
+	}()
+
+	return l.Addr().String(), requests
+}
+
+func TestQueryStats(t *testing.T) {
+	t.Parallel()
+
+	addr, requests := startFakeZabbixServer(t, `{"response":"success","data":{"boottime":1600000000,"version":"6.0.0"}}`)
+	stats, err := zbx.QueryStats(addr, time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if request := <-requests; request != `{"request":"zabbix.stats"}` {
+		t.Errorf("Unexpected request: %s", request)
+	}
+	if stats["version"] != "6.0.0" {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestQueryQueueStats(t *testing.T) {
+	t.Parallel()
+
+	addr, requests := startFakeZabbixServer(t, `{"response":"success","data":{"count":42}}`)
+	count, err := zbx.QueryQueueStats(addr, "10m", "", time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if request := <-requests; request != `{"request":"zabbix.stats","type":"queue","params":{"from":"10m","to":""}}` {
+		t.Errorf("Unexpected request: %s", request)
+	}
+	if count != 42 {
+		t.Errorf("Unexpected count. Expected 42 got %d", count)
+	}
+}
+
+func TestQueryStatsFailed(t *testing.T) {
+	t.Parallel()
+
+	addr, _ := startFakeZabbixServer(t, `{"response":"failed","info":"Permission denied."}`)
+	if _, err := zbx.QueryStats(addr, time.Second); err == nil || err.Error() != "unsuccessful response: Permission denied." {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
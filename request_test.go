@@ -0,0 +1,105 @@
+package zbx_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestOnRequest(t *testing.T) {
+	t.Parallel()
+
+	type result struct {
+		remoteAddr string
+		key        string
+		value      interface{}
+		err        error
+	}
+	results := make(chan result, 3)
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "agent.ping":
+				return 1, nil
+			case "generate.error":
+				return nil, fmt.Errorf("this is an error")
+			}
+			return nil, nil
+		},
+		OnRequest: func(remoteAddr string, key string) func(value interface{}, err error) {
+			return func(value interface{}, err error) {
+				results <- result{remoteAddr, key, value, err}
+			}
+		},
+	})
+
+	queryKey(t, addr, "agent.ping")
+	queryKey(t, addr, "generate.error")
+	queryKey(t, addr, "unknown")
+
+	r := <-results
+	if r.key != "agent.ping" || r.value != 1 || r.err != nil || r.remoteAddr == "" {
+		t.Errorf("Unexpected result for agent.ping: %+v", r)
+	}
+	r = <-results
+	if r.key != "generate.error" || r.value != nil || r.err == nil {
+		t.Errorf("Unexpected result for generate.error: %+v", r)
+	}
+	r = <-results
+	if r.key != "unknown" || r.value != nil || r.err != nil {
+		t.Errorf("Unexpected result for unknown: %+v", r)
+	}
+}
+
+func TestOnResult(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	durations := make(chan time.Duration, 4)
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "db.dsn":
+				clock.Advance(2 * time.Second)
+				return "postgres://app:hunter2@db/app", nil
+			case "generate.error":
+				return nil, fmt.Errorf("this is an error")
+			case "agent.ping":
+				return 1, nil
+			}
+			return nil, nil
+		},
+		Clock: clock,
+		OnResult: func(key string, value interface{}, err error, duration time.Duration) (interface{}, error) {
+			durations <- duration
+			switch key {
+			case "db.dsn":
+				return strings.Replace(value.(string), "hunter2", "xxxxx", 1), nil
+			case "generate.error":
+				return "0", nil
+			case "agent.ping":
+				return nil, fmt.Errorf("ping disabled")
+			}
+			return value, err
+		},
+	})
+
+	expected := map[string]string{
+		"db.dsn":         "postgres://app:xxxxx@db/app",
+		"generate.error": "0",
+		"agent.ping":     "ZBX_NOTSUPPORTED\x00ping disabled",
+		"unknown":        "ZBX_NOTSUPPORTED\x00Item key unknown",
+	}
+	for _, key := range []string{"db.dsn", "generate.error", "agent.ping", "unknown"} {
+		if reply := queryKey(t, addr, key); reply != expected[key] {
+			t.Errorf("Unexpected reply for '%s'. Expected %q got %q", key, expected[key], reply)
+		}
+	}
+
+	if duration := <-durations; duration != 2*time.Second {
+		t.Errorf("Unexpected duration. Expected %s got %s", 2*time.Second, duration)
+	}
+}
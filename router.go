@@ -0,0 +1,154 @@
+package zbx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Handler is implemented by the handler of an item registered with a Router. Handlers are usually structs holding the
+// dependencies and configuration of the item, which makes them easier to test than closures:
+//
+//	type QueueDepthItem struct {
+//	    Conn *sql.DB
+//	}
+//
+//	func (i QueueDepthItem) Value(params []string) (interface{}, error) {
+//	    var depth int
+//	    err := i.Conn.QueryRow("SELECT COUNT(*) FROM queue").Scan(&depth)
+//	    return depth, err
+//	}
+type Handler interface {
+	// Value returns the value of the item for a request with the given key parameters, which are nil if the key has
+	// no parameters. As with an ItemFunc, returning (nil, nil) means the key is unknown.
+	Value(params []string) (interface{}, error)
+}
+
+// Validator is optionally implemented by a Handler to check its configuration when it is registered
+type Validator interface {
+	// Validate returns an error if the handler is not configured correctly
+	Validate() error
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(params []string) (interface{}, error)
+
+// Value calls f(params)
+func (f HandlerFunc) Value(params []string) (interface{}, error) {
+	return f(params)
+}
+
+// Router dispatches requests to the Handler registered for the name of the requested key, the part before any
+// parameters. Use its ItemFunc method as the ItemFunc of a server. The zero value is an empty router.
+//
+//	router := &zbx.Router{}
+//	if err := router.Register("app.queue.depth", QueueDepthItem{Conn: db}); err != nil {
+//	    panic(err)
+//	}
+//	zbx.Start(router.ItemFunc, "0.0.0.0:10050")
+type Router struct {
+	// Next is an optional ItemFunc that is called for keys that do not have a registered handler. If nil these keys
+	// are unknown.
+	Next ItemFunc
+
+	lock     sync.RWMutex
+	handlers map[string]Handler
+}
+
+// Register registers handler for requests for keys with the given name, such as "app.queue.depth". Returns an error if
+// the name is not a valid key name, already has a handler, or if the handler implements Validator and its
+// configuration is invalid.
+func (r *Router) Register(name string, handler Handler) error {
+	if handler == nil {
+		return fmt.Errorf("invalid handler for '%s': handler is nil", name)
+	}
+	if err := ValidateKey(name); err != nil {
+		return fmt.Errorf("invalid handler for '%s': %s", name, err.Error())
+	}
+	if name == "" || strings.ContainsAny(name, "[]") {
+		return fmt.Errorf("invalid handler for '%s': name must not be empty or contain parameters", name)
+	}
+	if validator, ok := handler.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("invalid handler for '%s': %s", name, err.Error())
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, duplicate := r.handlers[name]; duplicate {
+		return fmt.Errorf("invalid handler for '%s': name registered more than once", name)
+	}
+	if r.handlers == nil {
+		r.handlers = map[string]Handler{}
+	}
+	r.handlers[name] = handler
+	return nil
+}
+
+// RegisterCounter registers handler like Register, for an item whose handler returns a monotonically increasing
+// counter, such as the number of bytes sent on a network interface. The value sent to the server is the change per
+// second of the counter since the previous request for the same key, with a separate Counter for each set of
+// parameters. The first request for a key, and the first request after the counter was reset, return ErrNoValue.
+func (r *Router) RegisterCounter(name string, handler Handler) error {
+	if handler == nil {
+		return fmt.Errorf("invalid handler for '%s': handler is nil", name)
+	}
+	return r.Register(name, &counterHandler{handler: handler, rate: ChangePerSecond(nil)})
+}
+
+// counterHandler converts the values of a handler from a counter to its change per second
+type counterHandler struct {
+	handler Handler
+	rate    PreprocessingStep
+}
+
+func (h *counterHandler) Value(params []string) (interface{}, error) {
+	value, err := h.handler.Value(params)
+	if err != nil || value == nil {
+		return value, err
+	}
+	// The rate is calculated separately for each set of parameters
+	return h.rate(strings.Join(params, "\x00"), value)
+}
+
+func (h *counterHandler) Validate() error {
+	if validator, ok := h.handler.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// Names returns the names of the registered handlers, sorted, such as for use as the WarmUpKeys of a server
+func (r *Router) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ItemFunc returns the value from the handler registered for the name of key, passing it the parameters of key. Keys
+// that cannot be parsed are passed to Next, as they may still be known to it.
+func (r *Router) ItemFunc(key string) (interface{}, error) {
+	name, params, err := ParseKey(key)
+	if err == nil {
+		r.lock.RLock()
+		handler, ok := r.handlers[name]
+		r.lock.RUnlock()
+		if ok {
+			return handler.Value(params)
+		}
+	}
+
+	if r.Next == nil {
+		return nil, nil
+	}
+	return r.Next(key)
+}
@@ -0,0 +1,157 @@
+package zbx_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+type queueDepthItem struct {
+	Queues map[string]int
+}
+
+func (i queueDepthItem) Value(params []string) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, fmt.Errorf("expected one parameter")
+	}
+	depth, ok := i.Queues[params[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown queue %s", params[0])
+	}
+	return depth, nil
+}
+
+func (i queueDepthItem) Validate() error {
+	if len(i.Queues) == 0 {
+		return fmt.Errorf("no queues")
+	}
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	t.Parallel()
+
+	router := &zbx.Router{
+		Next: func(key string) (interface{}, error) {
+			if key == "agent.ping" {
+				return 1, nil
+			}
+			return nil, nil
+		},
+	}
+	if err := router.Register("app.queue.depth", queueDepthItem{Queues: map[string]int{"email": 4}}); err != nil {
+		t.Fatalf("Error registering handler: %s", err.Error())
+	}
+	if err := router.Register("app.version", zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return "1.4.2", nil
+	})); err != nil {
+		t.Fatalf("Error registering handler: %s", err.Error())
+	}
+
+	addr := startTestServer(t, &zbx.Server{ItemFunc: router.ItemFunc})
+	expected := map[string]string{
+		"app.queue.depth[email]": "4",
+		"app.queue.depth[sms]":   "ZBX_NOTSUPPORTED\x00unknown queue sms",
+		"app.version":            "1.4.2",
+		"agent.ping":             "1",
+		"unknown":                "ZBX_NOTSUPPORTED\x00Item key unknown",
+	}
+	for key, expectedValue := range expected {
+		if value := queryKey(t, addr, key); value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected %q got %q", key, expectedValue, value)
+		}
+	}
+
+	if names := router.Names(); !reflect.DeepEqual(names, []string{"app.queue.depth", "app.version"}) {
+		t.Errorf("Unexpected names: %v", names)
+	}
+}
+
+func TestRouterRegisterInvalid(t *testing.T) {
+	t.Parallel()
+
+	handler := zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		return 1, nil
+	})
+	router := &zbx.Router{}
+	if err := router.Register("app.version", handler); err != nil {
+		t.Fatalf("Error registering handler: %s", err.Error())
+	}
+
+	invalid := map[string]zbx.Handler{
+		"":                       handler,
+		"app.version":            handler,
+		"app.queue.depth[email]": handler,
+		strings.Repeat("a", 257): handler,
+		"app.nil":                nil,
+		"app.queue.depth":        queueDepthItem{},
+	}
+	for name, handler := range invalid {
+		if err := router.Register(name, handler); err == nil {
+			t.Errorf("No error seen for invalid handler '%s'", name)
+		}
+	}
+}
+
+func TestRouterCounter(t *testing.T) {
+	t.Parallel()
+
+	bytesSent := map[string]int{}
+	router := &zbx.Router{}
+	if err := router.RegisterCounter("net.if.out", zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		bytesSent[params[0]] += 1000
+		return bytesSent[params[0]], nil
+	})); err != nil {
+		t.Fatalf("Error registering handler: %s", err.Error())
+	}
+	if err := router.RegisterCounter("app.queue.depth", queueDepthItem{}); err == nil {
+		t.Errorf("No error seen for invalid counter handler")
+	}
+
+	server := &zbx.Server{ItemFunc: router.ItemFunc}
+	addr := startTestServer(t, server)
+	noValue := "ZBX_NOTSUPPORTED\x00" + zbx.ErrNoValue.Error()
+	if value := queryKey(t, addr, "net.if.out[eth0]"); value != noValue {
+		t.Errorf("Unexpected value for first sample. Expected %q got %q", noValue, value)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if value := queryKey(t, addr, "net.if.out[eth0]"); value == noValue || strings.HasPrefix(value, "0") {
+		t.Errorf("Unexpected value for second sample: %q", value)
+	}
+	// Each set of parameters is a separate counter
+	if value := queryKey(t, addr, "net.if.out[eth1]"); value != noValue {
+		t.Errorf("Unexpected value for first sample of another interface. Expected %q got %q", noValue, value)
+	}
+	if errors := server.Stats().ItemErrors; errors != 0 {
+		t.Errorf("Counters without a value counted as item errors: %d", errors)
+	}
+}
+
+func TestRouterCounterWarmUp(t *testing.T) {
+	t.Parallel()
+
+	bytesSent := 0
+	router := &zbx.Router{}
+	router.RegisterCounter("net.if.out", zbx.HandlerFunc(func(params []string) (interface{}, error) {
+		bytesSent += 1000
+		return bytesSent, nil
+	}))
+
+	// The first sample is taken during warm-up, so the first request from the server has a value
+	server := &zbx.Server{ItemFunc: router.ItemFunc, WarmUpKeys: []string{"net.if.out[eth0]"}}
+	addr := startTestServer(t, server)
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.Stats().WarmedUp {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not warm up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if value := queryKey(t, addr, "net.if.out[eth0]"); strings.HasPrefix(value, "ZBX_NOTSUPPORTED") {
+		t.Errorf("Unexpected value for first request after warm-up: %q", value)
+	}
+}
@@ -0,0 +1,31 @@
+package zbx
+
+// SerializedItems returns an ItemFunc that passes requests to next, except that concurrent requests for the same key of
+// one of the named items share a single call to next instead of running it in parallel. This suits expensive items
+// that are polled by several Zabbix servers or proxies at once. Items are named by their key without parameters, such
+// as "vfs.file.contents", and only requests with identical keys, including parameters, are shared. Requests for other
+// items always call next.
+//
+// Will panic if next is nil.
+func SerializedItems(names []string, next ItemFunc) ItemFunc {
+	if next == nil {
+		panic("next is nil")
+	}
+
+	serialized := map[string]bool{}
+	for _, name := range names {
+		serialized[name] = true
+	}
+	group := &flightGroup{}
+
+	return func(key string) (interface{}, error) {
+		name, _, err := ParseKey(key)
+		if err != nil || !serialized[name] {
+			return next(key)
+		}
+		value, err, _ := group.do(key, func() (interface{}, error) {
+			return next(key)
+		})
+		return value, err
+	}
+}
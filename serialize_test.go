@@ -0,0 +1,135 @@
+package zbx_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestSerializedItems(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	release := make(chan struct{})
+	itemFunc := zbx.SerializedItems([]string{"expensive"}, func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return key, nil
+	})
+
+	keys := []string{"expensive[a]", "expensive[a]", "expensive[a]", "expensive[b]", "cheap", "cheap"}
+	values := make([]interface{}, len(keys))
+	wg := sync.WaitGroup{}
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			values[i], _ = itemFunc(key)
+		}(i, key)
+	}
+
+	// One call for each of expensive[a] and expensive[b], and both requests for cheap
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Item function was not called")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 4 {
+		t.Errorf("Unexpected number of calls. Expected 4 got %d", calls)
+	}
+	for i, key := range keys {
+		if values[i] != key {
+			t.Errorf("Unexpected value for '%s': %v", key, values[i])
+		}
+	}
+}
+
+func TestSerializedItemsPanic(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	itemFunc := zbx.SerializedItems([]string{"expensive"}, func(key string) (interface{}, error) {
+		close(started)
+		<-release
+		panic("boom")
+	})
+
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+		itemFunc("expensive")
+	}()
+	<-started
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := itemFunc("expensive")
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Errorf("Unexpected panic for first caller: %v", r)
+	}
+	if err := <-errs; err == nil {
+		t.Errorf("No error for waiting caller")
+	}
+}
+
+func TestCoalesceRequests(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	release := make(chan struct{})
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return 1, nil
+		},
+		CoalesceRequests: true,
+	}
+	addr := startTestServer(t, server)
+
+	values := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			values <- queryKey(t, addr, "agent.ping")
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) == 0 || server.Stats().Requests < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Requests were not received")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 3; i++ {
+		if value := <-values; value != "1" {
+			t.Errorf("Unexpected value '%s'", value)
+		}
+	}
+
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("Unexpected number of calls. Expected 1 got %d", calls)
+	}
+	if coalesced := server.Stats().CoalescedRequests; coalesced != 2 {
+		t.Errorf("Unexpected number of coalesced requests. Expected 2 got %d", coalesced)
+	}
+}
@@ -0,0 +1,533 @@
+package zbx
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultRequestTimeout is the RequestTimeout of a server that does not have one, which is the longest item timeout of
+// the Zabbix server
+const DefaultRequestTimeout = 30 * time.Second
+
+// Server describes a Zabbix agent that responds to passive checks from the Zabbix server (or proxy). The zero
+// value, with an ItemFunc set, is a valid agent. Options must not be changed once the server has started.
+type Server struct {
+	// ItemFunc is invoked for each item requested from this agent. Required.
+	ItemFunc ItemFunc
+	// PanicAsError controls how a recovered panic from the ItemFunc is reported to the server. When false the server
+	// is told that the key is unknown. When true the server is sent an error with the message "internal error: panic
+	// in handler", so that bugs in item functions are visible to operators.
+	PanicAsError bool
+	// OnPanic is an optional function that is called whenever a panic is recovered from the ItemFunc. It is given
+	// the requested key, the value passed to `panic()` and the stack trace of the panic.
+	OnPanic func(key string, recovered interface{}, stack []byte)
+	// ConnectionIdleTimeout enables persistent connections when greater than 0. After replying to a request the
+	// agent keeps the connection open for further requests, until the server closes it or no request is received
+	// within this duration. When 0 the connection is closed after each reply.
+	ConnectionIdleTimeout time.Duration
+	// TCPKeepAlive is the period between TCP keep-alive probes on accepted connections. When 0 the system default is
+	// used, when negative keep-alive probes are disabled.
+	TCPKeepAlive time.Duration
+	// DisableTCPNoDelay enables Nagle's algorithm on accepted connections, which are otherwise created with
+	// TCP_NODELAY set.
+	DisableTCPNoDelay bool
+	// TCPLinger sets SO_LINGER on accepted connections when greater than 0, so that closing a connection blocks for up
+	// to this duration (rounded to seconds) while unsent data is delivered.
+	TCPLinger time.Duration
+	// InvalidRequestLimit enables protection against remote hosts that repeatedly send malformed requests, such as
+	// scanners, when greater than 0. Once a host has sent this many malformed requests within InvalidRequestWindow the
+	// TarpitDelay and InvalidRequestBan options apply to it.
+	InvalidRequestLimit int
+	// InvalidRequestWindow is the period in which malformed requests from a host are counted. Defaults to one minute.
+	InvalidRequestWindow time.Duration
+	// TarpitDelay is how long to wait before closing the connection after a malformed request from a host that has
+	// exceeded InvalidRequestLimit.
+	TarpitDelay time.Duration
+	// InvalidRequestBan is how long connections are closed without being read from a host that has exceeded
+	// InvalidRequestLimit.
+	InvalidRequestBan time.Duration
+	// StatsItems enables the internal zbx.stats items, which report the counters of this server to Zabbix so that
+	// the agent itself can be monitored. See Server.Stats for the available counters.
+	StatsItems bool
+	// ValueSanitizer is an optional function that is applied to every value, after it has been formatted as a string,
+	// before it is sent to the server. Set it to SanitizeValue to clean up values that contain invalid UTF-8 or control
+	// characters, or to a custom function. It is not applied to error messages.
+	ValueSanitizer func(value string) string
+	// MaxValueLength is the maximum length in bytes of a value sent to the server, when greater than 0. The Zabbix
+	// server truncates or rejects long values depending on the type of item, so sending them wastes bandwidth. What
+	// is done with longer values is controlled by OversizedValues.
+	MaxValueLength int
+	// OversizedValues is the policy for values longer than MaxValueLength. Defaults to TruncateOversizedValues.
+	OversizedValues OversizedValuePolicy
+	// TruncationIndicator is the text that ends values that were truncated, and counts towards MaxValueLength.
+	// Defaults to DefaultTruncationIndicator.
+	TruncationIndicator string
+	// SlowItemThreshold is how long an item can take to return its value before Server.Validate reports it as slow.
+	// Defaults to 3 seconds, the default item timeout of the Zabbix server.
+	SlowItemThreshold time.Duration
+	// ShedIdleConnections enables closing the persistent connection that has been idle the longest whenever the
+	// listener fails to accept a connection because the process or system has run out of file descriptors, freeing
+	// one up for new connections. Only applies when ConnectionIdleTimeout is greater than 0.
+	ShedIdleConnections bool
+	// OnRequest is an optional function that is called when a well-formed request is received, before the value is
+	// requested, with the remote address of the connection and the requested key. If it returns a function, that
+	// function is called once the value has been determined with the value, which is nil for unknown keys, and any
+	// error. This allows requests to be traced or timed, such as with the otelzbx module.
+	OnRequest func(remoteAddr string, key string) func(value interface{}, err error)
+	// OnResult is an optional function that is called with the value and error returned for each requested key, and
+	// how long they took to get, before the reply is sent. The value and error it returns are replied instead, which
+	// allows values to be rewritten, such as to redact secrets or normalize units, or replaced entirely. Returning
+	// (nil, nil) replies that the key is unknown. It is not called for requests refused during maintenance. If it
+	// replaces a value that is an io.Reader, it must close the reader if needed.
+	OnResult func(key string, value interface{}, err error, duration time.Duration) (interface{}, error)
+	// ErrorHandler is an optional handler that is given every error encountered by the server as a structured event,
+	// in addition to the message written to ErrorLog. This allows errors to be routed to an error tracking or alerting
+	// system. Wrap a handler with RateLimitErrors to suppress repeated errors.
+	ErrorHandler ErrorHandler
+	// MaxErrorLength is the longest error message, in characters, that is replied to the server for items that return
+	// an error. Longer messages are shortened to keep the root cause of the error, the last error in its Unwrap chain,
+	// as the server truncates the message and would otherwise cut it off. ErrorLog and the ErrorHandler are always
+	// given the full error. Defaults to DefaultMaxErrorLength.
+	MaxErrorLength int
+	// ProtocolErrorReplies enables replying to requests that are rejected because of a protocol problem, such as
+	// unsupported flags (including compression) or a request that is too large, with a ZBX_NOTSUPPORTED message
+	// describing the problem before the connection is closed. Otherwise the connection is closed without a reply, which
+	// the Zabbix server reports as a generic network error. Requests that do not start with the ZBXD header are never
+	// replied to.
+	ProtocolErrorReplies bool
+	// StrictReservedBytes enables rejecting requests with a non-zero reserved field in their header as a protocol
+	// error. Otherwise the field is ignored, as it is by the Zabbix agent, and a warning is written to ErrorLog, since
+	// some proxies and tools do not zero it.
+	StrictReservedBytes bool
+	// PeerSelector is an optional function that chooses the items served to a connection, so that different Zabbix
+	// servers or proxies querying this agent can be given different items, such as real data for the production proxy
+	// and synthetic data for a staging proxy. It is called once for each connection, after the TLS handshake, and
+	// returns the ItemFunc used for requests on that connection, or nil to use ItemFunc. See NetworkSelector.
+	PeerSelector func(peer Peer) ItemFunc
+	// OnListen is an optional function that is called once the server has started listening, with the address of
+	// the listener. This is useful when listening on port 0 to learn the chosen port.
+	OnListen func(addr net.Addr)
+	// OnTLSHandshake is an optional function that is called once the TLS handshake with a server has completed, or
+	// failed, on a TLS listener. It is given the remote address of the connection, the negotiated connection state
+	// (including the version, cipher suite and any peer certificates), and the error if the handshake failed. This is
+	// useful for diagnosing TLS interoperability with Zabbix servers and proxies.
+	OnTLSHandshake func(remoteAddr net.Addr, state tls.ConnectionState, err error)
+	// TLSKeyLogWriter is an optional writer that TLS master secrets are written to in NSS key log format, allowing
+	// the traffic of TLS listeners to be decrypted by tools such as Wireshark. It is only used if the TLS
+	// configuration does not already have a KeyLogWriter. Use of this option compromises security and should only be
+	// used for debugging.
+	TLSKeyLogWriter io.Writer
+	// Clock is the source of time for the server, used for its uptime, invalid request bans, tarpit delays and idle
+	// connections. Defaults to SystemClock.
+	Clock Clock
+	// StreamSpoolDir is the directory that streamed values of unknown length are written to before being sent, so
+	// that their length is known. Defaults to the directory returned by os.TempDir.
+	StreamSpoolDir string
+	// MaxRequestSize is the largest request accepted from the server in bytes, when greater than 0, limiting the memory
+	// used by each connection. Defaults to the limit of the protocol, just under 128MiB.
+	MaxRequestSize int
+	// MaxInFlightMemory limits the total size in bytes of the requests being read and the replies being written by all
+	// connections at once, when greater than 0, so that a burst of large requests cannot exhaust the memory of the
+	// application. Connections wait up to RequestTimeout for memory to be released by others before reading a request
+	// or sending a reply, and stop waiting if the server is closed. Requests larger than this limit are rejected, and
+	// replies larger than it are sent as ErrValueTooLarge. A reply is only counted once its value has been formatted,
+	// so the limit holds back writing it but does not limit the memory used by the ItemFunc or to format values.
+	// Streamed values are not held in memory and do not count towards it.
+	MaxInFlightMemory int64
+	// RequestTimeout is how long a request can take to arrive once its first byte has been read, how long a connection
+	// that is not persistent waits for a request, and how long connections wait for MaxInFlightMemory before giving up.
+	// Defaults to DefaultRequestTimeout.
+	RequestTimeout time.Duration
+	// Profiling enables the ProfilingHandler and ServeProfiling, which serve runtime profiles of the application for
+	// troubleshooting, and labels the profiles with the key of the item being requested.
+	Profiling bool
+	// CoalesceRequests enables sharing the value of an item between requests for the same key that arrive while it is
+	// being determined, so that duplicate simultaneous polls only call the ItemFunc once. Shared requests are counted
+	// in Stats.CoalescedRequests. It is not applied when PeerSelector is set, as connections from different peers may
+	// be served by different item functions. See SerializedItems to share calls for only some items.
+	CoalesceRequests bool
+	// MaintenanceMessage is the error sent for every key while the server is in maintenance mode, see
+	// Server.SetMaintenance. Defaults to the message of ErrMaintenance.
+	MaintenanceMessage string
+	// WarmUpKeys are requested once when the server first starts serving, in the background, to fill caches and so
+	// that broken items are written to ErrorLog and the ErrorHandler immediately instead of when the Zabbix server
+	// first polls them. Unknown keys are reported with ErrUnknownKey. Stats.WarmedUp is set once all keys have
+	// returned.
+	WarmUpKeys []string
+	// WarmUpConcurrency is how many WarmUpKeys are requested at once. Defaults to DefaultWarmUpConcurrency.
+	WarmUpConcurrency int
+	// ValueTypes declares the type of information of items, by the name of their key without parameters, such as
+	// "app.queue.depth". Values that the Zabbix server would reject for the type are sent as an error naming the key
+	// and value instead, and numbers are formatted without an exponent. Keys without a declared type are not checked.
+	ValueTypes map[string]ValueType
+
+	listenerLock        sync.Mutex
+	listener            net.Listener
+	profilingListeners  []net.Listener
+	serving             bool
+	ready               chan struct{}
+	isReady             bool
+	statsLock           sync.Mutex
+	stats               Stats
+	invalidRequestsLock sync.Mutex
+	invalidRequests     map[string]*invalidRequests
+	idleLock            sync.Mutex
+	idleConns           map[net.Conn]time.Time
+	connsLock           sync.Mutex
+	conns               map[net.Conn]struct{}
+	closing             bool
+	memoryLock          sync.Mutex
+	memoryReleased      *sync.Cond
+	memoryInUse         int64
+	coalesced           flightGroup
+	maintenanceLock     sync.Mutex
+	maintenance         bool
+}
+
+// ListenAndServe starts the Zabbix agent on the specified address. Will block and always return on error,
+// including when the listener is closed.
+func (s *Server) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS starts the Zabbix agent on the specified address with TLS. The agent will present the given
+// certificate to the server when connected. Will block and always return on error, including when the listener is
+// closed.
+func (s *Server) ListenAndServeTLS(address string, certificate tls.Certificate) error {
+	return s.ListenAndServeTLSConfig(address, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	})
+}
+
+// ListenAndServeTLSConfig starts the Zabbix agent on the specified address with TLS using the given configuration,
+// for when more control is needed than ListenAndServeTLS provides, such as requiring client certificates from the
+// server. The configuration must contain at least one certificate or set GetCertificate, and must not be modified
+// once the server has started. Will block and always return on error, including when the listener is closed.
+func (s *Server) ListenAndServeTLSConfig(address string, config *tls.Config) error {
+	if config == nil {
+		panic("config is nil")
+	}
+	if s.TLSKeyLogWriter != nil && config.KeyLogWriter == nil {
+		config = config.Clone()
+		config.KeyLogWriter = s.TLSKeyLogWriter
+	}
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(tls.NewListener(&tunedListener{Listener: l, server: s}, config))
+}
+
+// Serve starts the Zabbix agent on the specified listener. The TCP options of the server are applied to accepted
+// connections of a TCP listener. Will block until the listener returns a permanent error,
+// such as when it is closed, and then returns that error. Temporary errors accepting connections are retried with a
+// backoff. Will panic if ItemFunc is nil.
+func (s *Server) Serve(l net.Listener) error {
+	if s.ItemFunc == nil {
+		panic("itemFunc is nil")
+	}
+
+	s.connsLock.Lock()
+	s.closing = false
+	s.connsLock.Unlock()
+
+	s.listenerLock.Lock()
+	s.listener = l
+	s.serving = true
+	if !s.isReady {
+		s.isReady = true
+		close(s.readyChannel())
+		if len(s.WarmUpKeys) > 0 {
+			go s.warmUp()
+		}
+	}
+	s.listenerLock.Unlock()
+	defer func() {
+		s.listenerLock.Lock()
+		s.serving = false
+		s.listenerLock.Unlock()
+	}()
+	if s.OnListen != nil {
+		s.OnListen(l.Addr())
+	}
+
+	s.updateStats(func(stats *Stats) {
+		if stats.Started.IsZero() {
+			stats.Started = s.clock().Now()
+		}
+	})
+
+	var retryDelay time.Duration
+	exhaustedErrors := 0
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if retryDelay == 0 {
+					retryDelay = 5 * time.Millisecond
+				} else {
+					retryDelay *= 2
+				}
+				if retryDelay > time.Second {
+					retryDelay = time.Second
+				}
+				s.updateStats(func(stats *Stats) {
+					stats.AcceptErrors++
+				})
+				if isResourceExhausted(err) {
+					// Only log the start of the condition, as it may last for some time
+					if exhaustedErrors == 0 {
+						errorWrite("Out of resources accepting connections, pausing: %s", fmt.Sprintf("error='%s'", err.Error()))
+						s.handleError(ErrorEvent{Category: ErrorCategoryAccept, Err: err})
+						s.updateStats(func(stats *Stats) {
+							stats.Exhausted = true
+						})
+					}
+					exhaustedErrors++
+					if s.ShedIdleConnections {
+						s.shedIdleConnection()
+					}
+				} else {
+					errorWrite("Error accepting connection: %s,%s", fmt.Sprintf("error='%s'", err.Error()), fmt.Sprintf("retry_in='%s'", retryDelay))
+					s.handleError(ErrorEvent{Category: ErrorCategoryAccept, Err: err})
+				}
+				s.sleep(retryDelay)
+				continue
+			}
+			if !errors.Is(err, net.ErrClosed) {
+				errorWrite("Error accepting connection: %s", fmt.Sprintf("error='%s'", err.Error()))
+				s.handleError(ErrorEvent{Category: ErrorCategoryAccept, Err: err})
+			}
+			return err
+		}
+		retryDelay = 0
+		if exhaustedErrors > 0 {
+			errorWrite("Accepting connections again: %s", fmt.Sprintf("errors=%d", exhaustedErrors))
+			exhaustedErrors = 0
+			s.updateStats(func(stats *Stats) {
+				stats.Exhausted = false
+			})
+		}
+		s.updateStats(func(stats *Stats) {
+			stats.Connections++
+			stats.OpenConnections++
+		})
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			s.tuneConn(tcpConn)
+		}
+		go s.newConnection(conn)
+	}
+}
+
+// Addr returns the address of the listener of this server, or nil if the server has not started listening.
+func (s *Server) Addr() net.Addr {
+	s.listenerLock.Lock()
+	defer s.listenerLock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Ready returns a channel that is closed once the server is accepting connections. If the server fails to start
+// listening the channel is never closed, so callers should also watch for an error from the method that started the
+// server:
+//
+//	errs := make(chan error, 1)
+//	go func() { errs <- server.ListenAndServe(address) }()
+//	select {
+//	case <-server.Ready():
+//	case err := <-errs:
+//	}
+func (s *Server) Ready() <-chan struct{} {
+	s.listenerLock.Lock()
+	defer s.listenerLock.Unlock()
+	return s.readyChannel()
+}
+
+// readyChannel returns the ready channel, creating it if needed. The caller must hold listenerLock.
+func (s *Server) readyChannel() chan struct{} {
+	if s.ready == nil {
+		s.ready = make(chan struct{})
+	}
+	return s.ready
+}
+
+// Close stops the server from accepting new connections, causing the method that started the server to return, and
+// closes all open connections, including any that are waiting for a reply. Any unix sockets opened by ServeProfiling
+// are also closed. Otherwise does nothing if the server has not started listening.
+func (s *Server) Close() error {
+	s.listenerLock.Lock()
+	defer s.listenerLock.Unlock()
+
+	for _, l := range s.profilingListeners {
+		l.Close()
+	}
+	s.profilingListeners = nil
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+
+	s.connsLock.Lock()
+	s.closing = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.connsLock.Unlock()
+	// Connections waiting for memory are not reading from their connection, so must be woken up
+	s.wakeMemoryWaiters()
+
+	return err
+}
+
+// isClosing returns true if the server has been closed
+func (s *Server) isClosing() bool {
+	s.connsLock.Lock()
+	defer s.connsLock.Unlock()
+	return s.closing
+}
+
+// requestTimeout returns the RequestTimeout of the server, or the default
+func (s *Server) requestTimeout() time.Duration {
+	if s.RequestTimeout <= 0 {
+		return DefaultRequestTimeout
+	}
+	return s.RequestTimeout
+}
+
+// trackConn records if conn is open, so that it can be closed by Close. Returns false if the server is closing, in
+// which case conn must not be used.
+func (s *Server) trackConn(conn net.Conn, open bool) bool {
+	s.connsLock.Lock()
+	defer s.connsLock.Unlock()
+
+	if !open {
+		delete(s.conns, conn)
+		return true
+	}
+	if s.closing {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]struct{}{}
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// tuneConn applies the TCP options of the server to conn
+func (s *Server) tuneConn(conn *net.TCPConn) {
+	if s.TCPKeepAlive < 0 {
+		conn.SetKeepAlive(false)
+	} else if s.TCPKeepAlive > 0 {
+		conn.SetKeepAlive(true)
+		conn.SetKeepAlivePeriod(s.TCPKeepAlive)
+	}
+	if s.DisableTCPNoDelay {
+		conn.SetNoDelay(false)
+	}
+	if s.TCPLinger > 0 {
+		conn.SetLinger(int(s.TCPLinger.Round(time.Second) / time.Second))
+	}
+}
+
+// tunedListener applies the TCP options of the server to accepted connections before they are wrapped, such as by
+// a TLS listener
+type tunedListener struct {
+	net.Listener
+	server *Server
+}
+
+func (l *tunedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		l.server.tuneConn(tcpConn)
+	}
+	return conn, nil
+}
+
+// handshake completes the TLS handshake of conn, reporting the result to OnTLSHandshake
+func (s *Server) handshake(conn *tls.Conn, who string) error {
+	timeout := s.requestTimeout()
+	if s.ConnectionIdleTimeout > 0 {
+		timeout = s.ConnectionIdleTimeout
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	err := conn.Handshake()
+	if err != nil {
+		peerErrorWrite(who, "TLS handshake failed: %s", fmt.Sprintf("error='%s'", err.Error()))
+		s.handleError(ErrorEvent{Category: ErrorCategoryTLS, Peer: who, Err: err})
+	}
+	if s.OnTLSHandshake != nil {
+		s.OnTLSHandshake(conn.RemoteAddr(), conn.ConnectionState(), err)
+	}
+	return err
+}
+
+// isResourceExhausted returns true if err is caused by the process or system running out of file descriptors or
+// memory
+func isResourceExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) ||
+		errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ENOBUFS) ||
+		errors.Is(err, syscall.ENOMEM)
+}
+
+// setIdle records if the persistent connection conn is idle, waiting for the next request
+func (s *Server) setIdle(conn net.Conn, idle bool) {
+	s.idleLock.Lock()
+	defer s.idleLock.Unlock()
+
+	if !idle {
+		delete(s.idleConns, conn)
+		return
+	}
+	if s.idleConns == nil {
+		s.idleConns = map[net.Conn]time.Time{}
+	}
+	s.idleConns[conn] = s.clock().Now()
+}
+
+// shedIdleConnection closes the persistent connection that has been idle the longest, if any
+func (s *Server) shedIdleConnection() {
+	s.idleLock.Lock()
+	var oldest net.Conn
+	var oldestSince time.Time
+	for conn, since := range s.idleConns {
+		if oldest == nil || since.Before(oldestSince) {
+			oldest = conn
+			oldestSince = since
+		}
+	}
+	if oldest != nil {
+		delete(s.idleConns, oldest)
+	}
+	s.idleLock.Unlock()
+
+	if oldest == nil {
+		return
+	}
+	oldest.Close()
+	s.updateStats(func(stats *Stats) {
+		stats.ShedConnections++
+	})
+}
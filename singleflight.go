@@ -0,0 +1,67 @@
+package zbx
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// flightGroup shares the result of a call between concurrent callers with the same key
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done     chan struct{}
+	value    interface{}
+	err      error
+	panicked interface{}
+}
+
+// do calls f for key and returns its result, unless a call for key is already in flight, in which case it waits for
+// that call and returns its result instead. shared is true if the result came from the call of another caller.
+//
+// A panic in f is passed on to the caller that made the call, while the callers waiting for it get an error. Values
+// that are an io.Reader can only be read once, so callers waiting for one make their own call.
+func (g *flightGroup) do(key string, f func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.lock.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	if call, ok := g.flights[key]; ok {
+		g.lock.Unlock()
+		<-call.done
+
+		if call.panicked != nil {
+			return nil, fmt.Errorf("internal error: panic in shared call: %v", call.panicked), true
+		}
+		if _, ok := call.value.(io.Reader); ok {
+			value, err = f()
+			return value, err, false
+		}
+		return call.value, call.err, true
+	}
+	call := &flight{done: make(chan struct{})}
+	g.flights[key] = call
+	g.lock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.panicked = r
+			g.finish(key, call)
+			panic(r)
+		}
+		g.finish(key, call)
+	}()
+	call.value, call.err = f()
+	return call.value, call.err, false
+}
+
+// finish removes call from the group and wakes the callers waiting for it
+func (g *flightGroup) finish(key string, call *flight) {
+	g.lock.Lock()
+	delete(g.flights, key)
+	g.lock.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,358 @@
+/*
+Package smart provides item values for disk health, collected with smartctl from smartmontools, using the same keys
+and low-level discovery macros as the official "SMART by Zabbix agent 2" template.
+
+smartctl version 7.0 or newer must be installed, and the agent must have permission to read the disks, which usually
+requires running as root.
+*/
+package smart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// Disk types reported in discovery and by smart.disk.get
+const (
+	DiskTypeHDD  = "hdd"
+	DiskTypeSSD  = "ssd"
+	DiskTypeNVMe = "nvme"
+)
+
+// smartctlTimeout is how long smartctl can run before it is killed, which is below the longest item timeout of the
+// Zabbix server
+const smartctlTimeout = 10 * time.Second
+
+// runSmartctl runs smartctl with the given arguments and returns its JSON output
+var runSmartctl = func(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), smartctlTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "smartctl", args...).Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("error running smartctl: timed out after %s", smartctlTimeout)
+	}
+	var exitErr *exec.ExitError
+	// The exit status of smartctl is a bit mask, only the first two bits mean that no information was returned
+	if errors.As(err, &exitErr) && exitErr.ExitCode()&0x3 == 0 {
+		return output, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error running smartctl: %s", err.Error())
+	}
+	return output, nil
+}
+
+type scanResult struct {
+	Devices []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"devices"`
+}
+
+type attribute struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Value  int    `json:"value"`
+	Worst  int    `json:"worst"`
+	Thresh int    `json:"thresh"`
+	Raw    struct {
+		Value  int64  `json:"value"`
+		String string `json:"string"`
+	} `json:"raw"`
+}
+
+type deviceInfo struct {
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	RotationRate *int   `json:"rotation_rate"`
+	Device       struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ATASmartAttributes struct {
+		Table []attribute `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+func (d deviceInfo) diskType() string {
+	if strings.EqualFold(d.Device.Protocol, "NVMe") {
+		return DiskTypeNVMe
+	}
+	if d.RotationRate != nil && *d.RotationRate > 0 {
+		return DiskTypeHDD
+	}
+	return DiskTypeSSD
+}
+
+// scannedDevice is a disk found by smartctl --scan
+type scannedDevice struct {
+	path string
+	// deviceType is the smartctl device type, such as sat or nvme. For disks behind a RAID controller this includes
+	// the disk number, such as megaraid,0.
+	deviceType string
+}
+
+// raidType returns the device type for disks behind a RAID controller, or an empty string for other disks
+func (d scannedDevice) raidType() string {
+	if strings.Contains(d.deviceType, ",") {
+		return d.deviceType
+	}
+	return ""
+}
+
+// id uniquely identifies the disk, since every disk behind a RAID controller has the same path
+func (d scannedDevice) id() string {
+	return d.path + " " + d.raidType()
+}
+
+// device is the most recent output of smartctl for a disk
+type device struct {
+	scannedDevice
+	raw  map[string]interface{}
+	info deviceInfo
+}
+
+// Collector collects disk health from smartctl. Since reading SMART data can be slow, and polling disks too often can
+// wake them from standby, the list of disks and the data for each disk are cached.
+type Collector struct {
+	// CacheFor is how long results from smartctl are used before it is run again
+	CacheFor time.Duration
+	// Clock is the source of time for CacheFor, zbx.SystemClock if nil
+	Clock zbx.Clock
+
+	lock    sync.Mutex
+	scanned time.Time
+	devices []scannedDevice
+	data    map[string]*device
+	expires map[string]time.Time
+}
+
+// Items returns an ItemFunc for the smart.* keys below. Any other key, even one that cannot be parsed, is left to next,
+// and is unknown if next is nil.
+//
+//   - smart.disk.discovery, a JSON array for low-level discovery of disks with the {#NAME}, {#DISKTYPE}, {#MODEL},
+//     {#SN}, {#PATH} and {#RAIDTYPE} macros.
+//   - smart.disk.get[<path>,<raid type>], the smartctl JSON output for the disk at path, with an added disk_type
+//     property. Raid type is only needed for disks behind a RAID controller, and is the {#RAIDTYPE} of the disk.
+//   - smart.attribute.discovery, a JSON array for low-level discovery of the SMART attributes of ATA disks with the
+//     {#NAME}, {#DISKTYPE}, {#ID}, {#ATTRNAME} and {#THRESH} macros.
+//   - smart.attribute[<path>,<id>,<raid type>], the raw value of a SMART attribute.
+//
+// Only disks found by smartctl --scan can be requested.
+func (c *Collector) Items(next zbx.ItemFunc) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil {
+			// The key is not one of these, but may still be known to next
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		switch name {
+		case "smart.disk.discovery":
+			return c.diskDiscovery()
+		case "smart.attribute.discovery":
+			return c.attributeDiscovery()
+		case "smart.disk.get":
+			if len(params) == 0 || len(params) > 2 || params[0] == "" {
+				return nil, fmt.Errorf("invalid number of parameters")
+			}
+			d, err := c.device(params[0], param(params, 1))
+			if err != nil {
+				return nil, err
+			}
+			return zbx.JSON(d.raw)
+		case "smart.attribute":
+			if len(params) < 2 || len(params) > 3 || params[0] == "" {
+				return nil, fmt.Errorf("invalid number of parameters")
+			}
+			id, err := strconv.Atoi(params[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid attribute id '%s'", params[1])
+			}
+			d, err := c.device(params[0], param(params, 2))
+			if err != nil {
+				return nil, err
+			}
+			for _, attr := range d.info.ATASmartAttributes.Table {
+				if attr.ID == id {
+					return attr.Raw.Value, nil
+				}
+			}
+			return nil, fmt.Errorf("disk '%s' has no attribute %d", d.path, id)
+		}
+
+		if next == nil {
+			return nil, nil
+		}
+		return next(key)
+	}
+}
+
+// now returns the current time from the Clock of the collector
+func (c *Collector) now() time.Time {
+	if c.Clock == nil {
+		return zbx.SystemClock.Now()
+	}
+	return c.Clock.Now()
+}
+
+func param(params []string, i int) string {
+	if i < len(params) {
+		return params[i]
+	}
+	return ""
+}
+
+// scan returns every disk, scanning for disks if the cached list has expired. The lock must be held.
+func (c *Collector) scan() ([]scannedDevice, error) {
+	if c.devices != nil && c.now().Sub(c.scanned) < c.CacheFor {
+		return c.devices, nil
+	}
+
+	output, err := runSmartctl("--scan", "--json")
+	if err != nil {
+		return nil, err
+	}
+	result := scanResult{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("invalid smartctl output: %s", err.Error())
+	}
+
+	c.devices = make([]scannedDevice, len(result.Devices))
+	for i, d := range result.Devices {
+		c.devices[i] = scannedDevice{path: d.Name, deviceType: d.Type}
+	}
+	c.scanned = c.now()
+	return c.devices, nil
+}
+
+// device returns the data for the disk at path, running smartctl if the cached data has expired
+func (c *Collector) device(path string, raidType string) (*device, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/dev/" + path
+	}
+	devices, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	var scanned *scannedDevice
+	for i, d := range devices {
+		if d.path == path && (raidType == "" || d.raidType() == raidType) {
+			scanned = &devices[i]
+			break
+		}
+	}
+	if scanned == nil {
+		return nil, fmt.Errorf("unknown disk '%s'", strings.TrimSpace(path+" "+raidType))
+	}
+	return c.read(*scanned)
+}
+
+// read runs smartctl for the disk if the cached data has expired. The lock must be held.
+func (c *Collector) read(scanned scannedDevice) (*device, error) {
+	if d, ok := c.data[scanned.id()]; ok && c.now().Before(c.expires[scanned.id()]) {
+		return d, nil
+	}
+
+	args := []string{"--all", "--json", scanned.path}
+	if scanned.deviceType != "" {
+		args = []string{"--all", "--json", "--device", scanned.deviceType, scanned.path}
+	}
+	output, err := runSmartctl(args...)
+	if err != nil {
+		return nil, err
+	}
+	d := &device{scannedDevice: scanned}
+	if err := json.Unmarshal(output, &d.raw); err != nil {
+		return nil, fmt.Errorf("invalid smartctl output: %s", err.Error())
+	}
+	if err := json.Unmarshal(output, &d.info); err != nil {
+		return nil, fmt.Errorf("invalid smartctl output: %s", err.Error())
+	}
+	d.raw["disk_type"] = d.info.diskType()
+
+	if c.data == nil {
+		c.data = map[string]*device{}
+		c.expires = map[string]time.Time{}
+	}
+	c.data[scanned.id()] = d
+	c.expires[scanned.id()] = c.now().Add(c.CacheFor)
+	return d, nil
+}
+
+// allDevices returns the data for every disk, skipping disks that smartctl can not read
+func (c *Collector) allDevices() ([]*device, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	scanned, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	devices := []*device{}
+	for _, s := range scanned {
+		d, err := c.read(s)
+		if err != nil {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+func (c *Collector) diskDiscovery() (string, error) {
+	devices, err := c.allDevices()
+	if err != nil {
+		return "", err
+	}
+
+	rows := []map[string]string{}
+	for _, d := range devices {
+		rows = append(rows, map[string]string{
+			"{#NAME}":     strings.TrimPrefix(d.path, "/dev/"),
+			"{#DISKTYPE}": d.info.diskType(),
+			"{#MODEL}":    d.info.ModelName,
+			"{#SN}":       d.info.SerialNumber,
+			"{#PATH}":     d.path,
+			"{#RAIDTYPE}": d.raidType(),
+		})
+	}
+	return zbx.JSON(rows)
+}
+
+func (c *Collector) attributeDiscovery() (string, error) {
+	devices, err := c.allDevices()
+	if err != nil {
+		return "", err
+	}
+
+	rows := []map[string]interface{}{}
+	for _, d := range devices {
+		for _, attr := range d.info.ATASmartAttributes.Table {
+			rows = append(rows, map[string]interface{}{
+				"{#NAME}":     strings.TrimPrefix(d.path, "/dev/"),
+				"{#DISKTYPE}": d.info.diskType(),
+				"{#ID}":       attr.ID,
+				"{#ATTRNAME}": attr.Name,
+				"{#THRESH}":   attr.Thresh,
+			})
+		}
+	}
+	return zbx.JSON(rows)
+}
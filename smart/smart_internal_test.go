@@ -0,0 +1,173 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+const testScan = `{"devices":[
+	{"name":"/dev/sda","info_name":"/dev/sda [SAT]","type":"sat","protocol":"ATA"},
+	{"name":"/dev/nvme0","info_name":"/dev/nvme0","type":"nvme","protocol":"NVMe"},
+	{"name":"/dev/bus/0","info_name":"/dev/bus/0 [megaraid_disk_00]","type":"megaraid,0","protocol":"SCSI"}
+]}`
+
+const testSATA = `{
+	"device":{"name":"/dev/sda","type":"sat","protocol":"ATA"},
+	"model_name":"WDC WD40EFRX","serial_number":"WD-1234","rotation_rate":5400,
+	"smart_status":{"passed":true},
+	"ata_smart_attributes":{"table":[
+		{"id":5,"name":"Reallocated_Sector_Ct","value":200,"worst":200,"thresh":140,"raw":{"value":3,"string":"3"}},
+		{"id":194,"name":"Temperature_Celsius","value":112,"worst":100,"thresh":0,"raw":{"value":38,"string":"38"}}
+	]}
+}`
+
+const testNVMe = `{
+	"device":{"name":"/dev/nvme0","type":"nvme","protocol":"NVMe"},
+	"model_name":"Samsung SSD 980","serial_number":"S64A",
+	"smart_status":{"passed":true}
+}`
+
+const testRAID = `{
+	"device":{"name":"/dev/bus/0","type":"megaraid,0","protocol":"SCSI"},
+	"model_name":"SEAGATE ST600","serial_number":"Z1Z","rotation_rate":10000,
+	"smart_status":{"passed":false}
+}`
+
+// testClock is a zbx.Clock that only moves when the test sets it
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) NewTimer(d time.Duration) zbx.Timer {
+	return zbx.SystemClock.NewTimer(d)
+}
+
+func TestCollector(t *testing.T) {
+	calls := 0
+	runSmartctl = func(args ...string) ([]byte, error) {
+		calls++
+		switch strings.Join(args, " ") {
+		case "--scan --json":
+			return []byte(testScan), nil
+		case "--all --json --device sat /dev/sda":
+			return []byte(testSATA), nil
+		case "--all --json --device nvme /dev/nvme0":
+			return []byte(testNVMe), nil
+		case "--all --json --device megaraid,0 /dev/bus/0":
+			return []byte(testRAID), nil
+		}
+		return nil, fmt.Errorf("unexpected arguments %q", args)
+	}
+
+	clock := &testClock{now: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)}
+	collector := &Collector{CacheFor: time.Minute, Clock: clock}
+	items := collector.Items(nil)
+
+	value, err := items("smart.disk.discovery")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	disks := []map[string]string{}
+	if err := json.Unmarshal([]byte(value.(string)), &disks); err != nil {
+		t.Fatalf("Invalid JSON: %s", err.Error())
+	}
+	if len(disks) != 3 {
+		t.Fatalf("Unexpected number of disks %d", len(disks))
+	}
+	expected := []map[string]string{
+		{"{#NAME}": "sda", "{#DISKTYPE}": DiskTypeHDD, "{#MODEL}": "WDC WD40EFRX", "{#SN}": "WD-1234", "{#PATH}": "/dev/sda", "{#RAIDTYPE}": ""},
+		{"{#NAME}": "nvme0", "{#DISKTYPE}": DiskTypeNVMe, "{#MODEL}": "Samsung SSD 980", "{#SN}": "S64A", "{#PATH}": "/dev/nvme0", "{#RAIDTYPE}": ""},
+		{"{#NAME}": "bus/0", "{#DISKTYPE}": DiskTypeHDD, "{#MODEL}": "SEAGATE ST600", "{#SN}": "Z1Z", "{#PATH}": "/dev/bus/0", "{#RAIDTYPE}": "megaraid,0"},
+	}
+	for i := range expected {
+		for macro, want := range expected[i] {
+			if disks[i][macro] != want {
+				t.Errorf("Unexpected %s for disk %d. Expected '%s' got '%s'", macro, i, want, disks[i][macro])
+			}
+		}
+	}
+
+	// Everything should now be cached
+	calls = 0
+	if value, err := items("smart.attribute[sda,5]"); err != nil || value != int64(3) {
+		t.Errorf("Unexpected attribute value: %v %v", value, err)
+	}
+	if value, err := items("smart.attribute[/dev/sda,194]"); err != nil || value != int64(38) {
+		t.Errorf("Unexpected attribute value: %v %v", value, err)
+	}
+	if _, err := items("smart.attribute[sda,1]"); err == nil {
+		t.Errorf("No error seen for missing attribute")
+	}
+	if calls != 0 {
+		t.Errorf("Unexpected calls to smartctl for cached data: %d", calls)
+	}
+
+	// Scanning and reading the disk again once the cache has expired
+	clock.now = clock.now.Add(time.Minute)
+	if value, err := items("smart.attribute[sda,5]"); err != nil || value != int64(3) {
+		t.Errorf("Unexpected attribute value: %v %v", value, err)
+	}
+	if calls != 2 {
+		t.Errorf("Unexpected calls to smartctl for expired data: %d", calls)
+	}
+
+	value, err = items("smart.disk.get[/dev/bus/0,megaraid,0]")
+	if err == nil {
+		t.Errorf("No error seen for unquoted raid type")
+	}
+	value, err = items(`smart.disk.get[/dev/bus/0,"megaraid,0"]`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	disk := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value.(string)), &disk); err != nil {
+		t.Fatalf("Invalid JSON: %s", err.Error())
+	}
+	if disk["disk_type"] != DiskTypeHDD || disk["smart_status"].(map[string]interface{})["passed"] != false {
+		t.Errorf("Unexpected disk data: %v", disk)
+	}
+
+	attributes := []map[string]interface{}{}
+	value, err = items("smart.attribute.discovery")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(value.(string)), &attributes); err != nil {
+		t.Fatalf("Invalid JSON: %s", err.Error())
+	}
+	if len(attributes) != 2 || attributes[0]["{#ATTRNAME}"] != "Reallocated_Sector_Ct" || attributes[0]["{#THRESH}"] != float64(140) {
+		t.Errorf("Unexpected attribute discovery: %v", attributes)
+	}
+
+	// Only disks found by scanning can be requested
+	if _, err := items("smart.disk.get[--scan-open]"); err == nil {
+		t.Errorf("No error seen for unknown disk")
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := (&Collector{}).Items(func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	})
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := (&Collector{}).Items(nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/ecnepsnai/zbx/snmpzbx
+
+go 1.17
+
+require (
+	github.com/ecnepsnai/zbx v0.0.0-20261016123541-ef93aff01069
+	github.com/gosnmp/gosnmp v1.32.0
+)
@@ -0,0 +1,179 @@
+// Package snmpzbx provides snmp.get and snmp.walk items that proxy requests to SNMP devices, letting an agent built
+// with zbx act as a gateway for devices that the Zabbix server cannot reach directly.
+//
+// It is a separate module so that the zbx package itself does not depend on gosnmp.
+package snmpzbx
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/gosnmp/gosnmp"
+)
+
+// Items returns an ItemFunc that proxies the keys below to the SNMP devices in targets. Any other key, including one
+// that cannot be parsed, goes to next, or is unknown if next is nil.
+//
+//   - snmp.get[<target>,<oid>], the value of a single OID.
+//   - snmp.walk[<target>,<oid>], every OID under oid, one per line formatted as "<oid> = <type>: <value>".
+//
+// Target is the name of one of targets, which are used as templates for the connection to the device: a copy of the
+// target is connected for each request. Devices are only reachable through a configured target, so the Zabbix server
+// cannot direct requests to arbitrary hosts.
+func Items(targets map[string]*gosnmp.GoSNMP, next zbx.ItemFunc) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil || (name != "snmp.get" && name != "snmp.walk") {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		if len(params) != 2 || params[0] == "" || params[1] == "" {
+			return nil, fmt.Errorf("invalid number of parameters")
+		}
+		template, ok := targets[params[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown target '%s'", params[0])
+		}
+		oid := params[1]
+		if !strings.HasPrefix(oid, ".") {
+			oid = "." + oid
+		}
+
+		client := *template
+		if template.SecurityParameters != nil {
+			// Security parameters are updated during requests, so each request uses its own copy
+			client.SecurityParameters = template.SecurityParameters.Copy()
+		}
+		if err := client.Connect(); err != nil {
+			return nil, fmt.Errorf("error connecting to target '%s': %s", params[0], err.Error())
+		}
+		defer client.Conn.Close()
+
+		if name == "snmp.get" {
+			return get(&client, oid)
+		}
+		return walk(&client, oid)
+	}
+}
+
+func get(client *gosnmp.GoSNMP, oid string) (interface{}, error) {
+	result, err := client.Get([]string{oid})
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %s", oid, err.Error())
+	}
+	if result.Error != gosnmp.NoError {
+		return nil, fmt.Errorf("error getting %s: %s", oid, result.Error.String())
+	}
+	if len(result.Variables) == 0 {
+		return nil, fmt.Errorf("no value for %s", oid)
+	}
+	return pduValue(result.Variables[0])
+}
+
+func walk(client *gosnmp.GoSNMP, oid string) (interface{}, error) {
+	var pdus []gosnmp.SnmpPDU
+	var err error
+	if client.Version == gosnmp.Version1 {
+		pdus, err = client.WalkAll(oid)
+	} else {
+		pdus, err = client.BulkWalkAll(oid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error walking %s: %s", oid, err.Error())
+	}
+
+	lines := make([]string, 0, len(pdus))
+	for _, pdu := range pdus {
+		line, err := walkLine(pdu)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// pduValue returns the value of pdu as an item value
+func pduValue(pdu gosnmp.SnmpPDU) (interface{}, error) {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance:
+		return nil, fmt.Errorf("no such object %s", pdu.Name)
+	case gosnmp.EndOfMibView:
+		return nil, fmt.Errorf("end of mib view at %s", pdu.Name)
+	case gosnmp.Null:
+		return nil, fmt.Errorf("no value for %s", pdu.Name)
+	case gosnmp.OctetString:
+		data, _ := pdu.Value.([]byte)
+		if isText(data) {
+			return string(data), nil
+		}
+		return formatHex(data), nil
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		return gosnmp.ToBigInt(pdu.Value).String(), nil
+	}
+	return fmt.Sprintf("%v", pdu.Value), nil
+}
+
+// walkLine formats pdu in the same way as snmpwalk from net-snmp, which Zabbix SNMP walk preprocessing expects
+func walkLine(pdu gosnmp.SnmpPDU) (string, error) {
+	value, err := pduValue(pdu)
+	if err != nil {
+		return "", err
+	}
+
+	typeName := ""
+	switch pdu.Type {
+	case gosnmp.OctetString:
+		typeName = "STRING"
+		data, _ := pdu.Value.([]byte)
+		if isText(data) {
+			value = fmt.Sprintf("%q", value)
+		} else {
+			typeName = "Hex-STRING"
+		}
+	case gosnmp.Integer:
+		typeName = "INTEGER"
+	case gosnmp.Counter32:
+		typeName = "Counter32"
+	case gosnmp.Gauge32, gosnmp.Uinteger32:
+		typeName = "Gauge32"
+	case gosnmp.TimeTicks:
+		typeName = "Timeticks"
+	case gosnmp.Counter64:
+		typeName = "Counter64"
+	case gosnmp.ObjectIdentifier:
+		typeName = "OID"
+	case gosnmp.IPAddress:
+		typeName = "IpAddress"
+	case gosnmp.OpaqueFloat:
+		typeName = "Opaque: Float"
+	case gosnmp.OpaqueDouble:
+		typeName = "Opaque: Double"
+	default:
+		typeName = pdu.Type.String()
+	}
+	return fmt.Sprintf("%s = %s: %v", pdu.Name, typeName, value), nil
+}
+
+// isText returns true if data is printable UTF-8 text
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatHex formats data as space separated upper case hex bytes
+func formatHex(data []byte) string {
+	return fmt.Sprintf("% X", data)
+}
@@ -0,0 +1,163 @@
+package snmpzbx_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx/snmpzbx"
+	"github.com/gosnmp/gosnmp"
+)
+
+// startTestDevice starts a minimal SNMP v2c agent that answers from pdus, which must be sorted by OID, and returns the
+// address it is listening on
+func startTestDevice(t *testing.T, pdus []gosnmp.SnmpPDU) (string, uint16) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := decoder.SnmpDecodePacket(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			variables := []gosnmp.SnmpPDU{}
+			for _, requested := range request.Variables {
+				switch request.PDUType {
+				case gosnmp.GetRequest:
+					variables = append(variables, findPDU(pdus, requested.Name))
+				case gosnmp.GetNextRequest:
+					variables = append(variables, nextPDUs(pdus, requested.Name, 1)...)
+				case gosnmp.GetBulkRequest:
+					// The decoder does not return max-repetitions for requests
+					variables = append(variables, nextPDUs(pdus, requested.Name, len(pdus))...)
+				}
+			}
+
+			response := *request
+			response.PDUType = gosnmp.GetResponse
+			response.Variables = variables
+			out, err := response.MarshalMsg()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, addr)
+		}
+	}()
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	return addr.IP.String(), uint16(addr.Port)
+}
+
+func findPDU(pdus []gosnmp.SnmpPDU, oid string) gosnmp.SnmpPDU {
+	for _, pdu := range pdus {
+		if pdu.Name == oid {
+			return pdu
+		}
+	}
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.NoSuchObject}
+}
+
+func nextPDUs(pdus []gosnmp.SnmpPDU, oid string, count int) []gosnmp.SnmpPDU {
+	result := []gosnmp.SnmpPDU{}
+	for _, pdu := range pdus {
+		if len(result) == count {
+			break
+		}
+		if pdu.Name == oid || (len(result) == 0 && !strings.HasPrefix(pdu.Name, oid+".")) {
+			continue
+		}
+		result = append(result, pdu)
+	}
+	if len(result) == 0 {
+		result = append(result, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+	}
+	return result
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	address, port := startTestDevice(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("Test Switch")},
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(12345)},
+		{Name: ".1.3.6.1.2.1.2.2.1.6.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(1000)},
+	})
+
+	items := snmpzbx.Items(map[string]*gosnmp.GoSNMP{
+		"switch": {
+			Target:    address,
+			Port:      port,
+			Community: "public",
+			Version:   gosnmp.Version2c,
+			Timeout:   time.Second,
+		},
+	}, nil)
+
+	if value, err := items("snmp.get[switch,.1.3.6.1.2.1.1.1.0]"); err != nil || value != "Test Switch" {
+		t.Errorf("Unexpected result for string OID: %v %v", value, err)
+	}
+	if value, err := items("snmp.get[switch,1.3.6.1.2.1.1.3.0]"); err != nil || value != "12345" {
+		t.Errorf("Unexpected result for timeticks OID: %v %v", value, err)
+	}
+	if value, err := items("snmp.get[switch,.1.3.6.1.2.1.2.2.1.6.1]"); err != nil || value != "00 1A 2B 3C 4D 5E" {
+		t.Errorf("Unexpected result for binary OID: %v %v", value, err)
+	}
+	if _, err := items("snmp.get[switch,.1.3.6.1.2.1.1.5.0]"); err == nil {
+		t.Errorf("No error seen for missing OID")
+	}
+
+	value, err := items("snmp.walk[switch,.1.3.6.1.2.1.2]")
+	if err != nil {
+		t.Fatalf("Unexpected error walking: %s", err.Error())
+	}
+	expected := ".1.3.6.1.2.1.2.2.1.6.1 = Hex-STRING: 00 1A 2B 3C 4D 5E\n.1.3.6.1.2.1.2.2.1.10.1 = Counter32: 1000"
+	if value != expected {
+		t.Errorf("Unexpected walk result. Expected %q got %q", expected, value)
+	}
+}
+
+func TestItemsTargets(t *testing.T) {
+	t.Parallel()
+
+	items := snmpzbx.Items(map[string]*gosnmp.GoSNMP{}, nil)
+	if _, err := items("snmp.get[unknown,.1.3.6.1.2.1.1.1.0]"); err == nil || !strings.Contains(err.Error(), "unknown target") {
+		t.Errorf("Unexpected error for unknown target: %v", err)
+	}
+	if _, err := items("snmp.get[unknown]"); err == nil {
+		t.Errorf("No error seen for missing OID")
+	}
+	if value, err := items("agent.ping"); value != nil || err != nil {
+		t.Errorf("Unexpected result for unknown key: %v %v", value, err)
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := snmpzbx.Items(map[string]*gosnmp.GoSNMP{}, func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	})
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := snmpzbx.Items(map[string]*gosnmp.GoSNMP{}, nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
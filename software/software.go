@@ -0,0 +1,331 @@
+/*
+Package software provides item values for the system.sw.* family of Zabbix item keys, which report the operating
+system and installed packages of the host. These items are used by security and compliance templates.
+
+Package lists are collected by running the package managers that are installed on the host: dpkg, rpm and apk.
+*/
+package software
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// Package describes a package installed on the host
+type Package struct {
+	// Name is the name of the package
+	Name string `json:"name"`
+	// Version is the version of the package
+	Version string `json:"version"`
+	// Architecture is the architecture the package was built for
+	Architecture string `json:"arch"`
+	// Manager is the name of the package manager that installed the package
+	Manager string `json:"manager"`
+}
+
+// listTimeout is how long a package manager can take to list packages before it is killed, such as when it is waiting
+// for a lock on its database, which is below the longest item timeout of the Zabbix server
+const listTimeout = 20 * time.Second
+
+type packageManager struct {
+	name    string
+	command []string
+	parse   func(output []byte) []Package
+}
+
+var packageManagers = []packageManager{
+	{
+		name:    "dpkg",
+		command: []string{"dpkg-query", "-W", "-f", "${db:Status-Abbrev}\t${Package}\t${Version}\t${Architecture}\n"},
+		parse:   parseDpkg,
+	},
+	{
+		name:    "rpm",
+		command: []string{"rpm", "-qa", "--queryformat", "%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\n"},
+		parse:   parseRPM,
+	},
+	{
+		name:    "apk",
+		command: []string{"apk", "list", "--installed"},
+		parse:   parseAPK,
+	},
+}
+
+// Packages returns the packages installed by every package manager available on the host, sorted by manager and
+// name. If manager is not empty only packages from the package manager with that name are returned, and an error is
+// returned if it is not available. An error is returned if no package manager is available.
+func Packages(manager string) ([]Package, error) {
+	packages := []Package{}
+	found := false
+	for _, pm := range packageManagers {
+		if manager != "" && pm.name != manager {
+			continue
+		}
+		if _, err := exec.LookPath(pm.command[0]); err != nil {
+			continue
+		}
+		found = true
+
+		output, err := pm.list()
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s packages: %s", pm.name, err.Error())
+		}
+		packages = append(packages, pm.parse(output)...)
+	}
+	if !found {
+		if manager != "" {
+			return nil, fmt.Errorf("package manager %s is not available", manager)
+		}
+		return nil, fmt.Errorf("no supported package manager is available")
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		if packages[i].Manager != packages[j].Manager {
+			return packages[i].Manager < packages[j].Manager
+		}
+		return packages[i].Name < packages[j].Name
+	})
+	return packages, nil
+}
+
+// list runs the package manager and returns its list of packages
+func (pm packageManager) list() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, pm.command[0], pm.command[1:]...).Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("timed out after %s", listTimeout)
+	}
+	return output, err
+}
+
+func parseDpkg(output []byte) []Package {
+	packages := []Package{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		// Only include packages that are installed
+		if len(fields) != 4 || !strings.HasPrefix(fields[0], "ii") {
+			continue
+		}
+		packages = append(packages, Package{Name: fields[1], Version: fields[2], Architecture: fields[3], Manager: "dpkg"})
+	}
+	return packages
+}
+
+func parseRPM(output []byte) []Package {
+	packages := []Package{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		packages = append(packages, Package{Name: fields[0], Version: fields[1], Architecture: fields[2], Manager: "rpm"})
+	}
+	return packages
+}
+
+func parseAPK(output []byte) []Package {
+	packages := []Package{}
+	for _, line := range strings.Split(string(output), "\n") {
+		// musl-1.2.4-r2 x86_64 {musl} (MIT) [installed]
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// The version is the last two dash separated parts of the first field
+		parts := strings.Split(fields[0], "-")
+		if len(parts) < 3 {
+			continue
+		}
+		packages = append(packages, Package{
+			Name:         strings.Join(parts[:len(parts)-2], "-"),
+			Version:      strings.Join(parts[len(parts)-2:], "-"),
+			Architecture: fields[1],
+			Manager:      "apk",
+		})
+	}
+	return packages
+}
+
+// OS returns information about the operating system for the system.sw.os item. Info is one of "full" for the full
+// kernel version string, "short" for the kernel name and release, or "name" for the name of the distribution.
+func OS(info string) (string, error) {
+	switch info {
+	case "", "full":
+		data, err := os.ReadFile("/proc/version")
+		if err != nil {
+			return "", fmt.Errorf("cannot read kernel version: %s", err.Error())
+		}
+		return strings.TrimSpace(string(data)), nil
+	case "short":
+		ostype, err := os.ReadFile("/proc/sys/kernel/ostype")
+		if err != nil {
+			return "", fmt.Errorf("cannot read kernel name: %s", err.Error())
+		}
+		release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+		if err != nil {
+			return "", fmt.Errorf("cannot read kernel release: %s", err.Error())
+		}
+		return strings.TrimSpace(string(ostype)) + " " + strings.TrimSpace(string(release)), nil
+	case "name":
+		for _, fileName := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+			data, err := os.ReadFile(fileName)
+			if err != nil {
+				continue
+			}
+			if name := parseOSRelease(data); name != "" {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("cannot determine operating system name")
+	}
+	return "", fmt.Errorf("invalid info parameter '%s'", info)
+}
+
+// parseOSRelease returns the PRETTY_NAME, or NAME, from the contents of an os-release file
+func parseOSRelease(data []byte) string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[parts[0]] = strings.Trim(parts[1], `"'`)
+	}
+	if values["PRETTY_NAME"] != "" {
+		return values["PRETTY_NAME"]
+	}
+	return values["NAME"]
+}
+
+// Items returns an ItemFunc for the software inventory keys below. Every other key, including one that cannot be
+// parsed, is passed to next, or is unknown if next is nil.
+//
+//   - system.sw.os[<info>], see OS
+//   - system.sw.packages[<regexp>,<manager>,<format>], a list of installed packages for each package manager, such
+//     as "[dpkg] bash 5.2.15-2, coreutils 9.1-1". Format is "full" (the default) to include versions, or "short" for
+//     names only.
+//   - system.sw.packages.get[<regexp>,<manager>], a JSON array of installed packages.
+//
+// Listing packages can be slow, so the list of packages is cached for the duration of cacheFor.
+func Items(next zbx.ItemFunc, cacheFor time.Duration) zbx.ItemFunc {
+	lock := sync.Mutex{}
+	cache := map[string][]Package{}
+	expires := map[string]time.Time{}
+	cachedPackages := func(manager string) ([]Package, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if packages, ok := cache[manager]; ok && time.Now().Before(expires[manager]) {
+			return packages, nil
+		}
+		packages, err := Packages(manager)
+		if err != nil {
+			return nil, err
+		}
+		cache[manager] = packages
+		expires[manager] = time.Now().Add(cacheFor)
+		return packages, nil
+	}
+
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil {
+			// The key is not one of these, but may still be known to next
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+		param := func(i int) string {
+			if i < len(params) {
+				return params[i]
+			}
+			return ""
+		}
+
+		switch name {
+		case "system.sw.os":
+			return OS(param(0))
+		case "system.sw.packages", "system.sw.packages.get":
+			var pattern *regexp.Regexp
+			if expr := param(0); expr != "" && expr != "all" {
+				pattern, err = regexp.Compile(expr)
+				if err != nil {
+					return nil, fmt.Errorf("invalid regular expression: %s", err.Error())
+				}
+			}
+			manager := param(1)
+			if manager == "all" {
+				manager = ""
+			}
+			packages, err := cachedPackages(manager)
+			if err != nil {
+				return nil, err
+			}
+
+			matched := []Package{}
+			for _, pkg := range packages {
+				if pattern == nil || pattern.MatchString(pkg.Name) {
+					matched = append(matched, pkg)
+				}
+			}
+			if name == "system.sw.packages.get" {
+				return zbx.JSON(matched)
+			}
+			return formatPackages(matched, param(2))
+		}
+
+		if next == nil {
+			return nil, nil
+		}
+		return next(key)
+	}
+}
+
+// formatPackages formats packages, which must be sorted by manager, as a list for each manager
+func formatPackages(packages []Package, format string) (string, error) {
+	if format != "" && format != "full" && format != "short" {
+		return "", fmt.Errorf("invalid format parameter '%s'", format)
+	}
+
+	lines := []string{}
+	var line *strings.Builder
+	manager := ""
+	for _, pkg := range packages {
+		if line == nil || pkg.Manager != manager {
+			if line != nil {
+				lines = append(lines, line.String())
+			}
+			manager = pkg.Manager
+			line = &strings.Builder{}
+			line.WriteString("[" + manager + "] ")
+		} else {
+			line.WriteString(", ")
+		}
+		line.WriteString(pkg.Name)
+		if format != "short" {
+			line.WriteString(" " + pkg.Version)
+		}
+	}
+	if line != nil {
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
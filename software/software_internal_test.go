@@ -0,0 +1,104 @@
+package software
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDpkg(t *testing.T) {
+	t.Parallel()
+
+	packages := parseDpkg([]byte("ii \tbash\t5.2.15-2+b2\tamd64\nrc \tremoved\t1.0\tamd64\nii \tlibc6\t2.36-9\tamd64\n"))
+	if len(packages) != 2 {
+		t.Fatalf("Unexpected number of packages. Expected 2 got %d", len(packages))
+	}
+	expected := Package{Name: "bash", Version: "5.2.15-2+b2", Architecture: "amd64", Manager: "dpkg"}
+	if packages[0] != expected {
+		t.Errorf("Unexpected package. Expected %+v got %+v", expected, packages[0])
+	}
+}
+
+func TestParseRPM(t *testing.T) {
+	t.Parallel()
+
+	packages := parseRPM([]byte("bash\t5.1.8-6.el9\tx86_64\ngpg-pubkey\t8483c65d-5ccc5b19\t(none)\n"))
+	if len(packages) != 2 {
+		t.Fatalf("Unexpected number of packages. Expected 2 got %d", len(packages))
+	}
+	expected := Package{Name: "bash", Version: "5.1.8-6.el9", Architecture: "x86_64", Manager: "rpm"}
+	if packages[0] != expected {
+		t.Errorf("Unexpected package. Expected %+v got %+v", expected, packages[0])
+	}
+}
+
+func TestParseAPK(t *testing.T) {
+	t.Parallel()
+
+	packages := parseAPK([]byte("musl-1.2.4-r2 x86_64 {musl} (MIT) [installed]\nca-certificates-bundle-20230506-r0 x86_64 {ca-certificates} (MPL-2.0 AND MIT) [installed]\n"))
+	if len(packages) != 2 {
+		t.Fatalf("Unexpected number of packages. Expected 2 got %d", len(packages))
+	}
+	expected := Package{Name: "ca-certificates-bundle", Version: "20230506-r0", Architecture: "x86_64", Manager: "apk"}
+	if packages[1] != expected {
+		t.Errorf("Unexpected package. Expected %+v got %+v", expected, packages[1])
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	t.Parallel()
+
+	if name := parseOSRelease([]byte("NAME=\"Debian GNU/Linux\"\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n")); name != "Debian GNU/Linux 12 (bookworm)" {
+		t.Errorf("Unexpected name '%s'", name)
+	}
+	if name := parseOSRelease([]byte("# comment\nNAME=Alpine\n")); name != "Alpine" {
+		t.Errorf("Unexpected name '%s'", name)
+	}
+}
+
+func TestFormatPackages(t *testing.T) {
+	t.Parallel()
+
+	packages := []Package{
+		{Name: "bash", Version: "5.2", Manager: "dpkg"},
+		{Name: "coreutils", Version: "9.1", Manager: "dpkg"},
+		{Name: "musl", Version: "1.2.4-r2", Manager: "apk"},
+	}
+	result, err := formatPackages(packages, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expected := "[dpkg] bash 5.2, coreutils 9.1\n[apk] musl 1.2.4-r2"; result != expected {
+		t.Errorf("Unexpected result. Expected %q got %q", expected, result)
+	}
+	result, _ = formatPackages(packages, "short")
+	if expected := "[dpkg] bash, coreutils\n[apk] musl"; result != expected {
+		t.Errorf("Unexpected result. Expected %q got %q", expected, result)
+	}
+	if _, err := formatPackages(packages, "bogus"); err == nil {
+		t.Errorf("No error seen for invalid format")
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := []string{}
+	items := Items(func(key string) (interface{}, error) {
+		received = append(received, key)
+		return 1, nil
+	}, time.Hour)
+
+	// Keys that are not software inventory keys, including those that cannot be parsed, are passed to next
+	for _, key := range []string{"agent.ping", "custom.key[a"} {
+		if value, err := items(key); err != nil || value != 1 {
+			t.Errorf("Unexpected result for '%s': %v %v", key, value, err)
+		}
+	}
+	if len(received) != 2 || received[1] != "custom.key[a" {
+		t.Errorf("Unexpected keys passed to next: %q", received)
+	}
+
+	if value, err := Items(nil, time.Hour)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
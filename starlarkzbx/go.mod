@@ -0,0 +1,10 @@
+module github.com/ecnepsnai/zbx/starlarkzbx
+
+go 1.17
+
+require (
+	github.com/ecnepsnai/zbx v0.0.0-20261016123541-ef93aff01069
+	go.starlark.net v0.0.0-20230525235612-a134d8f9ddca
+)
+
+require golang.org/x/sys v0.9.0 // indirect
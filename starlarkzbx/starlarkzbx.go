@@ -0,0 +1,189 @@
+// Package starlarkzbx provides items with values computed by Starlark scripts, so operators can add derived items,
+// such as ratios, thresholds or reformatted strings, without recompiling the application that embeds the agent.
+//
+// It is a separate module so that the zbx package itself does not depend on Starlark.
+package starlarkzbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ecnepsnai/zbx"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// maxExecutionSteps limits how long a script can run, since scripts come from configuration
+const maxExecutionSteps = 1000000
+
+// ScriptItem describes an item with a value computed by a Starlark script
+type ScriptItem struct {
+	// Key is the item key
+	Key string `json:"key"`
+	// Script is a Starlark expression, such as "float(item('mem.used')) / float(item('mem.total')) * 100", or a
+	// program that assigns the value of the item to a global variable named value.
+	Script string `json:"script"`
+}
+
+// scriptItem is a compiled script. Exactly one of expression or program is set.
+type scriptItem struct {
+	expression *starlark.Function
+	program    *starlark.Program
+}
+
+// Items returns an ItemFunc that responds with the values computed by the given scripts, and passes requests for any
+// other key to next. If next is nil other keys are treated as unknown.
+//
+// Scripts can call item(key) to get the value of another item from next, which returns an int, float, bool or string
+// depending on the type of the value. Scripts can not request other script items. An error from next, or requesting
+// an unknown item, stops the script and is returned as the error for the item.
+//
+// The script result must be None, which is treated as an unknown item, an int, float, bool or string. Lists and
+// dicts are returned as their Starlark representation.
+//
+// An error is returned if a key is invalid or declared more than once, or if a script does not compile.
+func Items(scripts []ScriptItem, next zbx.ItemFunc) (zbx.ItemFunc, error) {
+	predeclared := starlark.StringDict{
+		"item": starlark.NewBuiltin("item", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var key string
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &key); err != nil {
+				return nil, err
+			}
+			if next == nil {
+				return nil, fmt.Errorf("unknown item '%s'", key)
+			}
+			value, err := next(key)
+			if err != nil {
+				return nil, fmt.Errorf("item '%s': %s", key, err.Error())
+			}
+			if value == nil {
+				return nil, fmt.Errorf("unknown item '%s'", key)
+			}
+			return toStarlark(value), nil
+		}),
+	}
+
+	items := map[string]*scriptItem{}
+	for _, script := range scripts {
+		if err := zbx.ValidateKey(script.Key); err != nil {
+			return nil, fmt.Errorf("invalid script item '%s': %s", script.Key, err.Error())
+		}
+		if _, duplicate := items[script.Key]; duplicate {
+			return nil, fmt.Errorf("invalid script item '%s': key declared more than once", script.Key)
+		}
+
+		compiled := &scriptItem{}
+		if _, err := syntax.ParseExpr(script.Key, script.Script, 0); err == nil {
+			compiled.expression, err = starlark.ExprFunc(script.Key, script.Script, predeclared)
+			if err != nil {
+				return nil, fmt.Errorf("invalid script item '%s': %s", script.Key, err.Error())
+			}
+		} else {
+			_, compiled.program, err = starlark.SourceProgram(script.Key, script.Script, predeclared.Has)
+			if err != nil {
+				return nil, fmt.Errorf("invalid script item '%s': %s", script.Key, err.Error())
+			}
+		}
+		items[script.Key] = compiled
+	}
+
+	return func(key string) (interface{}, error) {
+		item, ok := items[key]
+		if !ok {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		thread := &starlark.Thread{Name: key}
+		thread.SetMaxExecutionSteps(maxExecutionSteps)
+
+		var result starlark.Value
+		if item.expression != nil {
+			value, err := starlark.Call(thread, item.expression, nil, nil)
+			if err != nil {
+				return nil, scriptError(err)
+			}
+			result = value
+		} else {
+			globals, err := item.program.Init(thread, predeclared)
+			if err != nil {
+				return nil, scriptError(err)
+			}
+			value, ok := globals["value"]
+			if !ok {
+				return nil, fmt.Errorf("script did not assign value")
+			}
+			result = value
+		}
+		return fromStarlark(result), nil
+	}, nil
+}
+
+// scriptError returns err without the Starlark backtrace
+func scriptError(err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("%s", evalErr.Msg)
+	}
+	return err
+}
+
+func toStarlark(value interface{}) starlark.Value {
+	switch typed := value.(type) {
+	case int:
+		return starlark.MakeInt(typed)
+	case int64:
+		return starlark.MakeInt64(typed)
+	case uint64:
+		return starlark.MakeUint64(typed)
+	case float32:
+		return starlark.Float(typed)
+	case float64:
+		return starlark.Float(typed)
+	case bool:
+		return starlark.Bool(typed)
+	case string:
+		return starlark.String(typed)
+	}
+	return starlark.String(fmt.Sprintf("%v", value))
+}
+
+func fromStarlark(value starlark.Value) interface{} {
+	switch typed := value.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Int:
+		if i, ok := typed.Int64(); ok {
+			return i
+		}
+		return typed.String()
+	case starlark.Float:
+		return float64(typed)
+	case starlark.Bool:
+		return bool(typed)
+	case starlark.String:
+		return string(typed)
+	}
+	return value.String()
+}
+
+// LoadScriptItems reads script items from the JSON file at fileName, which contains an array of items:
+//
+//	[
+//	    {"key": "mem.used.percent", "script": "float(item('mem.used')) / float(item('mem.total')) * 100"},
+//	    {"key": "app.healthy", "script": "value = item('queue.depth') < 100"}
+//	]
+func LoadScriptItems(fileName string) ([]ScriptItem, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []ScriptItem{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid script items file '%s': %s", fileName, err.Error())
+	}
+	return items, nil
+}
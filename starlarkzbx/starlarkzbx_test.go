@@ -0,0 +1,97 @@
+package starlarkzbx_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx/starlarkzbx"
+)
+
+func testItems(key string) (interface{}, error) {
+	switch key {
+	case "mem.used":
+		return "2048", nil
+	case "mem.total":
+		return 8192, nil
+	case "queue.depth":
+		return 150, nil
+	case "app.name":
+		return "billing", nil
+	case "broken":
+		return nil, fmt.Errorf("collector failed")
+	}
+	return nil, nil
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	fileName := filepath.Join(t.TempDir(), "items.json")
+	os.WriteFile(fileName, []byte(`[
+		{"key": "mem.used.percent", "script": "float(item('mem.used')) / item('mem.total') * 100"},
+		{"key": "queue.full", "script": "item('queue.depth') > 100"},
+		{"key": "app.label", "script": "name = item('app.name')\nvalue = name.upper() + '-' + str(item('queue.depth'))"},
+		{"key": "nothing", "script": "None"},
+		{"key": "uses.broken", "script": "item('broken')"},
+		{"key": "uses.unknown", "script": "item('unknown')"},
+		{"key": "no.value", "script": "x = 1"},
+		{"key": "forever", "script": "def f():\n    for i in range(100000000):\n        pass\nf()\nvalue = 1"}
+	]`), 0644)
+	scripts, err := starlarkzbx.LoadScriptItems(fileName)
+	if err != nil {
+		t.Fatalf("Error loading script items: %s", err.Error())
+	}
+	items, err := starlarkzbx.Items(scripts, testItems)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"mem.used.percent": 25.0,
+		"queue.full":       true,
+		"app.label":        "BILLING-150",
+		"nothing":          nil,
+		"app.name":         "billing",
+		"unknown":          nil,
+	}
+	for key, expectedValue := range expected {
+		value, err := items(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%v' got '%v'", key, expectedValue, value)
+		}
+	}
+
+	expectedErrors := map[string]string{
+		"uses.broken":  "item 'broken': collector failed",
+		"uses.unknown": "unknown item 'unknown'",
+		"no.value":     "script did not assign value",
+		"forever":      "too many steps",
+	}
+	for key, expectedError := range expectedErrors {
+		if _, err := items(key); err == nil || !strings.Contains(err.Error(), expectedError) {
+			t.Errorf("Unexpected error for '%s'. Expected '%s' got '%v'", key, expectedError, err)
+		}
+	}
+}
+
+func TestItemsInvalid(t *testing.T) {
+	t.Parallel()
+
+	invalid := [][]starlarkzbx.ScriptItem{
+		{{Key: "", Script: "1"}},
+		{{Key: "a", Script: "1"}, {Key: "a", Script: "2"}},
+		{{Key: "a", Script: "1 +"}},
+		{{Key: "a", Script: "value = undefined_name"}},
+	}
+	for _, scripts := range invalid {
+		if _, err := starlarkzbx.Items(scripts, nil); err == nil {
+			t.Errorf("No error seen for invalid scripts %+v", scripts)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package zbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// StaticItem describes an item with a value that does not change, such as the version or deployment region of the
+// application. Exactly one of Value or Env must be set.
+type StaticItem struct {
+	// Key is the item key
+	Key string `json:"key"`
+	// Value is the constant value of the item
+	Value string `json:"value,omitempty"`
+	// Env is the name of the environment variable that the value of the item is read from
+	Env string `json:"env,omitempty"`
+}
+
+// StaticItems returns an ItemFunc that responds with the values of the given static items, and passes requests for
+// any other key to next. If next is nil other keys are treated as unknown. Environment variables are read once, when
+// StaticItems is called.
+//
+// An error is returned if a key is invalid or declared more than once, if an item does not have exactly one of
+// Value or Env set, or if an environment variable is not set.
+func StaticItems(items []StaticItem, next ItemFunc) (ItemFunc, error) {
+	values := map[string]string{}
+	for _, item := range items {
+		if err := ValidateKey(item.Key); err != nil {
+			return nil, fmt.Errorf("invalid static item '%s': %s", item.Key, err.Error())
+		}
+		if _, duplicate := values[item.Key]; duplicate {
+			return nil, fmt.Errorf("invalid static item '%s': key declared more than once", item.Key)
+		}
+		if (item.Value == "") == (item.Env == "") {
+			return nil, fmt.Errorf("invalid static item '%s': exactly one of value or env must be set", item.Key)
+		}
+
+		value := item.Value
+		if item.Env != "" {
+			envValue, ok := os.LookupEnv(item.Env)
+			if !ok {
+				return nil, fmt.Errorf("invalid static item '%s': environment variable %s is not set", item.Key, item.Env)
+			}
+			value = envValue
+		}
+		values[item.Key] = value
+	}
+
+	return func(key string) (interface{}, error) {
+		if value, ok := values[key]; ok {
+			return value, nil
+		}
+		if next == nil {
+			return nil, nil
+		}
+		return next(key)
+	}, nil
+}
+
+// LoadStaticItems reads static items from the JSON file at fileName, which contains an array of items:
+//
+//	[
+//	    {"key": "app.version", "value": "1.4.2"},
+//	    {"key": "app.region", "env": "REGION"}
+//	]
+func LoadStaticItems(fileName string) ([]StaticItem, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []StaticItem{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid static items file '%s': %s", fileName, err.Error())
+	}
+	return items, nil
+}
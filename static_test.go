@@ -0,0 +1,76 @@
+package zbx_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestStaticItems(t *testing.T) {
+	t.Setenv("ZBX_TEST_REGION", "us-east")
+
+	fileName := filepath.Join(t.TempDir(), "items.json")
+	os.WriteFile(fileName, []byte(`[
+		{"key": "app.version", "value": "1.4.2"},
+		{"key": "app.region", "env": "ZBX_TEST_REGION"}
+	]`), 0644)
+	items, err := zbx.LoadStaticItems(fileName)
+	if err != nil {
+		t.Fatalf("Error loading static items: %s", err.Error())
+	}
+
+	itemFunc, err := zbx.StaticItems(items, func(key string) (interface{}, error) {
+		if key == "agent.ping" {
+			return 1, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"app.version": "1.4.2",
+		"app.region":  "us-east",
+		"agent.ping":  1,
+		"unknown":     nil,
+	}
+	for key, expectedValue := range expected {
+		value, err := itemFunc(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%v' got '%v'", key, expectedValue, value)
+		}
+	}
+
+	// Without next
+	itemFunc, _ = zbx.StaticItems(items, nil)
+	if value, _ := itemFunc("agent.ping"); value != nil {
+		t.Errorf("Unexpected value for unknown key: %v", value)
+	}
+}
+
+func TestStaticItemsInvalid(t *testing.T) {
+	invalid := [][]zbx.StaticItem{
+		{{Key: "", Value: "1"}},
+		{{Key: "app.version", Value: "1"}, {Key: "app.version", Value: "2"}},
+		{{Key: "app.version"}},
+		{{Key: "app.version", Value: "1", Env: "VERSION"}},
+		{{Key: "app.region", Env: "ZBX_TEST_VARIABLE_THAT_IS_NOT_SET"}},
+	}
+	for _, items := range invalid {
+		if _, err := zbx.StaticItems(items, nil); err == nil {
+			t.Errorf("No error seen for invalid items %+v", items)
+		}
+	}
+
+	fileName := filepath.Join(t.TempDir(), "items.json")
+	os.WriteFile(fileName, []byte(`{"app.version": "1"}`), 0644)
+	if _, err := zbx.LoadStaticItems(fileName); err == nil {
+		t.Errorf("No error seen for invalid file")
+	}
+}
@@ -0,0 +1,98 @@
+package zbx
+
+import (
+	"strings"
+	"time"
+)
+
+// Stats describes counters about the operation of a Server
+type Stats struct {
+	// Started is when the server started accepting connections
+	Started time.Time `json:"started"`
+	// Connections is the total number of connections accepted
+	Connections uint64 `json:"connections"`
+	// OpenConnections is the number of connections currently open
+	OpenConnections uint64 `json:"open_connections"`
+	// Requests is the total number of well-formed requests received
+	Requests uint64 `json:"requests"`
+	// Errors is the total number of malformed requests or connections that failed while reading a request
+	Errors uint64 `json:"errors"`
+	// ItemErrors is the total number of requests where the ItemFunc returned an error or panicked
+	ItemErrors uint64 `json:"item_errors"`
+	// AcceptErrors is the total number of temporary errors accepting connections, such as from running out of file
+	// descriptors
+	AcceptErrors uint64 `json:"accept_errors"`
+	// Exhausted is true while the server is unable to accept connections because the process or system has run out
+	// of file descriptors or memory
+	Exhausted bool `json:"exhausted"`
+	// ShedConnections is the total number of idle persistent connections closed to free up resources, see
+	// Server.ShedIdleConnections
+	ShedConnections uint64 `json:"shed_connections"`
+	// BytesReceived is the total size of well-formed requests received, including the protocol header
+	BytesReceived uint64 `json:"bytes_received"`
+	// BytesSent is the total size of replies sent, including the protocol header
+	BytesSent uint64 `json:"bytes_sent"`
+	// MemoryWaits is the total number of times a connection waited for memory to be released, see
+	// Server.MaxInFlightMemory
+	MemoryWaits uint64 `json:"memory_waits"`
+	// CoalescedRequests is the total number of requests that shared the value of a concurrent request for the same key,
+	// see Server.CoalesceRequests
+	CoalescedRequests uint64 `json:"coalesced_requests"`
+	// WarmedUp is true once all of the Server.WarmUpKeys have been requested
+	WarmedUp bool `json:"warmed_up"`
+}
+
+// Stats returns a snapshot of the counters of this server
+func (s *Server) Stats() Stats {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	return s.stats
+}
+
+func (s *Server) updateStats(update func(stats *Stats)) {
+	s.statsLock.Lock()
+	update(&s.stats)
+	s.statsLock.Unlock()
+}
+
+// statsItem returns the value for one of the internal zbx.stats items. Returns false if key is not one of these
+// items.
+//
+// Supported keys are zbx.stats, which returns all counters as JSON, and zbx.stats[<name>] where name is one of
+// connections, open_connections, requests, errors, item_errors, bytes_received, bytes_sent or uptime (in seconds).
+func (s *Server) statsItem(key string) (interface{}, bool, error) {
+	if !s.StatsItems {
+		return nil, false, nil
+	}
+
+	if key == "zbx.stats" {
+		stats := s.Stats()
+		value, err := JSON(stats)
+		return value, true, err
+	}
+
+	if !strings.HasPrefix(key, "zbx.stats[") || !strings.HasSuffix(key, "]") {
+		return nil, false, nil
+	}
+
+	stats := s.Stats()
+	switch key[len("zbx.stats[") : len(key)-1] {
+	case "connections":
+		return stats.Connections, true, nil
+	case "open_connections":
+		return stats.OpenConnections, true, nil
+	case "requests":
+		return stats.Requests, true, nil
+	case "errors":
+		return stats.Errors, true, nil
+	case "item_errors":
+		return stats.ItemErrors, true, nil
+	case "bytes_received":
+		return stats.BytesReceived, true, nil
+	case "bytes_sent":
+		return stats.BytesSent, true, nil
+	case "uptime":
+		return int64(s.clock().Now().Sub(stats.Started) / time.Second), true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,94 @@
+package zbx_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestStatsItems(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			if key == "agent.ping" {
+				return 1, nil
+			}
+			return nil, fmt.Errorf("broken")
+		},
+		StatsItems: true,
+	}
+	addr := startTestServer(t, server)
+
+	queryKey(t, addr, "agent.ping")
+	queryKey(t, addr, "generate.error")
+	sendGarbage(t, addr)
+
+	if value := queryKey(t, addr, "zbx.stats[requests]"); value != "3" {
+		t.Errorf("Unexpected requests value. Expected '3' got '%s'", value)
+	}
+	if value := queryKey(t, addr, "zbx.stats[item_errors]"); value != "1" {
+		t.Errorf("Unexpected item_errors value. Expected '1' got '%s'", value)
+	}
+	if value := queryKey(t, addr, "zbx.stats[errors]"); value != "1" {
+		t.Errorf("Unexpected errors value. Expected '1' got '%s'", value)
+	}
+	if value := queryKey(t, addr, "zbx.stats[uptime]"); value != "0" {
+		t.Errorf("Unexpected uptime value. Expected '0' got '%s'", value)
+	}
+	if value := queryKey(t, addr, "zbx.stats[unknown]"); value != "ZBX_NOTSUPPORTED\x00broken" {
+		t.Errorf("Unexpected reply for unknown stat: '%s'", value)
+	}
+
+	stats := zbx.Stats{}
+	if err := json.Unmarshal([]byte(queryKey(t, addr, "zbx.stats")), &stats); err != nil {
+		t.Fatalf("Error decoding stats: %s", err.Error())
+	}
+	if stats.Connections != 9 {
+		t.Errorf("Unexpected connections value. Expected 9 got %d", stats.Connections)
+	}
+	if stats.Started.IsZero() {
+		t.Errorf("No start time in stats")
+	}
+
+	if server.Stats().Requests != 8 {
+		t.Errorf("Unexpected requests value. Expected 8 got %d", server.Stats().Requests)
+	}
+}
+
+func TestStatsItemsDisabled(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return nil, nil
+		},
+	})
+
+	if value := queryKey(t, addr, "zbx.stats[requests]"); value != "ZBX_NOTSUPPORTED\x00Item key unknown" {
+		t.Errorf("Unexpected reply when stats items are disabled: '%s'", value)
+	}
+}
+
+func TestStatsBytes(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+	}
+	addr := startTestServer(t, server)
+
+	queryKey(t, addr, "agent.ping")
+
+	stats := server.Stats()
+	if stats.BytesReceived != 23 {
+		t.Errorf("Unexpected bytes received. Expected 23 got %d", stats.BytesReceived)
+	}
+	if stats.BytesSent != 14 {
+		t.Errorf("Unexpected bytes sent. Expected 14 got %d", stats.BytesSent)
+	}
+}
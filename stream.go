@@ -0,0 +1,142 @@
+package zbx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// MaxStreamedValueLength is the longest value that can be streamed to the server, which is the largest packet that the
+// Zabbix server accepts, 1GiB
+const MaxStreamedValueLength = 1 << 30
+
+// streamedValue is a reply with a value that is copied to the connection in chunks, instead of being held in memory
+type streamedValue struct {
+	header []byte
+	body   io.Reader
+	closer []io.Closer
+}
+
+// WriteTo writes the reply to w
+func (v *streamedValue) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(v.header)
+	if err != nil {
+		return int64(n), err
+	}
+	copied, err := io.Copy(w, v.body)
+	return int64(n) + copied, err
+}
+
+// Close releases the reader of the value, and any spool file
+func (v *streamedValue) Close() {
+	for _, closer := range v.closer {
+		closer.Close()
+	}
+}
+
+// sizedReaderAt is implemented by readers with a known size that can be read from any position, such as
+// *bytes.Reader, *strings.Reader and *io.SectionReader
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// streamValue prepares value to be streamed to the server in a reply encoded with options. The length of the value is
+// taken from the reader if it is known, otherwise the value is spooled to a temporary file to learn it.
+func (s *Server) streamValue(value io.Reader, options *zbxproto.Options) (_ *streamedValue, err error) {
+	stream := &streamedValue{}
+	if closer, ok := value.(io.Closer); ok {
+		stream.closer = append(stream.closer, closer)
+	}
+	defer func() {
+		if err != nil {
+			stream.Close()
+		}
+	}()
+
+	var source io.ReaderAt
+	var offset, length int64
+	switch v := value.(type) {
+	case sizedReaderAt:
+		source = v
+		length = v.Size()
+	case *os.File:
+		// Files that are not regular files, such as pipes, are spooled
+		if info, statErr := v.Stat(); statErr == nil && info.Mode().IsRegular() {
+			if offset, err = v.Seek(0, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			source = v
+			if length = info.Size() - offset; length < 0 {
+				length = 0
+			}
+		}
+	}
+	if source == nil {
+		spool, err := os.CreateTemp(s.StreamSpoolDir, "zbx-value-*")
+		if err != nil {
+			return nil, fmt.Errorf("spooling value: %w", err)
+		}
+		stream.closer = append(stream.closer, spoolFile{spool})
+		n, err := io.Copy(spool, io.LimitReader(value, MaxStreamedValueLength+1))
+		if err != nil {
+			return nil, fmt.Errorf("spooling value: %w", err)
+		}
+		source = spool
+		length = n
+	}
+	if length > MaxStreamedValueLength {
+		return nil, ErrValueTooLarge
+	}
+
+	var suffix string
+	if s.MaxValueLength > 0 && length > int64(s.MaxValueLength) {
+		if s.OversizedValues == RejectOversizedValues {
+			return nil, ErrValueTooLarge
+		}
+		suffix = s.TruncationIndicator
+		if suffix == "" {
+			suffix = DefaultTruncationIndicator
+		}
+		if len(suffix) >= s.MaxValueLength {
+			suffix = suffix[:s.MaxValueLength]
+			length = 0
+		} else {
+			length = truncatedLength(source, offset, int64(s.MaxValueLength-len(suffix)))
+		}
+	}
+
+	stream.header, err = zbxproto.EncodeHeader(uint64(length)+uint64(len(suffix)), options)
+	if err != nil {
+		return nil, err
+	}
+	stream.body = io.MultiReader(io.NewSectionReader(source, offset, length), strings.NewReader(suffix))
+	return stream, nil
+}
+
+// truncatedLength returns the length of the data of source starting at offset when cut to at most length bytes,
+// without splitting a UTF-8 sequence
+func truncatedLength(source io.ReaderAt, offset int64, length int64) int64 {
+	b := make([]byte, 1)
+	for end := length; end > 0 && length-end < utf8.UTFMax; end-- {
+		if _, err := source.ReadAt(b, offset+end); err != nil || utf8.RuneStart(b[0]) {
+			return end
+		}
+	}
+	return length
+}
+
+// spoolFile removes the temporary file that a value was spooled to when it is closed
+type spoolFile struct {
+	*os.File
+}
+
+func (f spoolFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.File.Name())
+	return err
+}
@@ -0,0 +1,175 @@
+package zbx_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+// trackedReader is a reader of unknown length that records if it was closed
+type trackedReader struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (r *trackedReader) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func TestStreamedValue(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("zabbix ", 100000)
+	fileName := filepath.Join(t.TempDir(), "value.txt")
+	os.WriteFile(fileName, []byte(large), 0644)
+	spoolDir := t.TempDir()
+	unsized := &trackedReader{
+		Reader: io.MultiReader(strings.NewReader(large), strings.NewReader("end")),
+		closed: make(chan struct{}),
+	}
+	files := make(chan *os.File, 1)
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "sized":
+				return bytes.NewReader([]byte(large)), nil
+			case "file":
+				f, err := os.Open(fileName)
+				files <- f
+				return f, err
+			case "unsized":
+				return unsized, nil
+			}
+			return nil, nil
+		},
+		StreamSpoolDir: spoolDir,
+	})
+
+	expected := map[string]string{
+		"sized":   large,
+		"file":    large,
+		"unsized": large + "end",
+	}
+	for key, expectedValue := range expected {
+		if value := queryKey(t, addr, key); value != expectedValue {
+			t.Errorf("Unexpected value for '%s' of length %d", key, len(value))
+		}
+	}
+
+	// Readers are closed once the reply has been written, which may be after the client has read it
+	file := <-files
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries, _ := os.ReadDir(spoolDir)
+		_, statErr := file.Stat()
+		closed := len(entries) == 0 && statErr != nil
+		select {
+		case <-unsized.closed:
+		default:
+			closed = false
+		}
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Readers were not closed or spool file was not removed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamedValueLargePacket(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return strings.NewReader("streamed"), nil
+		},
+	})
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if err := zbxproto.WriteMessage(c, []byte("agent.ping"), &zbxproto.Options{LargePacket: true}); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	message, err := zbxproto.ReadMessage(c, nil)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	if message.Flags&zbxproto.FlagLargePacket == 0 || string(message.Data) != "streamed" {
+		t.Errorf("Unexpected reply: %+v", message)
+	}
+}
+
+func TestStreamedValueLength(t *testing.T) {
+	t.Parallel()
+
+	itemFunc := func(key string) (interface{}, error) {
+		return strings.NewReader("abcdé✓"), nil
+	}
+	truncateAddr := startTestServer(t, &zbx.Server{
+		ItemFunc:            itemFunc,
+		MaxValueLength:      6,
+		TruncationIndicator: "~",
+	})
+	rejectAddr := startTestServer(t, &zbx.Server{
+		ItemFunc:        itemFunc,
+		MaxValueLength:  6,
+		OversizedValues: zbx.RejectOversizedValues,
+	})
+	sanitizeAddr := startTestServer(t, &zbx.Server{
+		ItemFunc:       itemFunc,
+		ValueSanitizer: strings.ToUpper,
+	})
+
+	// The cut is moved back so that é is not split
+	if value := queryKey(t, truncateAddr, "value"); value != "abcd~" {
+		t.Errorf("Unexpected truncated value '%s'", value)
+	}
+	if value := queryKey(t, rejectAddr, "value"); value != "ZBX_NOTSUPPORTED\x00"+zbx.ErrValueTooLarge.Error() {
+		t.Errorf("Unexpected rejected value '%s'", value)
+	}
+	if value := queryKey(t, sanitizeAddr, "value"); value != "ABCDÉ✓" {
+		t.Errorf("Unexpected sanitized value '%s'", value)
+	}
+}
+
+func TestStreamedValueError(t *testing.T) {
+	t.Parallel()
+
+	readers := make(chan *trackedReader, 2)
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			reader := &trackedReader{Reader: strings.NewReader("partial"), closed: make(chan struct{})}
+			readers <- reader
+			return reader, fmt.Errorf("read failed")
+		},
+	}
+	addr := startTestServer(t, server)
+
+	// Readers returned with an error are not sent, but are still closed
+	if value := queryKey(t, addr, "file"); value != "ZBX_NOTSUPPORTED\x00read failed" {
+		t.Errorf("Unexpected value '%s'", value)
+	}
+	server.Validate([]string{"file"})
+	for i := 0; i < 2; i++ {
+		select {
+		case <-(<-readers).closed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Reader returned with an error was not closed")
+		}
+	}
+}
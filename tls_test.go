@@ -0,0 +1,168 @@
+package zbx_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// testCertificate returns a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "zbx"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTestTLSServer starts the given server with TLS on a random local port, returning its address
+func startTestTLSServer(t *testing.T, server *zbx.Server, config *tls.Config) string {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServeTLSConfig("127.0.0.1:0", config)
+	}()
+	select {
+	case <-server.Ready():
+	case err := <-errs:
+		t.Fatalf("Error starting server: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return server.Addr().String()
+}
+
+// queryKeyTLS requests key from the agent at addr over TLS and returns the data of the reply
+func queryKeyTLS(t *testing.T, addr string, key string, config *tls.Config) string {
+	c, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	if _, err := c.Write(requestForKey(key)); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	reply, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	if len(reply) < 13 {
+		t.Fatalf("Reply too short: %x", reply)
+	}
+	return string(reply[13:])
+}
+
+func TestListenAndServeTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+	}
+	addr := startTestTLSServer(t, server, &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+		MinVersion:   tls.VersionTLS13,
+	})
+
+	if reply := queryKeyTLS(t, addr, "agent.ping", &tls.Config{InsecureSkipVerify: true}); reply != "1" {
+		t.Errorf("Unexpected reply. Expected '1' got '%s'", reply)
+	}
+
+	// The minimum version of the given config is enforced
+	c, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	if err == nil {
+		c.Close()
+		t.Errorf("No error seen when connecting with a version below the minimum")
+	}
+}
+
+func TestTLSHandshakeHooks(t *testing.T) {
+	t.Parallel()
+
+	type handshake struct {
+		state tls.ConnectionState
+		err   error
+	}
+	handshakes := make(chan handshake, 2)
+	keyLog := &lockedBuffer{}
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		OnTLSHandshake: func(remoteAddr net.Addr, state tls.ConnectionState, err error) {
+			handshakes <- handshake{state, err}
+		},
+		TLSKeyLogWriter: keyLog,
+	}
+	addr := startTestTLSServer(t, server, &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+	})
+
+	if reply := queryKeyTLS(t, addr, "agent.ping", &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}); reply != "1" {
+		t.Errorf("Unexpected reply. Expected '1' got '%s'", reply)
+	}
+	result := <-handshakes
+	if result.err != nil {
+		t.Errorf("Unexpected handshake error: %s", result.err.Error())
+	}
+	if result.state.Version != tls.VersionTLS12 {
+		t.Errorf("Unexpected TLS version. Expected %x got %x", tls.VersionTLS12, result.state.Version)
+	}
+	if !bytes.Contains(keyLog.Bytes(), []byte("CLIENT_RANDOM")) {
+		t.Errorf("Key log not written: %s", keyLog.Bytes())
+	}
+
+	// Plain text requests fail the handshake
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	c.Write(requestForKey("agent.ping"))
+	io.ReadAll(c)
+	c.Close()
+	result = <-handshakes
+	if result.err == nil {
+		t.Errorf("No handshake error seen for plain text request")
+	}
+}
+
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
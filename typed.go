@@ -0,0 +1,57 @@
+package zbx
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// TypedValue is the set of types that a typed handler can return, see RegisterTyped
+type TypedValue interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64 | ~string | ~bool
+}
+
+// RegisterTyped registers handler for requests for keys with the given name with router, like Router.Register, for a
+// handler that returns a single type of value:
+//
+//	zbx.RegisterTyped(router, "app.users.online", func(params []string) (int, error) {
+//	    return sessions.Count(), nil
+//	})
+//
+// Values are formatted the way Zabbix expects for the type: floats in decimal notation without an exponent, and
+// booleans as 1 or 0 for use with numeric items. A typed handler always knows its key, so errors are the only way to
+// report that a value is unavailable.
+func RegisterTyped[T TypedValue](router *Router, name string, handler func(params []string) (T, error)) error {
+	if handler == nil {
+		return router.Register(name, nil)
+	}
+	return router.Register(name, HandlerFunc(func(params []string) (interface{}, error) {
+		value, err := handler(params)
+		if err != nil {
+			return nil, err
+		}
+		return formatTypedValue(value), nil
+	}))
+}
+
+// formatTypedValue formats value as the string sent to the server
+func formatTypedValue[T TypedValue](value T) string {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		if v.Bool() {
+			return "1"
+		}
+		return "0"
+	}
+	return v.String()
+}
@@ -0,0 +1,72 @@
+package zbx_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+type celsius float64
+
+func TestRegisterTyped(t *testing.T) {
+	t.Parallel()
+
+	router := &zbx.Router{}
+	register := func(err error) {
+		if err != nil {
+			t.Fatalf("Error registering handler: %s", err.Error())
+		}
+	}
+	register(zbx.RegisterTyped(router, "app.users.online", func(params []string) (int, error) {
+		return -3, nil
+	}))
+	register(zbx.RegisterTyped(router, "app.bytes", func(params []string) (uint64, error) {
+		return math.MaxUint64, nil
+	}))
+	register(zbx.RegisterTyped(router, "app.large", func(params []string) (float64, error) {
+		return 1e20, nil
+	}))
+	register(zbx.RegisterTyped(router, "app.ratio", func(params []string) (float32, error) {
+		return 0.1, nil
+	}))
+	register(zbx.RegisterTyped(router, "app.temperature", func(params []string) (celsius, error) {
+		return 21.5, nil
+	}))
+	register(zbx.RegisterTyped(router, "app.healthy", func(params []string) (bool, error) {
+		return true, nil
+	}))
+	register(zbx.RegisterTyped(router, "app.name", func(params []string) (string, error) {
+		return params[0], nil
+	}))
+	register(zbx.RegisterTyped(router, "app.broken", func(params []string) (int, error) {
+		return 0, fmt.Errorf("not available")
+	}))
+
+	expected := map[string]interface{}{
+		"app.users.online": "-3",
+		"app.bytes":        "18446744073709551615",
+		"app.large":        "100000000000000000000",
+		"app.ratio":        "0.1",
+		"app.temperature":  "21.5",
+		"app.healthy":      "1",
+		"app.name[zbx]":    "zbx",
+	}
+	for key, expectedValue := range expected {
+		value, err := router.ItemFunc(key)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", key, err.Error())
+		}
+		if value != expectedValue {
+			t.Errorf("Unexpected value for '%s'. Expected '%v' got '%v'", key, expectedValue, value)
+		}
+	}
+	if _, err := router.ItemFunc("app.broken"); err == nil {
+		t.Errorf("No error seen for broken handler")
+	}
+
+	if err := zbx.RegisterTyped[int](router, "app.nil", nil); err == nil {
+		t.Errorf("No error seen for nil handler")
+	}
+}
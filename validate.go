@@ -0,0 +1,90 @@
+package zbx
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidationStatus describes the outcome of requesting a key during validation
+type ValidationStatus int
+
+const (
+	// ValidationSupported means that a value was returned for the key
+	ValidationSupported ValidationStatus = iota
+	// ValidationUnsupported means that the key is unknown to the agent
+	ValidationUnsupported
+	// ValidationError means that an error was returned for the key
+	ValidationError
+)
+
+func (s ValidationStatus) String() string {
+	switch s {
+	case ValidationSupported:
+		return "supported"
+	case ValidationUnsupported:
+		return "unsupported"
+	case ValidationError:
+		return "error"
+	}
+	return fmt.Sprintf("ValidationStatus(%d)", int(s))
+}
+
+// ValidationResult describes the result of requesting a single key during validation
+type ValidationResult struct {
+	// Key is the requested key
+	Key string
+	// Status is the outcome of the request
+	Status ValidationStatus
+	// Value is the value returned for the key, formatted as it would be sent to the server
+	Value string
+	// Error is the error returned for the key, if Status is ValidationError
+	Error error
+	// Duration is how long the item took to return
+	Duration time.Duration
+	// Slow is true if Duration exceeded the SlowItemThreshold of the server
+	Slow bool
+}
+
+// Validate requests each of the given keys once, in order, the same way they would be requested by the Zabbix server,
+// including OnResult, and reports which are supported, unsupported, return an error, or are slow. This is useful for
+// checking that the agent covers all of the items of a template before pointing a real server at it.
+//
+// The server does not need to be listening to be validated. Will panic if ItemFunc is nil.
+func (s *Server) Validate(keys []string) []ValidationResult {
+	if s.ItemFunc == nil {
+		panic("itemFunc is nil")
+	}
+
+	threshold := s.SlowItemThreshold
+	if threshold <= 0 {
+		threshold = 3 * time.Second
+	}
+
+	results := make([]ValidationResult, len(keys))
+	for i, key := range keys {
+		value, duration, err := s.resultValue(key, s.ItemFunc)
+		result := ValidationResult{
+			Key:      key,
+			Duration: duration,
+		}
+		result.Slow = result.Duration > threshold
+
+		if err != nil {
+			closeValue(value)
+		} else if value != nil {
+			result.Value, err = s.formatValue(value)
+		}
+
+		if err != nil {
+			result.Status = ValidationError
+			result.Error = err
+		} else if value == nil {
+			result.Status = ValidationUnsupported
+		} else {
+			result.Status = ValidationSupported
+		}
+		results[i] = result
+	}
+
+	return results
+}
@@ -0,0 +1,98 @@
+package zbx_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "agent.ping":
+				return 1, nil
+			case "generate.error":
+				return nil, fmt.Errorf("this is an error")
+			case "slow":
+				time.Sleep(20 * time.Millisecond)
+				return "done", nil
+			}
+			return nil, nil
+		},
+		SlowItemThreshold: 10 * time.Millisecond,
+		StatsItems:        true,
+	}
+
+	results := server.Validate([]string{"agent.ping", "generate.error", "unknown", "slow", "zbx.stats[errors]"})
+	if len(results) != 5 {
+		t.Fatalf("Unexpected number of results. Expected 5 got %d", len(results))
+	}
+
+	check := func(result zbx.ValidationResult, key string, status zbx.ValidationStatus, value string, slow bool) {
+		if result.Key != key {
+			t.Errorf("Unexpected key. Expected '%s' got '%s'", key, result.Key)
+		}
+		if result.Status != status {
+			t.Errorf("Unexpected status for '%s'. Expected %s got %s", key, status, result.Status)
+		}
+		if result.Value != value {
+			t.Errorf("Unexpected value for '%s'. Expected '%s' got '%s'", key, value, result.Value)
+		}
+		if result.Slow != slow {
+			t.Errorf("Unexpected slow for '%s'. Expected %v got %v", key, slow, result.Slow)
+		}
+	}
+	check(results[0], "agent.ping", zbx.ValidationSupported, "1", false)
+	check(results[1], "generate.error", zbx.ValidationError, "", false)
+	check(results[2], "unknown", zbx.ValidationUnsupported, "", false)
+	check(results[3], "slow", zbx.ValidationSupported, "done", true)
+	check(results[4], "zbx.stats[errors]", zbx.ValidationSupported, "0", false)
+
+	if results[1].Error == nil || results[1].Error.Error() != "this is an error" {
+		t.Errorf("Unexpected error: %v", results[1].Error)
+	}
+}
+
+// Ensure that values are validated as they are replied to the server, after OnResult
+func TestValidateOnResult(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			switch key {
+			case "app.password":
+				return "hunter2", nil
+			case "app.legacy":
+				return 1, nil
+			}
+			return nil, nil
+		},
+		OnResult: func(key string, value interface{}, err error, duration time.Duration) (interface{}, error) {
+			switch key {
+			case "app.password":
+				return "******", nil
+			case "app.legacy":
+				return nil, nil
+			case "app.new":
+				return "ok", nil
+			}
+			return value, err
+		},
+	}
+
+	results := server.Validate([]string{"app.password", "app.legacy", "app.new"})
+	if results[0].Status != zbx.ValidationSupported || results[0].Value != "******" {
+		t.Errorf("Value not rewritten by OnResult: %s '%s'", results[0].Status, results[0].Value)
+	}
+	if results[1].Status != zbx.ValidationUnsupported {
+		t.Errorf("Unexpected status for value removed by OnResult: %s", results[1].Status)
+	}
+	if results[2].Status != zbx.ValidationSupported || results[2].Value != "ok" {
+		t.Errorf("Value not added by OnResult: %s '%s'", results[2].Status, results[2].Value)
+	}
+}
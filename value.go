@@ -0,0 +1,99 @@
+package zbx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// SanitizeValue returns value with any invalid UTF-8 sequences replaced with the Unicode replacement character,
+// NUL and other control characters except tab and newline removed, and CRLF or CR line endings replaced with LF.
+// The Zabbix server may reject or mangle values that contain these characters.
+//
+// Sanitization is opt-in, by setting it as the ValueSanitizer of a Server:
+//
+//	server := &zbx.Server{ItemFunc: getItem, ValueSanitizer: zbx.SanitizeValue}
+func SanitizeValue(value string) string {
+	value = strings.ToValidUTF8(value, "\uFFFD")
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r == '\t' || r == '\n':
+			return r
+		case r < 0x20 || r == 0x7F:
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// OversizedValuePolicy describes what is done with values longer than the MaxValueLength of a Server
+type OversizedValuePolicy int
+
+const (
+	// TruncateOversizedValues truncates the value to MaxValueLength, ending it with the TruncationIndicator
+	TruncateOversizedValues OversizedValuePolicy = iota
+	// RejectOversizedValues sends ErrValueTooLarge to the server instead of the value
+	RejectOversizedValues
+)
+
+// DefaultTruncationIndicator is the text that ends truncated values when a Server has no TruncationIndicator
+const DefaultTruncationIndicator = "...(truncated)"
+
+// closeValue closes value if it is an io.Closer, such as a reader that is returned with an error and so is never sent
+func closeValue(value interface{}) {
+	if closer, ok := value.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// formatValue formats the value returned by an item as the string sent to the server, closing it if it is an
+// io.Closer
+func (s *Server) formatValue(value interface{}) (string, error) {
+	defer closeValue(value)
+	if reader, ok := value.(io.Reader); ok {
+		data, err := io.ReadAll(io.LimitReader(reader, MaxStreamedValueLength+1))
+		if err != nil {
+			return "", err
+		}
+		if len(data) > MaxStreamedValueLength {
+			return "", ErrValueTooLarge
+		}
+		value = string(data)
+	}
+
+	str := fmt.Sprintf("%v", value)
+	if s.ValueSanitizer != nil {
+		str = s.ValueSanitizer(str)
+	}
+
+	if s.MaxValueLength <= 0 || len(str) <= s.MaxValueLength {
+		return str, nil
+	}
+	if s.OversizedValues == RejectOversizedValues {
+		return "", ErrValueTooLarge
+	}
+	indicator := s.TruncationIndicator
+	if indicator == "" {
+		indicator = DefaultTruncationIndicator
+	}
+	return truncateValue(str, s.MaxValueLength, indicator), nil
+}
+
+// truncateValue returns value cut to at most length bytes, including the indicator, without splitting a UTF-8
+// sequence
+func truncateValue(value string, length int, indicator string) string {
+	if len(indicator) >= length {
+		return indicator[:length]
+	}
+
+	end := length - len(indicator)
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end] + indicator
+}
@@ -0,0 +1,90 @@
+package zbx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestSanitizeValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"plain":                "plain",
+		"tab\tand\nnewline":    "tab\tand\nnewline",
+		"crlf\r\nline\rend":    "crlf\nline\nend",
+		"nul\x00byte":          "nulbyte",
+		"bell\x07 and \x7fdel": "bell and del",
+		"bad \xff utf8":        "bad � utf8",
+		"unicode é ✓":          "unicode é ✓",
+	}
+	for input, expected := range tests {
+		if result := zbx.SanitizeValue(input); result != expected {
+			t.Errorf("Unexpected result for %q. Expected %q got %q", input, expected, result)
+		}
+	}
+}
+
+func TestValueSanitizer(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return "a\x00b\r\n", nil
+		},
+		ValueSanitizer: func(value string) string {
+			return strings.ToUpper(zbx.SanitizeValue(value))
+		},
+	})
+
+	if reply := queryKey(t, addr, "value"); reply != "AB\n" {
+		t.Errorf("Unexpected reply. Expected %q got %q", "AB\n", reply)
+	}
+}
+
+func TestMaxValueLength(t *testing.T) {
+	t.Parallel()
+
+	itemFunc := func(key string) (interface{}, error) {
+		switch key {
+		case "short":
+			return "short", nil
+		case "long":
+			return strings.Repeat("a", 30), nil
+		case "unicode":
+			return strings.Repeat("é", 10), nil
+		}
+		return nil, nil
+	}
+
+	truncate := startTestServer(t, &zbx.Server{
+		ItemFunc:       itemFunc,
+		MaxValueLength: 20,
+	})
+	if reply := queryKey(t, truncate, "short"); reply != "short" {
+		t.Errorf("Unexpected reply for short value: %q", reply)
+	}
+	if reply := queryKey(t, truncate, "long"); reply != "aaaaaa...(truncated)" {
+		t.Errorf("Unexpected reply for long value: %q", reply)
+	}
+
+	indicator := startTestServer(t, &zbx.Server{
+		ItemFunc:            itemFunc,
+		MaxValueLength:      8,
+		TruncationIndicator: "~",
+	})
+	// Each é is 2 bytes and must not be split
+	if reply := queryKey(t, indicator, "unicode"); reply != "ééé~" {
+		t.Errorf("Unexpected reply for unicode value: %q", reply)
+	}
+
+	reject := startTestServer(t, &zbx.Server{
+		ItemFunc:        itemFunc,
+		MaxValueLength:  20,
+		OversizedValues: zbx.RejectOversizedValues,
+	})
+	if reply := queryKey(t, reject, "long"); reply != "ZBX_NOTSUPPORTED\x00"+zbx.ErrValueTooLarge.Error() {
+		t.Errorf("Unexpected reply for rejected value: %q", reply)
+	}
+}
@@ -0,0 +1,130 @@
+package zbx
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the type of information of a Zabbix item, which determines the values that the Zabbix server accepts
+// for it
+type ValueType int
+
+const (
+	// ValueTypeAny does not restrict values, and is used for keys without a declared type
+	ValueTypeAny ValueType = iota
+	// ValueTypeUnsigned is a "Numeric (unsigned)" item, which only accepts integers from 0 to 18446744073709551615
+	ValueTypeUnsigned
+	// ValueTypeFloat is a "Numeric (float)" item, which only accepts finite numbers
+	ValueTypeFloat
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeAny:
+		return "any"
+	case ValueTypeUnsigned:
+		return "unsigned"
+	case ValueTypeFloat:
+		return "float"
+	}
+	return fmt.Sprintf("ValueType(%d)", int(t))
+}
+
+// convertValue checks that value is acceptable for the type declared for key in the ValueTypes of the server, and
+// returns it formatted for that type. Numbers are formatted in full, as the server does not accept exponents for
+// unsigned items. Values of keys without a declared type, and streamed values, are returned as they are.
+func (s *Server) convertValue(key string, value interface{}) (interface{}, error) {
+	if len(s.ValueTypes) == 0 {
+		return value, nil
+	}
+	if _, ok := value.(io.Reader); ok {
+		return value, nil
+	}
+	name, _, err := ParseKey(key)
+	if err != nil {
+		return value, nil
+	}
+
+	switch s.ValueTypes[name] {
+	case ValueTypeUnsigned:
+		if converted, ok := unsignedValue(value); ok {
+			return converted, nil
+		}
+		return nil, fmt.Errorf("invalid value for item '%s': '%v' is not an unsigned integer", key, value)
+	case ValueTypeFloat:
+		if converted, ok := floatValue(value); ok {
+			return converted, nil
+		}
+		return nil, fmt.Errorf("invalid value for item '%s': '%v' is not a finite number", key, value)
+	}
+	return value, nil
+}
+
+// unsignedValue returns value formatted as an unsigned integer, if it is one
+func unsignedValue(value interface{}) (string, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return "", false
+		}
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		// 2^64 is the first float that does not fit, as float64(math.MaxUint64) rounds up to it
+		if f < 0 || f >= math.Exp2(64) || f != math.Trunc(f) {
+			return "", false
+		}
+		return strconv.FormatFloat(f, 'f', 0, 64), true
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", true
+		}
+		return "0", true
+	}
+
+	str := strings.TrimSpace(fmt.Sprintf("%v", value))
+	if _, err := strconv.ParseUint(str, 10, 64); err != nil {
+		return "", false
+	}
+	return str, true
+}
+
+// floatValue returns value formatted as a finite number without an exponent, if it is one
+func floatValue(value interface{}) (string, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		bits := 64
+		if v.Kind() == reflect.Float32 {
+			bits = 32
+		}
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(f, 'f', -1, bits), true
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", true
+		}
+		return "0", true
+	}
+
+	// Strings are formatted again, as ParseFloat also accepts exponents and hexadecimal numbers
+	f, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprintf("%v", value)), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
@@ -0,0 +1,84 @@
+package zbx_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestValueTypes(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]interface{}{
+		"negative":  -1,
+		"zero":      0,
+		"max":       uint64(math.MaxUint64),
+		"large":     1e19,
+		"toolarge":  1e20,
+		"fraction":  1.5,
+		"string":    " 42 ",
+		"text":      "many",
+		"bool":      true,
+		"nan":       math.NaN(),
+		"small":     1e-7,
+		"float32":   float32(0.1),
+		"exponent":  "1e5",
+		"hex":       " 0x1p3",
+		"overflows": "18446744073709551616",
+	}
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			name, params, _ := zbx.ParseKey(key)
+			if name == "untyped" {
+				return -1, nil
+			}
+			return values[params[0]], nil
+		},
+		ValueTypes: map[string]zbx.ValueType{
+			"unsigned": zbx.ValueTypeUnsigned,
+			"float":    zbx.ValueTypeFloat,
+		},
+	}
+
+	expected := map[string]string{
+		"unsigned[zero]":      "0",
+		"unsigned[max]":       "18446744073709551615",
+		"unsigned[large]":     "10000000000000000000",
+		"unsigned[string]":    "42",
+		"unsigned[bool]":      "1",
+		"float[negative]":     "-1",
+		"float[small]":        "0.0000001",
+		"float[float32]":      "0.1",
+		"float[fraction]":     "1.5",
+		"float[exponent]":     "100000",
+		"float[hex]":          "8",
+		"float[string]":       "42",
+		"float[max]":          "18446744073709551615",
+		"unsigned[overflows]": "",
+		"unsigned[negative]":  "",
+		"unsigned[toolarge]":  "",
+		"unsigned[fraction]":  "",
+		"unsigned[text]":      "",
+		"float[nan]":          "",
+		"float[text]":         "",
+		"untyped":             "-1",
+	}
+	keys := []string{}
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for _, result := range server.Validate(keys) {
+		expectedValue := expected[result.Key]
+		if expectedValue == "" {
+			if result.Status != zbx.ValidationError || !strings.Contains(result.Error.Error(), "'"+result.Key+"'") {
+				t.Errorf("Unexpected result for invalid value of '%s': %+v", result.Key, result)
+			}
+			continue
+		}
+		if result.Status != zbx.ValidationSupported || result.Value != expectedValue {
+			t.Errorf("Unexpected result for '%s'. Expected '%s' got %+v", result.Key, expectedValue, result)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package zbx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultWarmUpConcurrency is how many items are requested at once during warm-up when a server does not have a
+// WarmUpConcurrency
+const DefaultWarmUpConcurrency = 4
+
+// ErrUnknownKey is reported for warm-up keys that are unknown to the ItemFunc
+var ErrUnknownKey = fmt.Errorf("item key unknown")
+
+// warmUp requests each of the WarmUpKeys of the server once, with at most WarmUpConcurrency at a time, writing any
+// that return an error or are unknown to ErrorLog and the ErrorHandler. Keys are skipped while the server is in
+// maintenance mode.
+func (s *Server) warmUp() {
+	concurrency := s.WarmUpConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultWarmUpConcurrency
+	}
+
+	slots := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, key := range s.WarmUpKeys {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			value, err := s.itemValue(key, s.ItemFunc)
+			if err != nil {
+				closeValue(value)
+			} else if reader, ok := value.(io.Reader); ok {
+				err = discardValue(reader)
+			} else if value == nil {
+				err = ErrUnknownKey
+			} else {
+				_, err = s.formatValue(value)
+			}
+			if errors.Is(err, ErrNoValue) || errors.Is(err, ErrMaintenance) {
+				// The first sample of a counter has no value, which is why it is warmed up
+				return
+			}
+			if err != nil {
+				errorWrite("Error warming up item: %s,%s", fmt.Sprintf("key='%s'", key), fmt.Sprintf("error='%s'", err.Error()))
+				s.handleError(ErrorEvent{Category: ErrorCategoryItem, Key: key, Err: err})
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	s.updateStats(func(stats *Stats) {
+		stats.WarmedUp = true
+	})
+}
+
+// discardValue reads and closes the streamed value reader without keeping it, as warming up only needs the item to
+// be requested
+func discardValue(reader io.Reader) error {
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(reader, MaxStreamedValueLength+1))
+	if err != nil {
+		return err
+	}
+	if n > MaxStreamedValueLength {
+		return ErrValueTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package zbx_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+func TestWarmUp(t *testing.T) {
+	t.Parallel()
+
+	lock := sync.Mutex{}
+	calls := map[string]int{}
+	running, maxRunning := 0, 0
+	events := map[string]error{}
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			lock.Lock()
+			calls[key]++
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			lock.Lock()
+			running--
+			lock.Unlock()
+
+			switch key {
+			case "broken":
+				return nil, fmt.Errorf("missing configuration")
+			case "unknown":
+				return nil, nil
+			}
+			return 1, nil
+		},
+		WarmUpKeys:        []string{"cache.a", "cache.b", "cache.c", "broken", "unknown"},
+		WarmUpConcurrency: 2,
+		ErrorHandler: zbx.ErrorHandlerFunc(func(event zbx.ErrorEvent) {
+			lock.Lock()
+			events[event.Key] = event.Err
+			lock.Unlock()
+		}),
+	}
+	startTestServer(t, server)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.Stats().WarmedUp {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not warm up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, key := range server.WarmUpKeys {
+		if calls[key] != 1 {
+			t.Errorf("Unexpected number of calls for '%s': %d", key, calls[key])
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("Too many items requested at once: %d", maxRunning)
+	}
+	if len(events) != 2 || events["broken"] == nil || !errors.Is(events["unknown"], zbx.ErrUnknownKey) {
+		t.Errorf("Unexpected error events: %v", events)
+	}
+}
+
+// countingReader is a streamed value of length bytes that records how much of it was read and if it was closed
+type countingReader struct {
+	length int64
+	read   int64
+	closed bool
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.read >= r.length {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.length-r.read {
+		p = p[:r.length-r.read]
+	}
+	r.read += int64(len(p))
+	return len(p), nil
+}
+
+func (r *countingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestWarmUpSkipped(t *testing.T) {
+	t.Parallel()
+
+	lock := sync.Mutex{}
+	events := []zbx.ErrorEvent{}
+	stream := &countingReader{length: 64 << 20}
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			if key == "stream" {
+				return stream, nil
+			}
+			return 1, nil
+		},
+		WarmUpKeys: []string{"stream"},
+		ErrorHandler: zbx.ErrorHandlerFunc(func(event zbx.ErrorEvent) {
+			lock.Lock()
+			events = append(events, event)
+			lock.Unlock()
+		}),
+	}
+	server.SetMaintenance(true)
+	startTestServer(t, server)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.Stats().WarmedUp {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not warm up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Keys are not requested during maintenance, and that is not an error
+	lock.Lock()
+	defer lock.Unlock()
+	if len(events) != 0 {
+		t.Errorf("Unexpected error events: %v", events)
+	}
+	if stream.read != 0 || stream.closed {
+		t.Errorf("Item was requested during maintenance")
+	}
+}
+
+func TestWarmUpStream(t *testing.T) {
+	t.Parallel()
+
+	lock := sync.Mutex{}
+	events := []zbx.ErrorEvent{}
+	stream := &countingReader{length: 64 << 20}
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return stream, nil
+		},
+		WarmUpKeys: []string{"stream"},
+		ErrorHandler: zbx.ErrorHandlerFunc(func(event zbx.ErrorEvent) {
+			lock.Lock()
+			events = append(events, event)
+			lock.Unlock()
+		}),
+	}
+	startTestServer(t, server)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.Stats().WarmedUp {
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not warm up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(events) != 0 {
+		t.Errorf("Unexpected error events: %v", events)
+	}
+	if stream.read != stream.length || !stream.closed {
+		t.Errorf("Streamed value was not drained and closed: read %d closed %v", stream.read, stream.closed)
+	}
+}
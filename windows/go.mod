@@ -0,0 +1,9 @@
+module github.com/ecnepsnai/zbx/windows
+
+go 1.17
+
+require (
+	github.com/ecnepsnai/zbx v0.0.0-20261016123541-ef93aff01069
+	github.com/go-ole/go-ole v1.3.0
+	golang.org/x/sys v0.9.0
+)
@@ -0,0 +1,174 @@
+package windows
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// perfSampleInterval is how often performance counters are sampled, which is also the period over which each
+	// sample of a rate counter is calculated
+	perfSampleInterval = time.Second
+	// perfCounterExpiry is how long a performance counter is sampled for after it was last requested
+	perfCounterExpiry = 24 * time.Hour
+	// perfFirstSampleTimeout is how long the first request for a performance counter waits for its first sample, which
+	// is well below the timeout of the Zabbix server
+	perfFirstSampleTimeout = 3 * time.Second
+)
+
+// perfQuery is an open query for a single performance counter
+type perfQuery interface {
+	// Collect samples the counter. Rate counters are calculated from the previous sample, so the first sample of
+	// a rate counter is not a valid value.
+	Collect() (float64, error)
+	Close()
+}
+
+type perfCounterID struct {
+	path    string
+	english bool
+}
+
+// perfCounterSamples are the samples of a performance counter, oldest first
+type perfCounterSamples struct {
+	query   perfQuery
+	samples []float64
+	// keep is the number of samples kept, for the longest interval requested
+	keep int
+	// err is the error from the latest sample, if it failed
+	err       error
+	requested time.Time
+	// ready is closed once the counter has been sampled for the first time
+	ready chan struct{}
+}
+
+// perfCollector samples performance counters in the background, from when they are first requested until they have
+// not been requested for expiry, so that requests are answered with the average of the samples over their interval
+// without waiting for it
+type perfCollector struct {
+	open     func(path string, english bool) (perfQuery, error)
+	interval time.Duration
+	expiry   time.Duration
+
+	lock     sync.Mutex
+	counters map[perfCounterID]*perfCounterSamples
+}
+
+var defaultPerfCollector = &perfCollector{
+	open:     openPerfCounter,
+	interval: perfSampleInterval,
+	expiry:   perfCounterExpiry,
+}
+
+// value returns the average of the samples of the counter over interval. The first request for a counter waits for
+// its first sample.
+func (c *perfCollector) value(path string, interval time.Duration, english bool) (float64, error) {
+	keep := int(interval / c.interval)
+	if keep < 1 {
+		keep = 1
+	}
+
+	c.lock.Lock()
+	id := perfCounterID{path: path, english: english}
+	counter := c.counters[id]
+	if counter == nil {
+		query, err := c.open(path, english)
+		if err != nil {
+			c.lock.Unlock()
+			return 0, err
+		}
+		// The first sample of a rate counter is not a valid value, so it is only used as the starting point of the
+		// first background sample
+		query.Collect()
+		counter = &perfCounterSamples{query: query, ready: make(chan struct{})}
+		if len(c.counters) == 0 {
+			c.counters = map[perfCounterID]*perfCounterSamples{}
+			go c.run()
+		}
+		c.counters[id] = counter
+	}
+	counter.requested = time.Now()
+	if keep > counter.keep {
+		counter.keep = keep
+	}
+	c.lock.Unlock()
+
+	select {
+	case <-counter.ready:
+	case <-time.After(perfFirstSampleTimeout):
+		return 0, fmt.Errorf("no data collected for counter '%s'", path)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if counter.err != nil {
+		return 0, counter.err
+	}
+	samples := counter.samples
+	if len(samples) > keep {
+		samples = samples[len(samples)-keep:]
+	}
+	sum := 0.0
+	for _, sample := range samples {
+		sum += sample
+	}
+	return sum / float64(len(samples)), nil
+}
+
+// run samples the counters every interval until there are none left
+func (c *perfCollector) run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !c.collect() {
+			return
+		}
+	}
+}
+
+// collect samples every counter, and stops sampling counters that have expired. Returns false once there are no
+// counters left to sample.
+func (c *perfCollector) collect() bool {
+	c.lock.Lock()
+	counters := make([]*perfCounterSamples, 0, len(c.counters))
+	for id, counter := range c.counters {
+		if time.Since(counter.requested) > c.expiry {
+			counter.query.Close()
+			delete(c.counters, id)
+			continue
+		}
+		counters = append(counters, counter)
+	}
+	if len(c.counters) == 0 {
+		// Requests for new counters start sampling again
+		c.counters = nil
+		c.lock.Unlock()
+		return false
+	}
+	c.lock.Unlock()
+
+	// Counters are sampled without holding the lock, as requests for other counters do not need to wait for them.
+	// Only this goroutine samples or closes counters once they have been added.
+	for _, counter := range counters {
+		value, err := counter.query.Collect()
+
+		c.lock.Lock()
+		counter.err = err
+		if err != nil {
+			counter.samples = nil
+		} else {
+			counter.samples = append(counter.samples, value)
+			if len(counter.samples) > counter.keep {
+				counter.samples = counter.samples[len(counter.samples)-counter.keep:]
+			}
+		}
+		select {
+		case <-counter.ready:
+		default:
+			close(counter.ready)
+		}
+		c.lock.Unlock()
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package windows
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePerfQuery returns the values sent on next as samples, and an error once next is closed
+type fakePerfQuery struct {
+	next   chan float64
+	closed int32
+}
+
+func (q *fakePerfQuery) Collect() (float64, error) {
+	value, ok := <-q.next
+	if !ok {
+		return 0, fmt.Errorf("counter removed")
+	}
+	return value, nil
+}
+
+func (q *fakePerfQuery) Close() {
+	atomic.StoreInt32(&q.closed, 1)
+}
+
+func TestPerfCollector(t *testing.T) {
+	t.Parallel()
+
+	query := &fakePerfQuery{next: make(chan float64)}
+	collector := &perfCollector{
+		open: func(path string, english bool) (perfQuery, error) {
+			if path != `\System\Processes` {
+				return nil, fmt.Errorf("invalid counter '%s'", path)
+			}
+			return query, nil
+		},
+		interval: time.Millisecond,
+		expiry:   50 * time.Millisecond,
+	}
+
+	if _, err := collector.value(`\System\Invalid`, time.Millisecond, false); err == nil {
+		t.Errorf("No error seen for invalid counter")
+	}
+
+	// The first request waits for the first background sample, and the sample it collects itself is not used
+	type result struct {
+		value float64
+		err   error
+	}
+	first := make(chan result, 1)
+	go func() {
+		value, err := collector.value(`\System\Processes`, 3*time.Millisecond, false)
+		first <- result{value, err}
+	}()
+	query.next <- 100
+	query.next <- 1
+	if r := <-first; r.err != nil || r.value != 1 {
+		t.Errorf("Unexpected first value: %v %v", r.value, r.err)
+	}
+
+	// The samples are averaged over the requested interval
+	query.next <- 2
+	query.next <- 3
+	query.next <- 4
+	value := 0.0
+	for i := 0; i < 100 && value != 3; i++ {
+		time.Sleep(time.Millisecond)
+		value, _ = collector.value(`\System\Processes`, 3*time.Millisecond, false)
+	}
+	if value != 3 {
+		t.Errorf("Unexpected average value. Expected 3 got %v", value)
+	}
+	if value, err := collector.value(`\System\Processes`, time.Millisecond, false); err != nil || value != 4 {
+		t.Errorf("Unexpected latest value: %v %v", value, err)
+	}
+
+	// Errors sampling the counter are returned instead of older samples
+	close(query.next)
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		time.Sleep(time.Millisecond)
+		_, err = collector.value(`\System\Processes`, time.Millisecond, false)
+	}
+	if err == nil || err.Error() != "counter removed" {
+		t.Errorf("Unexpected error for removed counter: %v", err)
+	}
+
+	// The counter is no longer sampled once it has not been requested for expiry
+	for i := 0; i < 200 && atomic.LoadInt32(&query.closed) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&query.closed) == 0 {
+		t.Errorf("Expired counter not closed")
+	}
+}
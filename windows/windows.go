@@ -0,0 +1,202 @@
+/*
+Package windows provides item values for the Windows specific Zabbix item keys used by the "Windows by Zabbix agent"
+template: performance counters, and the state and discovery of Windows services.
+
+The items are only supported on Windows. On other platforms requests for them return an error.
+*/
+package windows
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// MaxPerfCounterInterval is the longest interval that can be requested for a perf_counter item
+const MaxPerfCounterInterval = 900 * time.Second
+
+// Service states as reported by the service.info item
+const (
+	ServiceRunning         = 0
+	ServicePaused          = 1
+	ServiceStartPending    = 2
+	ServicePausePending    = 3
+	ServiceContinuePending = 4
+	ServiceStopPending     = 5
+	ServiceStopped         = 6
+	ServiceUnknown         = 7
+	ServiceNotFound        = 255
+)
+
+// Service startup types as reported by the service.info item
+const (
+	StartupAutomatic        = 0
+	StartupAutomaticDelayed = 1
+	StartupManual           = 2
+	StartupDisabled         = 3
+	StartupUnknown          = 4
+)
+
+var serviceStateNames = map[int]string{
+	ServiceRunning:         "running",
+	ServicePaused:          "paused",
+	ServiceStartPending:    "start pending",
+	ServicePausePending:    "pause pending",
+	ServiceContinuePending: "continue pending",
+	ServiceStopPending:     "stop pending",
+	ServiceStopped:         "stopped",
+	ServiceUnknown:         "unknown",
+}
+
+var startupNames = map[int]string{
+	StartupAutomatic:        "automatic",
+	StartupAutomaticDelayed: "automatic delayed",
+	StartupManual:           "manual",
+	StartupDisabled:         "disabled",
+	StartupUnknown:          "unknown",
+}
+
+// Service describes a Windows service
+type Service struct {
+	Name        string
+	DisplayName string
+	Description string
+	// State is one of the Service* state constants
+	State int
+	// Path is the command line of the service
+	Path string
+	// User is the account the service runs as
+	User string
+	// Startup is one of the Startup* constants
+	Startup int
+	// StartupTrigger is true if the service is started by a trigger
+	StartupTrigger bool
+}
+
+// Items returns an ItemFunc for the performance counter and service keys of the "Windows by Zabbix agent" template.
+// Other keys, and keys that cannot be parsed, are passed to next, or are unknown if next is nil.
+//
+//   - perf_counter[<counter>,<interval>] and perf_counter_en[<counter>,<interval>], the average value of a
+//     performance counter over the last interval seconds (default 1). perf_counter_en always uses English counter
+//     names. Counters are sampled every second in the background from the first request for them, so the first
+//     request waits for the first sample and averages over the samples collected so far until interval has passed.
+//   - service.info[<service>,<param>], where param is one of state (default), displayname, path, user, startup or
+//     description.
+//   - service.discovery, a JSON array for low-level discovery of Windows services.
+//
+// On platforms other than Windows these keys return an error.
+func Items(next zbx.ItemFunc) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil {
+			// The key is not one of these, but may still be known to next
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		switch name {
+		case "perf_counter", "perf_counter_en":
+			counter, interval, err := perfCounterParams(params)
+			if err != nil {
+				return nil, err
+			}
+			return defaultPerfCollector.value(counter, interval, name == "perf_counter_en")
+		case "service.info":
+			if len(params) == 0 || params[0] == "" {
+				return nil, fmt.Errorf("missing service name")
+			}
+			param := ""
+			if len(params) > 1 {
+				param = params[1]
+			}
+			return serviceInfoValue(params[0], param)
+		case "service.discovery":
+			services, err := listServices()
+			if err != nil {
+				return nil, err
+			}
+			return discoveryJSON(services)
+		}
+
+		if next == nil {
+			return nil, nil
+		}
+		return next(key)
+	}
+}
+
+// perfCounterParams returns the counter path and interval from the parameters of a perf_counter key
+func perfCounterParams(params []string) (string, time.Duration, error) {
+	if len(params) == 0 || params[0] == "" {
+		return "", 0, fmt.Errorf("missing counter path")
+	}
+	if len(params) > 2 {
+		return "", 0, fmt.Errorf("too many parameters")
+	}
+	interval := time.Second
+	if len(params) == 2 && params[1] != "" {
+		seconds, err := strconv.Atoi(params[1])
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxPerfCounterInterval {
+			return "", 0, fmt.Errorf("invalid interval '%s'", params[1])
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
+	return params[0], interval, nil
+}
+
+func serviceInfoValue(name string, param string) (interface{}, error) {
+	service, err := serviceInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	if service == nil {
+		if param == "" || param == "state" {
+			return ServiceNotFound, nil
+		}
+		return nil, fmt.Errorf("cannot find service '%s'", name)
+	}
+
+	switch param {
+	case "", "state":
+		return service.State, nil
+	case "displayname":
+		return service.DisplayName, nil
+	case "path":
+		return service.Path, nil
+	case "user":
+		return service.User, nil
+	case "startup":
+		return service.Startup, nil
+	case "description":
+		return service.Description, nil
+	}
+	return nil, fmt.Errorf("invalid parameter '%s'", param)
+}
+
+// discoveryJSON returns the low-level discovery JSON for services, using the same macros as the Zabbix agent
+func discoveryJSON(services []Service) (string, error) {
+	rows := make([]map[string]interface{}, len(services))
+	for i, service := range services {
+		trigger := 0
+		if service.StartupTrigger {
+			trigger = 1
+		}
+		rows[i] = map[string]interface{}{
+			"{#SERVICE.NAME}":           service.Name,
+			"{#SERVICE.DISPLAYNAME}":    service.DisplayName,
+			"{#SERVICE.DESCRIPTION}":    service.Description,
+			"{#SERVICE.STATE}":          service.State,
+			"{#SERVICE.STATENAME}":      serviceStateNames[service.State],
+			"{#SERVICE.PATH}":           service.Path,
+			"{#SERVICE.USER}":           service.User,
+			"{#SERVICE.STARTUP}":        service.Startup,
+			"{#SERVICE.STARTUPNAME}":    startupNames[service.Startup],
+			"{#SERVICE.STARTUPTRIGGER}": trigger,
+		}
+	}
+	return zbx.JSON(rows)
+}
@@ -0,0 +1,93 @@
+package windows
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPerfCounterParams(t *testing.T) {
+	t.Parallel()
+
+	counter, interval, err := perfCounterParams([]string{`\Processor(_Total)\% Processor Time`})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if counter != `\Processor(_Total)\% Processor Time` || interval != time.Second {
+		t.Errorf("Unexpected params '%s' %s", counter, interval)
+	}
+	if _, interval, _ := perfCounterParams([]string{`\System\Processes`, "60"}); interval != time.Minute {
+		t.Errorf("Unexpected interval %s", interval)
+	}
+
+	invalid := [][]string{
+		{},
+		{""},
+		{`\System\Processes`, "0"},
+		{`\System\Processes`, "901"},
+		{`\System\Processes`, "abc"},
+		{`\System\Processes`, "1", "1"},
+	}
+	for _, params := range invalid {
+		if _, _, err := perfCounterParams(params); err == nil {
+			t.Errorf("No error seen for invalid params %q", params)
+		}
+	}
+}
+
+func TestDiscoveryJSON(t *testing.T) {
+	t.Parallel()
+
+	result, err := discoveryJSON([]Service{
+		{
+			Name:           "Spooler",
+			DisplayName:    "Print Spooler",
+			State:          ServiceStopped,
+			Path:           `C:\Windows\System32\spoolsv.exe`,
+			User:           "LocalSystem",
+			Startup:        StartupAutomaticDelayed,
+			StartupTrigger: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	rows := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(result), &rows); err != nil {
+		t.Fatalf("Invalid JSON: %s", err.Error())
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Unexpected number of rows %d", len(rows))
+	}
+	expected := map[string]interface{}{
+		"{#SERVICE.NAME}":           "Spooler",
+		"{#SERVICE.STATENAME}":      "stopped",
+		"{#SERVICE.STATE}":          float64(ServiceStopped),
+		"{#SERVICE.STARTUPNAME}":    "automatic delayed",
+		"{#SERVICE.STARTUPTRIGGER}": float64(1),
+	}
+	for macro, value := range expected {
+		if rows[0][macro] != value {
+			t.Errorf("Unexpected value for %s. Expected %v got %v", macro, value, rows[0][macro])
+		}
+	}
+}
+
+func TestItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := Items(func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	})
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := Items(nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package windows
+
+import "fmt"
+
+var errNotSupported = fmt.Errorf("item is only supported on windows")
+
+func openPerfCounter(path string, english bool) (perfQuery, error) {
+	return nil, errNotSupported
+}
+
+func serviceInfo(name string) (*Service, error) {
+	return nil, errNotSupported
+}
+
+func listServices() ([]Service, error) {
+	return nil, errNotSupported
+}
+
+func wmiQuery(namespace string, query string) ([]wmiObject, error) {
+	return nil, errNotSupported
+}
@@ -0,0 +1,200 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+	"unsafe"
+
+	syswin "golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var (
+	pdh                             = syswin.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW               = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddCounterW              = pdh.NewProc("PdhAddCounterW")
+	procPdhAddEnglishCounterW       = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterValue = pdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhCloseQuery               = pdh.NewProc("PdhCloseQuery")
+)
+
+const (
+	pdhFmtDouble        = 0x00000200
+	pdhCStatusValidData = 0x00000000
+	pdhCStatusNewData   = 0x00000001
+)
+
+// pdhFmtCounterValueDouble is a PDH_FMT_COUNTERVALUE with the double member of the union
+type pdhFmtCounterValueDouble struct {
+	CStatus uint32
+	_       uint32
+	Value   float64
+}
+
+func pdhCall(proc *syswin.LazyProc, args ...uintptr) error {
+	if err := proc.Find(); err != nil {
+		return err
+	}
+	status, _, _ := proc.Call(args...)
+	if status != 0 {
+		return fmt.Errorf("%s failed with status 0x%08x", proc.Name, uint32(status))
+	}
+	return nil
+}
+
+// pdhQuery is a PDH query for a single performance counter
+type pdhQuery struct {
+	path    string
+	query   syswin.Handle
+	counter syswin.Handle
+}
+
+func openPerfCounter(path string, english bool) (perfQuery, error) {
+	pathPtr, err := syswin.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &pdhQuery{path: path}
+	if err := pdhCall(procPdhOpenQueryW, 0, 0, uintptr(unsafe.Pointer(&q.query))); err != nil {
+		return nil, err
+	}
+
+	addCounter := procPdhAddCounterW
+	if english {
+		addCounter = procPdhAddEnglishCounterW
+	}
+	if err := pdhCall(addCounter, uintptr(q.query), uintptr(unsafe.Pointer(pathPtr)), 0, uintptr(unsafe.Pointer(&q.counter))); err != nil {
+		q.Close()
+		return nil, fmt.Errorf("invalid counter '%s': %s", path, err.Error())
+	}
+	return q, nil
+}
+
+func (q *pdhQuery) Collect() (float64, error) {
+	if err := pdhCall(procPdhCollectQueryData, uintptr(q.query)); err != nil {
+		return 0, err
+	}
+
+	value := pdhFmtCounterValueDouble{}
+	if err := pdhCall(procPdhGetFormattedCounterValue, uintptr(q.counter), pdhFmtDouble, 0, uintptr(unsafe.Pointer(&value))); err != nil {
+		return 0, err
+	}
+	if value.CStatus != pdhCStatusValidData && value.CStatus != pdhCStatusNewData {
+		return 0, fmt.Errorf("counter '%s' has no valid data", q.path)
+	}
+	return value.Value, nil
+}
+
+func (q *pdhQuery) Close() {
+	procPdhCloseQuery.Call(uintptr(q.query))
+}
+
+var serviceStates = map[svc.State]int{
+	svc.Running:         ServiceRunning,
+	svc.Paused:          ServicePaused,
+	svc.StartPending:    ServiceStartPending,
+	svc.PausePending:    ServicePausePending,
+	svc.ContinuePending: ServiceContinuePending,
+	svc.StopPending:     ServiceStopPending,
+	svc.Stopped:         ServiceStopped,
+}
+
+// serviceInfo returns information about the service with the given name, or nil if there is no such service
+func serviceInfo(name string) (*Service, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to service manager: %s", err.Error())
+	}
+	defer manager.Disconnect()
+
+	return openService(manager, name)
+}
+
+func listServices() ([]Service, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to service manager: %s", err.Error())
+	}
+	defer manager.Disconnect()
+
+	names, err := manager.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list services: %s", err.Error())
+	}
+	services := []Service{}
+	for _, name := range names {
+		service, err := openService(manager, name)
+		if err != nil || service == nil {
+			// Services can be removed, or be inaccessible, while listing
+			continue
+		}
+		services = append(services, *service)
+	}
+	return services, nil
+}
+
+func openService(manager *mgr.Mgr, name string) (*Service, error) {
+	s, err := manager.OpenService(name)
+	if err != nil {
+		if err == syswin.ERROR_SERVICE_DOES_NOT_EXIST {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot open service '%s': %s", name, err.Error())
+	}
+	defer s.Close()
+
+	config, err := s.Config()
+	if err != nil {
+		return nil, fmt.Errorf("cannot query service '%s' config: %s", name, err.Error())
+	}
+	service := &Service{
+		Name:           name,
+		DisplayName:    config.DisplayName,
+		Description:    config.Description,
+		State:          ServiceUnknown,
+		Path:           config.BinaryPathName,
+		User:           config.ServiceStartName,
+		Startup:        StartupUnknown,
+		StartupTrigger: hasStartupTrigger(s.Handle),
+	}
+
+	if status, err := s.Query(); err == nil {
+		if state, ok := serviceStates[status.State]; ok {
+			service.State = state
+		}
+	}
+
+	switch config.StartType {
+	case mgr.StartAutomatic:
+		service.Startup = StartupAutomatic
+		if config.DelayedAutoStart {
+			service.Startup = StartupAutomaticDelayed
+		}
+	case mgr.StartManual:
+		service.Startup = StartupManual
+	case mgr.StartDisabled:
+		service.Startup = StartupDisabled
+	}
+
+	return service, nil
+}
+
+// hasStartupTrigger returns true if the service has any trigger start events configured
+func hasStartupTrigger(handle syswin.Handle) bool {
+	var needed uint32
+	err := syswin.QueryServiceConfig2(handle, syswin.SERVICE_CONFIG_TRIGGER_INFO, nil, 0, &needed)
+	if err != syswin.ERROR_INSUFFICIENT_BUFFER || needed < 4 {
+		return false
+	}
+	buf := make([]byte, needed)
+	if err := syswin.QueryServiceConfig2(handle, syswin.SERVICE_CONFIG_TRIGGER_INFO, &buf[0], needed, &needed); err != nil {
+		return false
+	}
+	// The first member of SERVICE_TRIGGER_INFO is the number of triggers
+	return *(*uint32)(unsafe.Pointer(&buf[0])) > 0
+}
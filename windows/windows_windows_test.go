@@ -0,0 +1,37 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestItemsWindows(t *testing.T) {
+	items := Items(nil)
+
+	if value, err := items("service.info[EventLog]"); err != nil || value != ServiceRunning {
+		t.Errorf("Unexpected state for EventLog service: %v %v", value, err)
+	}
+	if value, err := items("service.info[zbx-no-such-service]"); err != nil || value != ServiceNotFound {
+		t.Errorf("Unexpected state for missing service: %v %v", value, err)
+	}
+	if _, err := items(`perf_counter_en["\System\Processes"]`); err != nil {
+		t.Errorf("Unexpected error reading perf counter: %s", err.Error())
+	}
+	if _, err := items("service.discovery"); err != nil {
+		t.Errorf("Unexpected error discovering services: %s", err.Error())
+	}
+}
+
+func TestWMIItemsWindows(t *testing.T) {
+	items := WMIItems(nil, []*regexp.Regexp{regexp.MustCompile(`^select .* from Win32_OperatingSystem$`)})
+
+	if value, err := items(`wmi.get[root\cimv2,"select Caption from Win32_OperatingSystem"]`); err != nil || value == "" {
+		t.Errorf("Unexpected result from wmi.get: %v %v", value, err)
+	}
+	if _, err := items(`wmi.getall[root\cimv2,"select Caption, Version from Win32_OperatingSystem"]`); err != nil {
+		t.Errorf("Unexpected error from wmi.getall: %s", err.Error())
+	}
+}
@@ -0,0 +1,100 @@
+package windows
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ecnepsnai/zbx"
+)
+
+// DefaultWMINamespace is the namespace used by WMI items that do not specify one
+const DefaultWMINamespace = `root\cimv2`
+
+// wmiProperty is a single property of an object returned by a WMI query
+type wmiProperty struct {
+	Name  string
+	Value interface{}
+}
+
+// wmiObject is an object returned by a WMI query, with its properties in the order they were returned
+type wmiObject []wmiProperty
+
+// WMIItems returns an ItemFunc for the WMI keys below, which passes any other key, or a key that cannot be parsed, to
+// next. If next is nil those keys are unknown.
+//
+//   - wmi.get[<namespace>,<query>], the first property of the first object returned by the query.
+//   - wmi.getall[<namespace>,<query>], a JSON array of every object returned by the query.
+//
+// The Zabbix server can request any query, so only queries that match at least one of the regular expressions in
+// allow are run. Anchor each expression with ^ and $ to match whole queries. If namespace is empty
+// DefaultWMINamespace is used.
+//
+// On platforms other than Windows these keys return an error.
+func WMIItems(next zbx.ItemFunc, allow []*regexp.Regexp) zbx.ItemFunc {
+	return func(key string) (interface{}, error) {
+		name, params, err := zbx.ParseKey(key)
+		if err != nil || (name != "wmi.get" && name != "wmi.getall") {
+			if next == nil {
+				return nil, nil
+			}
+			return next(key)
+		}
+
+		if len(params) != 2 || params[1] == "" {
+			return nil, fmt.Errorf("invalid number of parameters")
+		}
+		namespace, query := params[0], params[1]
+		if namespace == "" {
+			namespace = DefaultWMINamespace
+		}
+		if !wmiQueryAllowed(allow, query) {
+			return nil, fmt.Errorf("query is not allowed")
+		}
+
+		objects, err := wmiQuery(namespace, query)
+		if err != nil {
+			return nil, err
+		}
+		if name == "wmi.get" {
+			return wmiGetValue(objects)
+		}
+		return wmiGetAllJSON(objects)
+	}
+}
+
+func wmiQueryAllowed(allow []*regexp.Regexp, query string) bool {
+	query = strings.TrimSpace(query)
+	for _, pattern := range allow {
+		if pattern.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
+
+func wmiGetValue(objects []wmiObject) (interface{}, error) {
+	if len(objects) == 0 || len(objects[0]) == 0 {
+		return nil, fmt.Errorf("query returned no results")
+	}
+	value := objects[0][0].Value
+	if value == nil {
+		return nil, fmt.Errorf("property '%s' has no value", objects[0][0].Name)
+	}
+	if _, isArray := value.([]interface{}); isArray {
+		return zbx.JSON(value)
+	}
+	return value, nil
+}
+
+func wmiGetAllJSON(objects []wmiObject) (string, error) {
+	rows := make([]map[string]interface{}, len(objects))
+	for i, object := range objects {
+		row := map[string]interface{}{}
+		for _, property := range object {
+			row[property.Name] = property.Value
+		}
+		rows[i] = row
+	}
+	return zbx.JSON(rows)
+}
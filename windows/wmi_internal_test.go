@@ -0,0 +1,75 @@
+package windows
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestWMIItemsAllowlist(t *testing.T) {
+	t.Parallel()
+
+	items := WMIItems(nil, []*regexp.Regexp{regexp.MustCompile(`^select Caption from Win32_OperatingSystem$`)})
+
+	if _, err := items(`wmi.get[root\cimv2,"select * from Win32_UserAccount"]`); err == nil || err.Error() != "query is not allowed" {
+		t.Errorf("Unexpected error for disallowed query: %v", err)
+	}
+	if _, err := items(`wmi.get[root\cimv2]`); err == nil {
+		t.Errorf("No error seen for missing query")
+	}
+	if value, err := items("agent.ping"); value != nil || err != nil {
+		t.Errorf("Unexpected result for unknown key: %v %v", value, err)
+	}
+}
+
+func TestWMIItemsNext(t *testing.T) {
+	t.Parallel()
+
+	received := ""
+	items := WMIItems(func(key string) (interface{}, error) {
+		received = key
+		return 1, nil
+	}, nil)
+
+	// Keys that cannot be parsed may still be known to next
+	if value, err := items("custom.key[a"); err != nil || value != 1 || received != "custom.key[a" {
+		t.Errorf("Key was not passed to next: %v %v", value, err)
+	}
+	if value, err := WMIItems(nil, nil)("custom.key[a"); value != nil || err != nil {
+		t.Errorf("Unexpected result without next: %v %v", value, err)
+	}
+}
+
+func TestWMIGetValue(t *testing.T) {
+	t.Parallel()
+
+	value, err := wmiGetValue([]wmiObject{{{Name: "Caption", Value: "Microsoft Windows Server 2022"}}})
+	if err != nil || value != "Microsoft Windows Server 2022" {
+		t.Errorf("Unexpected value: %v %v", value, err)
+	}
+	value, err = wmiGetValue([]wmiObject{{{Name: "IPAddress", Value: []interface{}{"10.0.0.1", "fe80::1"}}}})
+	if err != nil || value != `["10.0.0.1","fe80::1"]` {
+		t.Errorf("Unexpected value: %v %v", value, err)
+	}
+	if _, err := wmiGetValue([]wmiObject{}); err == nil {
+		t.Errorf("No error seen for empty results")
+	}
+	if _, err := wmiGetValue([]wmiObject{{{Name: "Caption"}}}); err == nil {
+		t.Errorf("No error seen for null value")
+	}
+}
+
+func TestWMIGetAllJSON(t *testing.T) {
+	t.Parallel()
+
+	result, err := wmiGetAllJSON([]wmiObject{
+		{{Name: "Name", Value: "C:"}, {Name: "FreeSpace", Value: uint64(1024)}},
+		{{Name: "Name", Value: "D:"}, {Name: "FreeSpace", Value: nil}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := `[{"FreeSpace":1024,"Name":"C:"},{"FreeSpace":null,"Name":"D:"}]`
+	if result != expected {
+		t.Errorf("Unexpected result. Expected %s got %s", expected, result)
+	}
+}
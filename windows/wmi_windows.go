@@ -0,0 +1,113 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// sFalse is returned by CoInitializeEx when COM was already initialized on the thread
+const sFalse = 0x00000001
+
+// wmiQuery runs query in namespace using the SWbemLocator scripting object
+func wmiQuery(namespace string, query string) ([]wmiObject, error) {
+	// COM is initialized per thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		if oleErr, ok := err.(*ole.OleError); !ok || oleErr.Code() != sFalse {
+			return nil, fmt.Errorf("cannot initialize COM: %s", err.Error())
+		}
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("WbemScripting.SWbemLocator")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create WMI locator: %s", err.Error())
+	}
+	defer unknown.Release()
+	locator, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create WMI locator: %s", err.Error())
+	}
+	defer locator.Release()
+
+	serviceRaw, err := oleutil.CallMethod(locator, "ConnectServer", nil, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to WMI namespace '%s': %s", namespace, err.Error())
+	}
+	defer serviceRaw.Clear()
+	service := serviceRaw.ToIDispatch()
+
+	resultRaw, err := oleutil.CallMethod(service, "ExecQuery", query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run WMI query: %s", err.Error())
+	}
+	defer resultRaw.Clear()
+	result := resultRaw.ToIDispatch()
+
+	objects := []wmiObject{}
+	err = oleutil.ForEach(result, func(itemRaw *ole.VARIANT) error {
+		item := itemRaw.ToIDispatch()
+		if item == nil {
+			return nil
+		}
+		propertiesRaw, err := oleutil.GetProperty(item, "Properties_")
+		if err != nil {
+			return err
+		}
+		defer propertiesRaw.Clear()
+
+		object := wmiObject{}
+		err = oleutil.ForEach(propertiesRaw.ToIDispatch(), func(propertyRaw *ole.VARIANT) error {
+			property := propertyRaw.ToIDispatch()
+			if property == nil {
+				return nil
+			}
+			nameRaw, err := oleutil.GetProperty(property, "Name")
+			if err != nil {
+				return err
+			}
+			defer nameRaw.Clear()
+			valueRaw, err := oleutil.GetProperty(property, "Value")
+			if err != nil {
+				return err
+			}
+			defer valueRaw.Clear()
+
+			object = append(object, wmiProperty{Name: nameRaw.ToString(), Value: variantValue(valueRaw)})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read WMI query results: %s", err.Error())
+	}
+	return objects, nil
+}
+
+// variantValue converts v to a value that can be formatted as an item value
+func variantValue(v *ole.VARIANT) interface{} {
+	if v.VT&ole.VT_ARRAY != 0 {
+		array := v.ToArray()
+		if array == nil {
+			return nil
+		}
+		return array.ToValueArray()
+	}
+	switch v.VT {
+	case ole.VT_DISPATCH, ole.VT_UNKNOWN:
+		return nil
+	}
+	return v.Value()
+}
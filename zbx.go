@@ -10,17 +10,27 @@ package zbx
 import (
 	"bytes"
 	"crypto/tls"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
 )
 
 // ErrorLog is the writer that error messages are written to. By default this is stderr.
 var ErrorLog io.Writer = os.Stderr
 
+// ErrorSampleInterval is the minimum time between logging the same type of protocol error from the same remote
+// host. Errors that occur more often are counted and the number suppressed is included with the next message that
+// is logged. This prevents scanners that repeatedly connect to the agent from flooding ErrorLog. By default this is
+// 0, where every error is logged.
+var ErrorSampleInterval time.Duration
+
 // ItemFunc describes the method invoked when the Zabbix Server (or proxy) is requesting
 // an item from this agent. The returned interface be encoded as a string and returned to the
 // server.
@@ -28,8 +38,16 @@ var ErrorLog io.Writer = os.Stderr
 // If error is not nil, it will be sent back to the server. If (nil, nil) is returned then it is
 // assumed the key is unknown.
 //
+// Very large values, such as file contents, can be returned as an io.Reader, which is copied to the
+// connection in chunks instead of being held in memory. Readers with Size and ReadAt methods, such
+// as *bytes.Reader, are sent in full and regular files from their current position. Other readers
+// are first written to a file in StreamSpoolDir to learn their length. If the reader is an
+// io.Closer it is closed once the value is sent, or when it is returned with an error. Streamed
+// values are limited to MaxStreamedValueLength and are subject to MaxValueLength, but when a
+// ValueSanitizer is set they are read into memory and formatted like any other value.
+//
 // Any calls to `panic()` will be recovered from and written to ErrorLog and the server will act as
-// if the key was unknown.
+// if the key was unknown, unless PanicAsError is set on the Server.
 type ItemFunc func(key string) (interface{}, error)
 
 // StartTLS will start the Zabbix agent on the specified address with TLS. The agent will present
@@ -40,168 +58,480 @@ func StartTLS(itemFunc ItemFunc, address string, certificate tls.Certificate) er
 		panic("itemFunc is nil")
 	}
 
-	config := &tls.Config{
-		Certificates: []tls.Certificate{certificate},
-	}
+	return (&Server{ItemFunc: itemFunc}).ListenAndServeTLS(address, certificate)
+}
 
-	l, err := tls.Listen("tcp", address, config)
-	if err != nil {
-		return err
+// StartTLSConfig will start the Zabbix agent on the specified address with TLS using the given configuration. See
+// Server.ListenAndServeTLSConfig for details.
+// Will panic if itemFunc or config is nil.
+func StartTLSConfig(itemFunc ItemFunc, address string, config *tls.Config) error {
+	if itemFunc == nil {
+		panic("itemFunc is nil")
 	}
-	StartListener(itemFunc, l)
-	return nil
+
+	return (&Server{ItemFunc: itemFunc}).ListenAndServeTLSConfig(address, config)
 }
 
-// Start the Zabbix agent on the specified address. Will block and always return on error.
+// Start the Zabbix agent on the specified address. Will block and always return on error, including
+// when the listener is closed.
 // Will panic if itemFunc is nil.
 func Start(itemFunc ItemFunc, address string) error {
 	if itemFunc == nil {
 		panic("itemFunc is nil")
 	}
 
-	l, err := net.Listen("tcp", address)
-	if err != nil {
-		return err
+	return (&Server{ItemFunc: itemFunc}).ListenAndServe(address)
+}
+
+// StartListener starts the Zabbix agent on the specified listener. Will block until the listener returns a
+// permanent error, such as when it is closed, and then returns that error. Temporary errors accepting
+// connections are retried with a backoff.
+// Will panic if itemFunc is nil.
+func StartListener(itemFunc ItemFunc, l net.Listener) error {
+	if itemFunc == nil {
+		panic("itemFunc is nil")
 	}
-	StartListener(itemFunc, l)
-	return nil
+
+	return (&Server{ItemFunc: itemFunc}).Serve(l)
 }
 
-// Start the Zabbix agent on the specified listener.
-func StartListener(itemFunc ItemFunc, l net.Listener) {
-	for {
-		conn, err := l.Accept()
-		if err != nil {
-			errorWrite("Error accepting connection: %s", fmt.Sprintf("error='%s'", err.Error()))
-			continue
-		}
-		go newConnection(itemFunc, conn)
+// StartListenerFD starts the Zabbix agent on the listening socket with the file descriptor fd, such as a socket that
+// was bound to port 10050 before the process dropped its privileges, or was passed in by a supervisor. Will block and
+// always return on error, including when the listener is closed.
+// Will panic if itemFunc is nil.
+func StartListenerFD(itemFunc ItemFunc, fd uintptr) error {
+	if itemFunc == nil {
+		panic("itemFunc is nil")
 	}
+
+	return (&Server{ItemFunc: itemFunc}).ServeFD(fd)
 }
 
-func newConnection(itemFunc ItemFunc, conn net.Conn) {
+func (s *Server) newConnection(conn net.Conn) {
 	who := conn.RemoteAddr().String()
+	host := remoteHost(who)
 
-	reply := consumeReader(itemFunc, conn)
-	if reply != nil {
-		if _, err := conn.Write(reply); err != nil {
-			errorWrite("Error writing reply: %s,%s", fmt.Sprintf("remote_addr='%s'", who), fmt.Sprintf("error='%s'", err.Error()))
+	defer conn.Close()
+	defer s.updateStats(func(stats *Stats) {
+		stats.OpenConnections--
+	})
+	if !s.trackConn(conn, true) {
+		return
+	}
+	defer s.trackConn(conn, false)
+	defer func() {
+		if r := recover(); r != nil {
+			errorWrite("Recovered from panic handling connection: %s,%s", fmt.Sprintf("remote_addr='%s'", who), fmt.Sprintf("panic='%v'", r))
+			ErrorLog.Write(debug.Stack())
+			s.handleError(ErrorEvent{Category: ErrorCategoryPanic, Peer: who, Err: fmt.Errorf("panic: %v", r)})
+		}
+	}()
+
+	if s.isBanned(host) {
+		return
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := s.handshake(tlsConn, who); err != nil {
+			s.updateStats(func(stats *Stats) {
+				stats.Errors++
+			})
+			return
+		}
+	}
+
+	itemFunc := s.ItemFunc
+	if s.PeerSelector != nil {
+		peer := Peer{Addr: conn.RemoteAddr()}
+		if tlsConn, ok := conn.(*tls.Conn); ok {
+			state := tlsConn.ConnectionState()
+			peer.TLS = &state
+		}
+		if selected := s.PeerSelector(peer); selected != nil {
+			itemFunc = selected
+		}
+	}
+
+	idle := false
+	// The connection is no longer idle once a request starts to arrive, so that it cannot be shed while the request is
+	// being read or served, and the rest of the request must arrive by deadline
+	requestStarted := func(deadline time.Time) {
+		conn.SetReadDeadline(deadline)
+		if idle {
+			s.setIdle(conn, false)
+			idle = false
 		}
 	}
+	for {
+		if s.ConnectionIdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ConnectionIdleTimeout))
+		} else {
+			conn.SetReadDeadline(time.Now().Add(s.requestTimeout()))
+		}
+
+		reply, stream, err := s.consumeReader(conn, who, itemFunc, requestStarted)
+		if idle {
+			s.setIdle(conn, false)
+			idle = false
+		}
+		if err != nil {
+			s.updateStats(func(stats *Stats) {
+				stats.Errors++
+			})
+			if reply != nil {
+				// Tell the server why the request was rejected before closing the connection
+				conn.Write(reply)
+			}
+			if s.recordInvalidRequest(host) && s.TarpitDelay > 0 {
+				s.sleep(s.TarpitDelay)
+			}
+			return
+		}
+		if reply == nil && stream == nil {
+			return
+		}
+		var n int64
+		if stream != nil {
+			n, err = stream.WriteTo(conn)
+			stream.Close()
+		} else {
+			n, err = s.writeReply(conn, reply, who)
+		}
+		s.updateStats(func(stats *Stats) {
+			stats.BytesSent += uint64(n)
+		})
+		if err != nil {
+			errorWrite("Error writing reply: %s,%s", fmt.Sprintf("remote_addr='%s'", who), fmt.Sprintf("error='%s'", err.Error()))
+			s.handleError(ErrorEvent{Category: ErrorCategoryConnection, Peer: who, Err: err})
+			return
+		}
 
-	conn.Close()
+		if s.ConnectionIdleTimeout <= 0 {
+			return
+		}
+		if s.ShedIdleConnections {
+			s.setIdle(conn, true)
+			idle = true
+		}
+	}
 }
 
-func consumeReader(itemFunc ItemFunc, r io.Reader) []byte {
-	// Read the first 4 bytes of the header, must be 'ZBXD'
-	headerBuf := make([]byte, 4)
-	if _, err := r.Read(headerBuf); err != nil && err != io.EOF {
-		errorWrite("Error reading request header: %s", fmt.Sprintf("error='%s'", err.Error()))
-		return nil
+// consumeReader reads a single request from r and returns the reply for it, with the value from itemFunc, or the
+// streamed value to send instead if the value is an io.Reader. started is called once the first byte of the request has
+// been read, with the deadline for the rest of the request to be read. Returns an error if the request was malformed,
+// along with a reply describing the problem if one can be sent, or nil for all if the connection was closed or idle
+// before a request was sent.
+func (s *Server) consumeReader(r io.Reader, who string, itemFunc ItemFunc,
+	started func(deadline time.Time)) ([]byte, *streamedValue, error) {
+	first := make([]byte, 1)
+	_, err := io.ReadFull(r, first)
+	var header *zbxproto.Message
+	deadline := time.Now().Add(s.requestTimeout())
+	if err == nil {
+		started(deadline)
+		header, err = zbxproto.ReadHeader(io.MultiReader(bytes.NewReader(first), r))
+	}
+	if err == io.EOF || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, net.ErrClosed) {
+		// Connection closed, or an idle persistent connection, or one that was shed
+		return nil, nil, nil
 	}
-	if !bytes.Equal(headerBuf, []byte("ZBXD")) {
+	if errors.Is(err, zbxproto.ErrBadHeader) {
 		// Don't recognize this header, ignore
-		return nil
+		err := fmt.Errorf("unrecognized header")
+		s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+		return nil, nil, err
 	}
-
-	// Read 1 byte of the flags
-	// Note that this library does not support compression
-	flagsBuf := make([]byte, 1)
-	if _, err := r.Read(flagsBuf); err != nil && err != io.EOF {
-		errorWrite("Error reading request flags: %s", fmt.Sprintf("error='%s'", err.Error()))
-		return nil
+	flagsErr := &zbxproto.FlagsError{}
+	if errors.As(err, &flagsErr) {
+		return s.unsupportedFlags(flagsErr.Flags, who)
 	}
-	if !bytes.Equal(flagsBuf, []byte("\x01")) {
-		errorWrite("Unsupported request flags: %s", fmt.Sprintf("flags='%s'", fmt.Sprintf("%x", flagsBuf)))
-		return nil
+	if err != nil {
+		peerErrorWrite(who, "Error reading request header: %s", fmt.Sprintf("error='%s'", err.Error()))
+		s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+		return nil, nil, err
 	}
-
-	// Read 4 bytes for the content length
-	keyLenBuf := make([]byte, 4)
-	if _, err := r.Read(keyLenBuf); err != nil && err != io.EOF {
-		errorWrite("Error reading request body: %s", fmt.Sprintf("error='%s'", err.Error()))
-		return nil
+	// Note that this library does not support compression, but does support the large packet header, which the reply
+	// mirrors
+	if header.Flags&zbxproto.FlagCompressed != 0 {
+		return s.unsupportedFlags(header.Flags, who)
+	}
+	var replyOptions *zbxproto.Options
+	if header.Flags&zbxproto.FlagLargePacket != 0 {
+		replyOptions = &zbxproto.Options{LargePacket: true}
 	}
-	dataLength := binary.LittleEndian.Uint32(keyLenBuf)
+	dataLength := header.Length
 
 	// Protocol is limited to 128MiB
-	if dataLength >= 134217728 {
-		errorWrite("Rejecting oversides request: %s,%s", fmt.Sprintf("max_size=%d", 134217728), fmt.Sprintf("request_size=%d", dataLength))
-		return nil
+	maxSize := uint64(zbxproto.DefaultMaxSize - 1)
+	if s.MaxRequestSize > 0 && uint64(s.MaxRequestSize) < maxSize {
+		maxSize = uint64(s.MaxRequestSize)
+	}
+	if dataLength > maxSize {
+		peerErrorWrite(who, "Rejecting oversides request: %s,%s", fmt.Sprintf("max_size=%d", maxSize), fmt.Sprintf("request_size=%d", dataLength))
+		err := fmt.Errorf("request too large")
+		s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+		return s.protocolErrorReply(fmt.Sprintf("Request too large: %d bytes, maximum is %d", dataLength, maxSize), replyOptions), nil, err
 	}
+	// Memory is reserved before the request has arrived, so waiting for it is limited by the same deadline
+	if err := s.acquireMemory(int64(dataLength), deadline); err != nil {
+		peerErrorWrite(who, "Rejecting request: %s,%s", fmt.Sprintf("request_size=%d", dataLength), fmt.Sprintf("error='%s'", err.Error()))
+		s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+		return s.protocolErrorReply(fmt.Sprintf("Request of %d bytes rejected: %s", dataLength, err.Error()), replyOptions), nil, err
+	}
+	defer s.releaseMemory(int64(dataLength))
 
-	// Read 4 bytes for the reserved portion of the header, but don't do anything with it
-	reservedBuf := make([]byte, 4)
-	if _, err := r.Read(reservedBuf); err != nil && err != io.EOF {
-		errorWrite("Error reading request header: %s", fmt.Sprintf("error='%s'", err.Error()))
-		return nil
+	// The reserved portion of the header is only used for compressed requests and should be zero
+	if header.Reserved != 0 {
+		if s.StrictReservedBytes {
+			peerErrorWrite(who, "Rejecting request with non-zero reserved bytes: %s", fmt.Sprintf("reserved='%x'", header.Reserved))
+			err := fmt.Errorf("non-zero reserved bytes %x", header.Reserved)
+			s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+			return s.protocolErrorReply(fmt.Sprintf("Non-zero reserved bytes 0x%x in request header", header.Reserved), replyOptions), nil, err
+		}
+		peerErrorWrite(who, "Ignoring non-zero reserved bytes in request: %s", fmt.Sprintf("reserved='%x'", header.Reserved))
 	}
 
-	// Read n bytes for the key (n=data length)
+	// Read n bytes for the key (n=data length), which may arrive in more than one segment
 	keyBuf := make([]byte, dataLength)
-	realLen, err := r.Read(keyBuf)
-	if err != nil && err != io.EOF {
-		errorWrite("Error reading request key: %s", fmt.Sprintf("error='%s'", err.Error()))
-		return nil
+	realLen, err := io.ReadFull(r, keyBuf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		peerErrorWrite(who, "Incorrect request size: %s,%s", fmt.Sprintf("reported=%d", dataLength), fmt.Sprintf("received=%d", realLen))
+		err := fmt.Errorf("incorrect request size")
+		s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+		return s.protocolErrorReply(fmt.Sprintf("Incorrect request size: header reported %d bytes, received %d", dataLength, realLen), replyOptions), nil, err
 	}
-	if uint32(realLen) != dataLength {
-		errorWrite("Incorrect request size: %s,%s", fmt.Sprintf("reported=%d", dataLength), fmt.Sprintf("reported=%d", realLen))
-		return nil
+	if err != nil {
+		peerErrorWrite(who, "Error reading request key: %s", fmt.Sprintf("error='%s'", err.Error()))
+		s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+		return nil, nil, err
 	}
 
 	key := string(keyBuf)
 
-	respObj, err := safeCallItemFunc(itemFunc, key)
+	s.updateStats(func(stats *Stats) {
+		stats.Requests++
+		stats.BytesReceived += uint64(zbxproto.HeaderLength(header.Flags)) + dataLength
+	})
 
-	var data []byte
+	var done func(value interface{}, err error)
+	if s.OnRequest != nil {
+		done = s.OnRequest(who, key)
+	}
+
+	respObj, _, err := s.resultValue(key, itemFunc)
+	var value string
+	var stream *streamedValue
 	if err != nil {
+		closeValue(respObj)
+	} else if reader, ok := respObj.(io.Reader); ok && s.ValueSanitizer == nil {
+		stream, err = s.streamValue(reader, replyOptions)
+	} else if respObj != nil {
+		value, err = s.formatValue(respObj)
+	}
+	if done != nil {
+		done(respObj, err)
+	}
+
+	var data []byte
+	if errors.Is(err, ErrMaintenance) || errors.Is(err, ErrNoValue) {
+		data = []byte("ZBX_NOTSUPPORTED\x00" + err.Error())
+	} else if err != nil {
 		// Error from the agent
+		s.updateStats(func(stats *Stats) {
+			stats.ItemErrors++
+		})
 		errorWrite("Error reading request key: %s,%s", fmt.Sprintf("key='%s'", key), fmt.Sprintf("error='%s'", err.Error()))
-		data = []byte("ZBX_NOTSUPPORTED\x00" + err.Error())
+		s.handleError(ErrorEvent{Category: ErrorCategoryItem, Peer: who, Key: key, Err: err})
+		data = []byte("ZBX_NOTSUPPORTED\x00" + truncateError(err, s.maxErrorLength()))
 	} else if respObj == nil {
 		// No error but no reply, key not found
 		data = []byte("ZBX_NOTSUPPORTED\x00Item key unknown")
+	} else if stream != nil {
+		return nil, stream, nil
 	} else {
 		// Format the reply as a string
-		data = []byte(fmt.Sprintf("%v", respObj))
+		data = []byte(value)
 	}
 
-	length := len(data)
-	header := []byte("ZBXD\x01")
-	lenBuf := make([]byte, 8)
-	binary.LittleEndian.PutUint64(lenBuf, uint64(length))
-	// header + data length is 8 bytes
-	reply := make([]byte, 13+length)
+	reply, err := zbxproto.Encode(data, replyOptions)
+	return reply, nil, err
+}
 
-	i := 0
-	// Add the header
-	for _, b := range header {
-		reply[i] = b
-		i++
+// unsupportedFlags rejects a request with flags that are not supported, returning the reply describing why if the
+// ProtocolErrorReplies option is enabled
+func (s *Server) unsupportedFlags(flags byte, who string) ([]byte, *streamedValue, error) {
+	peerErrorWrite(who, "Unsupported request flags: %s", fmt.Sprintf("flags='%02x'", flags))
+	err := fmt.Errorf("unsupported flags %02x", flags)
+	s.handleError(ErrorEvent{Category: ErrorCategoryProtocol, Peer: who, Err: err})
+	message := fmt.Sprintf("Unsupported request flags 0x%02x", flags)
+	if flags&zbxproto.FlagCompressed != 0 {
+		message += ": compression is not supported"
 	}
-	// Add the data length
-	for _, b := range lenBuf {
-		reply[i] = b
-		i++
+	return s.protocolErrorReply(message, nil), nil, err
+}
+
+// protocolErrorReply returns the reply describing why a request was rejected, encoded with options, if the
+// ProtocolErrorReplies option is enabled, or nil
+func (s *Server) protocolErrorReply(message string, options *zbxproto.Options) []byte {
+	if !s.ProtocolErrorReplies {
+		return nil
 	}
-	// Add the data
-	for _, b := range data {
-		reply[i] = b
-		i++
+	reply, err := zbxproto.Encode([]byte("ZBX_NOTSUPPORTED\x00"+message), options)
+	if err != nil {
+		return nil
 	}
-
 	return reply
 }
 
-func safeCallItemFunc(itemFunc ItemFunc, key string) (interface{}, error) {
+// resultValue returns the value for key as it is replied to the server, after OnResult, and how long itemFunc took to
+// return it
+func (s *Server) resultValue(key string, itemFunc ItemFunc) (interface{}, time.Duration, error) {
+	start := s.clock().Now()
+	value, err := s.itemValue(key, itemFunc)
+	duration := s.clock().Now().Sub(start)
+	if s.OnResult != nil && !errors.Is(err, ErrMaintenance) {
+		value, err = s.OnResult(key, value, err, duration)
+	}
+	return value, duration, err
+}
+
+// itemValue returns the value of key from the internal items or itemFunc
+func (s *Server) itemValue(key string, itemFunc ItemFunc) (interface{}, error) {
+	if err := s.maintenanceError(); err != nil {
+		return nil, err
+	}
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	respObj, ok, err := s.statsItem(key)
+	if ok {
+		return respObj, err
+	}
+	if !s.CoalesceRequests || s.PeerSelector != nil {
+		respObj, err = s.safeCallItemFunc(key, itemFunc)
+	} else {
+		var shared bool
+		respObj, err, shared = s.coalesced.do(key, func() (interface{}, error) {
+			return s.safeCallItemFunc(key, itemFunc)
+		})
+		if shared {
+			s.updateStats(func(stats *Stats) {
+				stats.CoalescedRequests++
+			})
+		}
+	}
+	if err != nil || respObj == nil {
+		return respObj, err
+	}
+	return s.convertValue(key, respObj)
+}
+
+func (s *Server) safeCallItemFunc(key string, itemFunc ItemFunc) (result interface{}, err error) {
 	defer func() {
 		if r := recover(); r != nil {
+			stack := debug.Stack()
 			errorWrite("Recovered from panic calling function for item %s: %s", key, r)
-			ErrorLog.Write(debug.Stack())
+			ErrorLog.Write(stack)
+			if !s.PanicAsError {
+				// Otherwise counted as an error
+				s.updateStats(func(stats *Stats) {
+					stats.ItemErrors++
+				})
+			}
+			if s.OnPanic != nil {
+				s.OnPanic(key, r, stack)
+			}
+			s.handleError(ErrorEvent{Category: ErrorCategoryPanic, Key: key, Err: fmt.Errorf("panic: %v", r)})
+			result = nil
+			err = nil
+			if s.PanicAsError {
+				err = fmt.Errorf("internal error: panic in handler")
+			}
 		}
 	}()
 
-	return itemFunc(key)
+	s.callWithLabels(key, func() {
+		result, err = itemFunc(key)
+	})
+	return result, err
+}
+
+type sampledError struct {
+	logged     time.Time
+	suppressed int
+}
+
+// errorSampler tracks when errors were last reported, so that repeated errors can be suppressed
+type errorSampler struct {
+	lock    sync.Mutex
+	samples map[string]*sampledError
+}
+
+var sampledErrors = &errorSampler{}
+
+// peerErrorWrite writes an error caused by the remote address who, subject to ErrorSampleInterval
+func peerErrorWrite(who string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...) + fmt.Sprintf(",remote_addr='%s'", who)
+
+	interval := ErrorSampleInterval
+	if interval <= 0 {
+		errorWrite("%s", message)
+		return
+	}
+
+	log, suppressed := sampleError(remoteHost(who)+"|"+format, interval, time.Now())
+	if !log {
+		return
+	}
+	if suppressed > 0 {
+		message += fmt.Sprintf(",suppressed=%d", suppressed)
+	}
+	errorWrite("%s", message)
+}
+
+// sampleError returns true if the error identified by sampleKey should be logged at now, and the number of times it
+// was suppressed since it was last logged
+func sampleError(sampleKey string, interval time.Duration, now time.Time) (bool, int) {
+	return sampledErrors.sample(sampleKey, interval, now)
+}
+
+// sample returns true if the error identified by sampleKey should be reported at now, and the number of times it
+// was suppressed since it was last reported
+func (e *errorSampler) sample(sampleKey string, interval time.Duration, now time.Time) (bool, int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.samples == nil {
+		e.samples = map[string]*sampledError{}
+	}
+	sample, ok := e.samples[sampleKey]
+	if ok && now.Sub(sample.logged) < interval {
+		sample.suppressed++
+		return false, 0
+	}
+	suppressed := 0
+	if ok {
+		suppressed = sample.suppressed
+	}
+	e.samples[sampleKey] = &sampledError{logged: now}
+	if len(e.samples) > 1024 {
+		for key, sample := range e.samples {
+			if now.Sub(sample.logged) >= interval {
+				delete(e.samples, key)
+			}
+		}
+	}
+	return true, suppressed
+}
+
+// remoteHost returns the host portion of the remote address who
+func remoteHost(who string) string {
+	host, _, err := net.SplitHostPort(who)
+	if err != nil {
+		return who
+	}
+	return host
 }
 
 func errorWrite(format string, a ...interface{}) {
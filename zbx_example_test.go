@@ -3,6 +3,7 @@ package zbx_test
 import (
 	"crypto/tls"
 	"runtime"
+	"time"
 
 	"github.com/ecnepsnai/zbx"
 )
@@ -64,3 +65,95 @@ func ExampleStartTLS() {
 	// This will block
 	zbx.StartTLS(getItem, "0.0.0.0:10050", cert)
 }
+
+func ExampleBulkItem() {
+	// This function is called at most once every 30 seconds, regardless of how many of its keys are requested
+	getStatus := func() (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"app.connections": 42,
+			"app.uptime":      3600,
+		}, nil
+	}
+
+	// This will block
+	zbx.Start(zbx.BulkItem(getStatus, 30*time.Second, nil), "0.0.0.0:10050")
+}
+
+func ExampleServer() {
+	// This function is called for each incoming request from the Zabbix server
+	getItem := func(itemKey string) (interface{}, error) {
+		if itemKey == "agent.ping" {
+			return "1", nil
+		}
+
+		// Returning nil, nil means the itemKey was unknown
+		return nil, nil
+	}
+
+	server := &zbx.Server{
+		ItemFunc: getItem,
+		// Tell the Zabbix server about panics in getItem instead of reporting the key as unknown
+		PanicAsError: true,
+		// Keep connections open for up to 30 seconds for additional requests
+		ConnectionIdleTimeout: 30 * time.Second,
+	}
+
+	// This will block
+	server.ListenAndServe("0.0.0.0:10050")
+}
+
+func ExampleStartTLSConfig() {
+	// This function is called for each incoming request from the Zabbix server
+	getItem := func(itemKey string) (interface{}, error) {
+		if itemKey == "agent.ping" {
+			return "1", nil
+		}
+
+		// Returning nil, nil means the itemKey was unknown
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair("zabbix.crt", "zabbix.key")
+	if err != nil {
+		panic(err)
+	}
+
+	// Require TLS 1.3 from the Zabbix server
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	// This will block
+	zbx.StartTLSConfig(getItem, "0.0.0.0:10050", config)
+}
+
+func ExampleHostnameFromEnvironment() {
+	// In Kubernetes, expose the pod and node names to the container using the downward API:
+	//
+	//	env:
+	//	- name: POD_NAME
+	//	  valueFrom:
+	//	    fieldRef:
+	//	      fieldPath: metadata.name
+	//	- name: NODE_NAME
+	//	  valueFrom:
+	//	    fieldRef:
+	//	      fieldPath: spec.nodeName
+	hostname, err := zbx.HostnameFromEnvironment("${NODE_NAME}-${POD_NAME}")
+	if err != nil {
+		panic(err)
+	}
+
+	getItem := func(itemKey string) (interface{}, error) {
+		if itemKey == "agent.hostname" {
+			return hostname, nil
+		}
+
+		// Returning nil, nil means the itemKey was unknown
+		return nil, nil
+	}
+
+	// This will block
+	zbx.Start(getItem, "0.0.0.0:10050")
+}
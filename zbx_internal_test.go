@@ -0,0 +1,29 @@
+package zbx
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure that repeated protocol errors from the same host are only logged once per sample interval
+func TestErrorSampling(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	// Samples are global, so keep keys unique to this run of the test
+	prefix := now.String()
+	check := func(sampleKey string, at time.Time, expectLog bool, expectSuppressed int) {
+		log, suppressed := sampleError(prefix+sampleKey, time.Minute, at)
+		if log != expectLog || suppressed != expectSuppressed {
+			t.Errorf("Unexpected sample result for '%s'. Expected %v,%d got %v,%d", sampleKey, expectLog, expectSuppressed, log, suppressed)
+		}
+	}
+
+	check("192.0.2.1|Unsupported request flags: %s", now, true, 0)
+	check("192.0.2.1|Unsupported request flags: %s", now.Add(time.Second), false, 0)
+	check("192.0.2.1|Unsupported request flags: %s", now.Add(2*time.Second), false, 0)
+	check("192.0.2.2|Unsupported request flags: %s", now.Add(2*time.Second), true, 0)
+	check("192.0.2.1|Incorrect request size: %s", now.Add(2*time.Second), true, 0)
+	check("192.0.2.1|Unsupported request flags: %s", now.Add(2*time.Minute), true, 2)
+	check("192.0.2.1|Unsupported request flags: %s", now.Add(3*time.Minute), true, 0)
+}
@@ -3,6 +3,7 @@ package zbx_test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/ecnepsnai/zbx"
+	"github.com/ecnepsnai/zbx/zbxproto"
 )
 
 const socketAddr = "127.0.0.1:8765"
@@ -40,19 +42,24 @@ func TestMain(m *testing.M) {
 			panic("Ah!")
 		},
 	}
-	go zbx.Start(func(key string) (interface{}, error) {
-		f, ok := items[key]
-		if !ok {
-			return nil, nil
-		}
-		return f()
-	}, socketAddr)
-
-	c, err := retryDial(socketAddr)
-	if err != nil {
-		panic("unable to connect to socket")
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			f, ok := items[key]
+			if !ok {
+				return nil, nil
+			}
+			return f()
+		},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe(socketAddr)
+	}()
+	select {
+	case <-server.Ready():
+	case err := <-errs:
+		panic("unable to start agent: " + err.Error())
 	}
-	c.Close()
 
 	os.Exit(m.Run())
 }
@@ -70,27 +77,69 @@ func retryDial(addr string) (net.Conn, error) {
 	return nil, fmt.Errorf("cant connect after 5 attempts")
 }
 
-func requestForKey(key string) []byte {
-	length := make([]byte, 8)
-	binary.LittleEndian.PutUint64(length, uint64(len(key)))
-	request := make([]byte, 13+len(key))
-	header := []byte("ZBXD\x01")
-	i := 0
-	for _, b := range header {
-		request[i] = b
-		i++
+// startTestServer starts the given server on a random local port, returning its address. The server is stopped
+// when the test finishes.
+func startTestServer(t *testing.T, server *zbx.Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
 	}
-	for _, b := range length {
-		request[i] = b
-		i++
+	go server.Serve(l)
+	t.Cleanup(func() {
+		l.Close()
+	})
+	return l.Addr().String()
+}
+
+// queryKey requests key from the agent at addr and returns the data of the reply
+func queryKey(t *testing.T, addr string, key string) string {
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
 	}
-	for _, b := range []byte(key) {
-		request[i] = b
-		i++
+	defer c.Close()
+	if _, err := c.Write(requestForKey(key)); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	reply, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	if len(reply) < 13 {
+		t.Fatalf("Reply too short: %x", reply)
+	}
+	return string(reply[13:])
+}
+
+func requestForKey(key string) []byte {
+	request, err := zbxproto.Encode([]byte(key), nil)
+	if err != nil {
+		panic(err)
 	}
 	return request
 }
 
+// fixture returns the raw message of the zbxproto fixture with the given name
+func fixture(t *testing.T, name string) []byte {
+	for _, fixture := range zbxproto.Fixtures() {
+		if fixture.Name == name {
+			return fixture.Raw
+		}
+	}
+	t.Fatalf("No fixture named '%s'", name)
+	return nil
+}
+
+// notSupportedReply returns the reply for an item that is not supported with the given message, which the fixtures
+// verify the encoding of
+func notSupportedReply(message string) []byte {
+	reply, err := zbxproto.Encode([]byte("ZBX_NOTSUPPORTED\x00"+message), nil)
+	if err != nil {
+		panic(err)
+	}
+	return reply
+}
+
 func TestItemFuncNil(t *testing.T) {
 	t.Parallel()
 
@@ -118,12 +167,55 @@ func TestAgentPing(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error reading reply: %s", err.Error())
 	}
-	expectedResponse := []byte("\x5A\x42\x58\x44\x01\x01\x00\x00\x00\x00\x00\x00\x00\x31")
+	expectedResponse := fixture(t, "reply 1")
 	if !bytes.Equal(reply, expectedResponse) {
 		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
 	}
 }
 
+// Ensure that the reply to a request with the large packet header also uses it
+func TestAgentPingLargePacket(t *testing.T) {
+	t.Parallel()
+
+	c, err := retryDial(socketAddr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	if _, err := c.Write(fixture(t, "request agent.ping large packet")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	reply, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	expectedResponse := fixture(t, "reply 1 large packet")
+	if !bytes.Equal(reply, expectedResponse) {
+		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+	}
+}
+
+// Ensure that the size of a request with the large packet header is still limited to 128MiB
+func TestOversizedRequestLargePacket(t *testing.T) {
+	t.Parallel()
+
+	request := []byte("ZBXD\x05")
+	length := make([]byte, 16)
+	binary.LittleEndian.PutUint64(length, uint64(1)<<40)
+	request = append(request, length...)
+	request = append(request, []byte("agent.ping")...)
+
+	c, err := retryDial(socketAddr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	if _, err := c.Write(request); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	if reply, _ := io.ReadAll(c); len(reply) > 0 {
+		t.Fatalf("Unexpected reply: %x", reply)
+	}
+}
+
 func TestAgentError(t *testing.T) {
 	t.Parallel()
 
@@ -138,7 +230,7 @@ func TestAgentError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error reading reply: %s", err.Error())
 	}
-	expectedResponse := []byte("\x5A\x42\x58\x44\x01\x21\x00\x00\x00\x00\x00\x00\x00\x5A\x42\x58\x5F\x4E\x4F\x54\x53\x55\x50\x50\x4F\x52\x54\x45\x44\x00\x74\x68\x69\x73\x20\x69\x73\x20\x61\x6E\x20\x65\x72\x72\x6F\x72")
+	expectedResponse := notSupportedReply("this is an error")
 	if !bytes.Equal(reply, expectedResponse) {
 		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
 	}
@@ -158,7 +250,7 @@ func TestUnknownKey(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error reading reply: %s", err.Error())
 	}
-	expectedResponse := []byte("\x5A\x42\x58\x44\x01\x21\x00\x00\x00\x00\x00\x00\x00\x5A\x42\x58\x5F\x4E\x4F\x54\x53\x55\x50\x50\x4F\x52\x54\x45\x44\x00\x49\x74\x65\x6D\x20\x6B\x65\x79\x20\x75\x6E\x6B\x6E\x6F\x77\x6E")
+	expectedResponse := fixture(t, "reply item key unknown")
 	if !bytes.Equal(reply, expectedResponse) {
 		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
 	}
@@ -178,7 +270,7 @@ func TestKeyPanic(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error reading reply: %s", err.Error())
 	}
-	expectedResponse := []byte("\x5A\x42\x58\x44\x01\x21\x00\x00\x00\x00\x00\x00\x00\x5A\x42\x58\x5F\x4E\x4F\x54\x53\x55\x50\x50\x4F\x52\x54\x45\x44\x00\x49\x74\x65\x6D\x20\x6B\x65\x79\x20\x75\x6E\x6B\x6E\x6F\x77\x6E")
+	expectedResponse := fixture(t, "reply item key unknown")
 	if !bytes.Equal(reply, expectedResponse) {
 		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
 	}
@@ -280,6 +372,8 @@ func TestFalseDataLength(t *testing.T) {
 	if _, err := c.Write(request); err != nil {
 		t.Fatalf("Error writing request: %s", err.Error())
 	}
+	// The agent waits for the rest of the request until the connection is closed
+	c.(*net.TCPConn).CloseWrite()
 	reply, err := io.ReadAll(c)
 	if err != nil {
 		t.Fatalf("Error reading reply: %s", err.Error())
@@ -288,3 +382,227 @@ func TestFalseDataLength(t *testing.T) {
 		t.Fatalf("Unexpected reply when none expected")
 	}
 }
+
+// Ensure that a request that arrives in more than one segment is read in full
+func TestSplitRequest(t *testing.T) {
+	t.Parallel()
+
+	c, err := retryDial(socketAddr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+	request := requestForKey("agent.ping")
+	for _, segment := range [][]byte{request[0:2], request[2:7], request[7:15], request[15:]} {
+		if _, err := c.Write(segment); err != nil {
+			t.Fatalf("Error writing request: %s", err.Error())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	reply, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	expectedResponse := fixture(t, "reply 1")
+	if !bytes.Equal(reply, expectedResponse) {
+		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+	}
+}
+
+// Ensure that a panic in the item func is reported as an error when PanicAsError is enabled
+func TestKeyPanicAsError(t *testing.T) {
+	t.Parallel()
+
+	panicKey := make(chan string, 1)
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			panic("Ah!")
+		},
+		PanicAsError: true,
+		OnPanic: func(key string, recovered interface{}, stack []byte) {
+			panicKey <- key
+		},
+	})
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	if _, err := c.Write(requestForKey("panic")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	reply, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	expectedResponse := notSupportedReply("internal error: panic in handler")
+	if !bytes.Equal(reply, expectedResponse) {
+		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+	}
+	if key := <-panicKey; key != "panic" {
+		t.Errorf("OnPanic not called with expected key. Expected '%s' got '%s'", "panic", key)
+	}
+}
+
+// Ensure that StartListener returns once its listener is closed
+func TestListenerClosed(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error opening listener: %s", err.Error())
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- zbx.StartListener(func(key string) (interface{}, error) {
+			return nil, nil
+		}, l)
+	}()
+
+	l.Close()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Errorf("No error returned when one expected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("StartListener did not return after listener was closed")
+	}
+}
+
+// Ensure that multiple requests can be sent on one connection when persistent connections are enabled
+func TestPersistentConnection(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		ConnectionIdleTimeout: time.Second,
+	})
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	defer c.Close()
+
+	expectedResponse := fixture(t, "reply 1")
+	for i := 0; i < 3; i++ {
+		if _, err := c.Write(requestForKey("agent.ping")); err != nil {
+			t.Fatalf("Error writing request: %s", err.Error())
+		}
+		reply := make([]byte, len(expectedResponse))
+		if _, err := io.ReadFull(c, reply); err != nil {
+			t.Fatalf("Error reading reply: %s", err.Error())
+		}
+		if !bytes.Equal(reply, expectedResponse) {
+			t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+		}
+	}
+}
+
+// Ensure that the agent still replies when TCP options are set
+func TestTCPOptions(t *testing.T) {
+	t.Parallel()
+
+	addr := startTestServer(t, &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		TCPKeepAlive:      15 * time.Second,
+		DisableTCPNoDelay: true,
+		TCPLinger:         time.Second,
+	})
+
+	c, err := retryDial(addr)
+	if err != nil {
+		t.Fatalf("Error connecting to zabbix agent: %s", err.Error())
+	}
+	if _, err := c.Write(requestForKey("agent.ping")); err != nil {
+		t.Fatalf("Error writing request: %s", err.Error())
+	}
+	reply, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("Error reading reply: %s", err.Error())
+	}
+	expectedResponse := fixture(t, "reply 1")
+	if !bytes.Equal(reply, expectedResponse) {
+		t.Errorf("Unexpected reply from server. Expected:\n%x\nGot:\n%x", expectedResponse, reply)
+	}
+}
+
+// Ensure that the address of a server listening on port 0 can be learned
+func TestServerAddr(t *testing.T) {
+	t.Parallel()
+
+	listening := make(chan net.Addr, 1)
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+		OnListen: func(addr net.Addr) {
+			listening <- addr
+		},
+	}
+	if server.Addr() != nil {
+		t.Errorf("Unexpected address before listening: %s", server.Addr())
+	}
+	go server.ListenAndServe("127.0.0.1:0")
+	defer server.Close()
+
+	addr := <-listening
+	if addr.String() == "127.0.0.1:0" {
+		t.Errorf("Port not assigned in address: %s", addr)
+	}
+	if server.Addr().String() != addr.String() {
+		t.Errorf("Unexpected address. Expected '%s' got '%s'", addr, server.Addr())
+	}
+	if value := queryKey(t, addr.String(), "agent.ping"); value != "1" {
+		t.Errorf("Unexpected reply: '%s'", value)
+	}
+}
+
+// Ensure that the ready channel is closed once the server is listening and that Close stops the server
+func TestServerReadyClose(t *testing.T) {
+	t.Parallel()
+
+	server := &zbx.Server{
+		ItemFunc: func(key string) (interface{}, error) {
+			return 1, nil
+		},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe("127.0.0.1:0")
+	}()
+
+	select {
+	case <-server.Ready():
+	case err := <-errs:
+		t.Fatalf("Error starting server: %s", err.Error())
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Server not ready after 5 seconds")
+	}
+
+	addr := server.Addr().String()
+	if value := queryKey(t, addr, "agent.ping"); value != "1" {
+		t.Errorf("Unexpected reply: '%s'", value)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatalf("Error closing server: %s", err.Error())
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Unexpected error from closed server: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Server did not stop after being closed")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Errorf("No error connecting to closed server")
+	}
+}
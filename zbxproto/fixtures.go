@@ -0,0 +1,115 @@
+package zbxproto
+
+import (
+	"io"
+)
+
+// Fixture is a message as it is sent on the wire, along with how it decodes, for testing implementations of the
+// protocol against the same messages
+type Fixture struct {
+	// Name uniquely describes the message, such as "reply 1 large packet"
+	Name string
+	// Raw is the complete message, including the header
+	Raw []byte
+	// Flags, Length and Reserved are the fields of the header of the message
+	Flags    byte
+	Length   uint64
+	Reserved uint64
+	// Data is the data of the message, decompressed if needed
+	Data []byte
+	// Err is the error that ReadMessage returns for the message with the default options, or nil if it is valid
+	Err error
+}
+
+// Fixtures returns golden messages covering each combination of flags and length fields, compressed messages as
+// produced by zlib, and messages that are not valid. Each call returns a new copy, which can be modified.
+func Fixtures() []Fixture {
+	return []Fixture{
+		{
+			Name:  "request agent.ping",
+			Raw:   []byte("ZBXD\x01\x0a\x00\x00\x00\x00\x00\x00\x00agent.ping"),
+			Flags: FlagProtocol, Length: 10, Data: []byte("agent.ping"),
+		},
+		{
+			Name:  "request agent.ping large packet",
+			Raw:   []byte("ZBXD\x05\x0a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00agent.ping"),
+			Flags: FlagProtocol | FlagLargePacket, Length: 10, Data: []byte("agent.ping"),
+		},
+		{
+			Name:  "request agent.ping compressed",
+			Raw:   []byte("ZBXD\x03\x12\x00\x00\x00\x0a\x00\x00\x00\x78\x9c\x4b\x4c\x4f\xcd\x2b\xd1\x2b\xc8\xcc\x4b\x07\x00\x15\x79\x03\xec"),
+			Flags: FlagProtocol | FlagCompressed, Length: 18, Reserved: 10, Data: []byte("agent.ping"),
+		},
+		{
+			Name:  "request agent.ping compressed large packet",
+			Raw:   []byte("ZBXD\x07\x12\x00\x00\x00\x00\x00\x00\x00\x0a\x00\x00\x00\x00\x00\x00\x00\x78\x9c\x4b\x4c\x4f\xcd\x2b\xd1\x2b\xc8\xcc\x4b\x07\x00\x15\x79\x03\xec"),
+			Flags: FlagProtocol | FlagCompressed | FlagLargePacket, Length: 18, Reserved: 10, Data: []byte("agent.ping"),
+		},
+		{
+			Name:  "request with non-zero reserved field",
+			Raw:   []byte("ZBXD\x01\x0a\x00\x00\x00\x07\x00\x00\x00agent.ping"),
+			Flags: FlagProtocol, Length: 10, Reserved: 7, Data: []byte("agent.ping"),
+		},
+		{
+			Name:  "reply 1",
+			Raw:   []byte("ZBXD\x01\x01\x00\x00\x00\x00\x00\x00\x001"),
+			Flags: FlagProtocol, Length: 1, Data: []byte("1"),
+		},
+		{
+			Name:  "reply 1 large packet",
+			Raw:   []byte("ZBXD\x05\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x001"),
+			Flags: FlagProtocol | FlagLargePacket, Length: 1, Data: []byte("1"),
+		},
+		{
+			Name:  "reply empty",
+			Raw:   []byte("ZBXD\x01\x00\x00\x00\x00\x00\x00\x00\x00"),
+			Flags: FlagProtocol, Data: []byte{},
+		},
+		{
+			Name:  "reply item key unknown",
+			Raw:   []byte("ZBXD\x01\x21\x00\x00\x00\x00\x00\x00\x00ZBX_NOTSUPPORTED\x00Item key unknown"),
+			Flags: FlagProtocol, Length: 33, Data: []byte("ZBX_NOTSUPPORTED\x00Item key unknown"),
+		},
+		{
+			Name: "bad magic",
+			Raw:  []byte("GET / HTTP/1.1\r\n\r\n"),
+			Err:  ErrBadHeader,
+		},
+		{
+			Name:  "missing protocol flag",
+			Raw:   []byte("ZBXD\x00\x0a\x00\x00\x00\x00\x00\x00\x00agent.ping"),
+			Flags: 0x00,
+			Err:   ErrUnsupportedFlags,
+		},
+		{
+			Name:  "unknown flag",
+			Raw:   []byte("ZBXD\x09\x0a\x00\x00\x00\x00\x00\x00\x00agent.ping"),
+			Flags: 0x09,
+			Err:   ErrUnsupportedFlags,
+		},
+		{
+			Name:  "truncated header",
+			Raw:   []byte("ZBXD\x01\x0a\x00"),
+			Flags: FlagProtocol,
+			Err:   io.ErrUnexpectedEOF,
+		},
+		{
+			Name:  "truncated data",
+			Raw:   []byte("ZBXD\x01\x0a\x00\x00\x00\x00\x00\x00\x00agent"),
+			Flags: FlagProtocol, Length: 10,
+			Err: io.ErrUnexpectedEOF,
+		},
+		{
+			Name:  "too large",
+			Raw:   []byte("ZBXD\x01\x01\x00\x00\x08\x00\x00\x00\x00"),
+			Flags: FlagProtocol, Length: DefaultMaxSize + 1,
+			Err: ErrTooLarge,
+		},
+		{
+			Name:  "compressed length mismatch",
+			Raw:   []byte("ZBXD\x03\x12\x00\x00\x00\x05\x00\x00\x00\x78\x9c\x4b\x4c\x4f\xcd\x2b\xd1\x2b\xc8\xcc\x4b\x07\x00\x15\x79\x03\xec"),
+			Flags: FlagProtocol | FlagCompressed, Length: 18, Reserved: 5,
+			Err: ErrLengthMismatch,
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package zbxproto
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info is the result of a request that sent values to a Zabbix server or proxy, as reported in the info string of
+// its response
+type Info struct {
+	// Processed is the number of values that were accepted
+	Processed int
+	// Failed is the number of values that were rejected, such as for unknown items or values of the wrong type
+	Failed int
+	// Total is the number of values that were received
+	Total int
+	// SecondsSpent is how long the server spent processing the values, or 0 if the server did not report it
+	SecondsSpent float64
+}
+
+// Duration returns SecondsSpent as a duration
+func (i Info) Duration() time.Duration {
+	return time.Duration(i.SecondsSpent * float64(time.Second))
+}
+
+var (
+	infoProcessedPattern = regexp.MustCompile(`processed:?\s*(\d+)`)
+	infoFailedPattern    = regexp.MustCompile(`failed:?\s*(\d+)`)
+	infoTotalPattern     = regexp.MustCompile(`total:?\s*(\d+)`)
+	infoSecondsPattern   = regexp.MustCompile(`seconds spent:?\s*(\d+(?:[.,]\d+)?)`)
+)
+
+// ParseInfoString parses the info string from the response of a Zabbix server or proxy to a request that sent
+// values, such as "processed: 3; failed: 1; total: 4; seconds spent: 0.000213". The older format used by Zabbix 1.8
+// and earlier ("Processed 3 Failed 1 Total 4 Seconds spent 0.000213"), and a comma as the decimal separator, are also
+// accepted. Returns an error if the processed, failed or total counts are missing.
+func ParseInfoString(info string) (*Info, error) {
+	lower := strings.ToLower(info)
+	result := &Info{}
+
+	counts := []struct {
+		name    string
+		pattern *regexp.Regexp
+		value   *int
+	}{
+		{"processed", infoProcessedPattern, &result.Processed},
+		{"failed", infoFailedPattern, &result.Failed},
+		{"total", infoTotalPattern, &result.Total},
+	}
+	for _, count := range counts {
+		match := count.pattern.FindStringSubmatch(lower)
+		if match == nil {
+			return nil, fmt.Errorf("invalid info string '%s': missing %s count", info, count.name)
+		}
+		value, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid info string '%s': invalid %s count", info, count.name)
+		}
+		*count.value = value
+	}
+
+	if match := infoSecondsPattern.FindStringSubmatch(lower); match != nil {
+		seconds, err := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid info string '%s': invalid seconds spent", info)
+		}
+		result.SecondsSpent = seconds
+	}
+
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package zbxproto_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+func TestParseInfoString(t *testing.T) {
+	t.Parallel()
+
+	expected := map[string]zbxproto.Info{
+		// Zabbix 2.0 and newer
+		"processed: 3; failed: 1; total: 4; seconds spent: 0.000213": {Processed: 3, Failed: 1, Total: 4, SecondsSpent: 0.000213},
+		// Zabbix 1.8 and earlier
+		"Processed 3 Failed 1 Total 4 Seconds spent 0.000213": {Processed: 3, Failed: 1, Total: 4, SecondsSpent: 0.000213},
+		// Comma decimal separator
+		"processed: 10; failed: 0; total: 10; seconds spent: 0,001500": {Processed: 10, Failed: 0, Total: 10, SecondsSpent: 0.0015},
+		// Without the time spent
+		"processed: 1; failed: 0; total: 1": {Processed: 1, Total: 1},
+		// Extra whitespace
+		"  processed:2;failed:0;total:2;seconds spent:1  ": {Processed: 2, Total: 2, SecondsSpent: 1},
+	}
+	for info, expectedInfo := range expected {
+		result, err := zbxproto.ParseInfoString(info)
+		if err != nil {
+			t.Errorf("Unexpected error parsing '%s': %s", info, err.Error())
+			continue
+		}
+		if *result != expectedInfo {
+			t.Errorf("Unexpected result for '%s'. Expected %+v got %+v", info, expectedInfo, *result)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"processed: 3; failed: 1",
+		"Processed: x; Failed: 1; Total: 4",
+	}
+	for _, info := range invalid {
+		if _, err := zbxproto.ParseInfoString(info); err == nil {
+			t.Errorf("No error seen for invalid info string '%s'", info)
+		}
+	}
+}
+
+func TestInfoDuration(t *testing.T) {
+	t.Parallel()
+
+	info := zbxproto.Info{SecondsSpent: 0.25}
+	if info.Duration() != 250*time.Millisecond {
+		t.Errorf("Unexpected duration %s", info.Duration())
+	}
+}
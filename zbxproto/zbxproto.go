@@ -0,0 +1,252 @@
+/*
+Package zbxproto implements the framing used by the Zabbix protocol, for use by tools that need to read or write
+messages exchanged between Zabbix agents, proxies and servers.
+
+Each message starts with a header made up of the "ZBXD" magic, one byte of flags, the length of the data and a
+reserved field, followed by the data itself. The length and reserved fields are 4 bytes each, or 8 bytes each when the
+large packet flag is set. When the data is compressed the reserved field holds the length of the uncompressed data.
+*/
+package zbxproto
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// FlagProtocol is set on every Zabbix protocol message
+	FlagProtocol byte = 0x01
+	// FlagCompressed is set when the data of the message is compressed with zlib
+	FlagCompressed byte = 0x02
+	// FlagLargePacket is set when the length fields of the header are 8 bytes instead of 4
+	FlagLargePacket byte = 0x04
+)
+
+// DefaultMaxSize is the largest message data accepted by ReadMessage unless otherwise specified, 128MiB
+const DefaultMaxSize = 134217728
+
+// Magic is the value that every Zabbix protocol message starts with
+var Magic = []byte("ZBXD")
+
+var (
+	// ErrBadHeader is returned when a message does not start with the Zabbix protocol header
+	ErrBadHeader = errors.New("bad header")
+	// ErrUnsupportedFlags is returned when a message has flags that are not known
+	ErrUnsupportedFlags = errors.New("unsupported flags")
+	// ErrTooLarge is returned when the data of a message is larger than the maximum size
+	ErrTooLarge = errors.New("message too large")
+	// ErrLengthMismatch is returned when the decompressed data of a message does not match its reported length
+	ErrLengthMismatch = errors.New("decompressed length does not match header")
+	// ErrReservedNotZero is returned when the reserved field of an uncompressed message is not zero, when
+	// Options.StrictReserved is set
+	ErrReservedNotZero = errors.New("reserved field is not zero")
+)
+
+// Options describes options for reading and writing messages. A nil *Options uses the defaults.
+type Options struct {
+	// MaxSize is the largest message data that will be read, before and after decompression. Defaults to
+	// DefaultMaxSize.
+	MaxSize uint64
+	// Compress controls if written messages have their data compressed.
+	Compress bool
+	// LargePacket controls if written messages use the large packet header.
+	LargePacket bool
+	// StrictReserved controls if read messages that are not compressed must have a zero reserved field. By default
+	// the field is ignored, as it is by Zabbix, since some proxies and tools do not zero it.
+	StrictReserved bool
+}
+
+// Message describes a single Zabbix protocol message
+type Message struct {
+	// Flags are the flags from the header of the message
+	Flags byte
+	// Length is the length of the data as reported in the header, which is the compressed length for compressed
+	// messages
+	Length uint64
+	// Reserved is the reserved field from the header, which is the uncompressed length for compressed messages
+	Reserved uint64
+	// Data is the data of the message, decompressed if needed
+	Data []byte
+}
+
+func (o *Options) maxSize() uint64 {
+	if o == nil || o.MaxSize == 0 {
+		return DefaultMaxSize
+	}
+	return o.MaxSize
+}
+
+// Encode returns data framed as a Zabbix protocol message
+func Encode(data []byte, options *Options) ([]byte, error) {
+	flags := FlagProtocol
+	payload := data
+	if options != nil && options.Compress {
+		flags |= FlagCompressed
+		buf := &bytes.Buffer{}
+		writer := zlib.NewWriter(buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+	}
+
+	var reserved uint64
+	if flags&FlagCompressed != 0 {
+		reserved = uint64(len(data))
+	}
+
+	header, err := encodeHeader(flags, uint64(len(payload)), reserved, options != nil && options.LargePacket)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, payload...), nil
+}
+
+// EncodeHeader returns the header of a message with data of the given length, for when the data is written
+// separately, such as when it is copied from a file. Compression is not possible, as the length of the compressed
+// data is not known.
+func EncodeHeader(length uint64, options *Options) ([]byte, error) {
+	if options != nil && options.Compress {
+		return nil, errors.New("compression is not supported when encoding a header")
+	}
+	return encodeHeader(FlagProtocol, length, 0, options != nil && options.LargePacket)
+}
+
+func encodeHeader(flags byte, length uint64, reserved uint64, largePacket bool) ([]byte, error) {
+	header := &bytes.Buffer{}
+	header.Write(Magic)
+	if largePacket {
+		header.WriteByte(flags | FlagLargePacket)
+		binary.Write(header, binary.LittleEndian, length)
+		binary.Write(header, binary.LittleEndian, reserved)
+	} else {
+		if length > 0xFFFFFFFF || reserved > 0xFFFFFFFF {
+			return nil, ErrTooLarge
+		}
+		header.WriteByte(flags)
+		binary.Write(header, binary.LittleEndian, uint32(length))
+		binary.Write(header, binary.LittleEndian, uint32(reserved))
+	}
+	return header.Bytes(), nil
+}
+
+// WriteMessage writes data framed as a Zabbix protocol message to w
+func WriteMessage(w io.Writer, data []byte, options *Options) error {
+	message, err := Encode(data, options)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(message)
+	return err
+}
+
+// FlagsError is returned when a message has flags that are not known. It matches ErrUnsupportedFlags with errors.Is.
+type FlagsError struct {
+	// Flags are the flags from the header of the message
+	Flags byte
+}
+
+func (e *FlagsError) Error() string {
+	return fmt.Sprintf("%s: %02x", ErrUnsupportedFlags.Error(), e.Flags)
+}
+
+// Is reports if target is ErrUnsupportedFlags
+func (e *FlagsError) Is(target error) bool {
+	return target == ErrUnsupportedFlags
+}
+
+// HeaderLength returns the length of the header of a message with the given flags
+func HeaderLength(flags byte) int {
+	if flags&FlagLargePacket != 0 {
+		return 21
+	}
+	return 13
+}
+
+// ReadHeader reads the header of a single Zabbix protocol message from r, leaving its data to be read, such as to check
+// the length of the data before reading it. The returned message does not have any Data. Unlike ReadMessage, no
+// limits are applied to the length.
+func ReadHeader(r io.Reader) (*Message, error) {
+	magic := make([]byte, 5)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic[0:4], Magic) {
+		return nil, ErrBadHeader
+	}
+
+	flags := magic[4]
+	if flags&FlagProtocol == 0 || flags&^(FlagProtocol|FlagCompressed|FlagLargePacket) != 0 {
+		return nil, &FlagsError{Flags: flags}
+	}
+
+	message := &Message{Flags: flags}
+	lengths := make([]byte, HeaderLength(flags)-5)
+	if _, err := io.ReadFull(r, lengths); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if flags&FlagLargePacket != 0 {
+		message.Length = binary.LittleEndian.Uint64(lengths[0:8])
+		message.Reserved = binary.LittleEndian.Uint64(lengths[8:16])
+	} else {
+		message.Length = uint64(binary.LittleEndian.Uint32(lengths[0:4]))
+		message.Reserved = uint64(binary.LittleEndian.Uint32(lengths[4:8]))
+	}
+	return message, nil
+}
+
+// ReadMessage reads a single Zabbix protocol message from r. Compressed messages are decompressed.
+func ReadMessage(r io.Reader, options *Options) (*Message, error) {
+	message, err := ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	flags := message.Flags
+
+	if options != nil && options.StrictReserved && flags&FlagCompressed == 0 && message.Reserved != 0 {
+		return nil, fmt.Errorf("%w: %d", ErrReservedNotZero, message.Reserved)
+	}
+
+	maxSize := options.maxSize()
+	if message.Length > maxSize {
+		return nil, ErrTooLarge
+	}
+
+	data := make([]byte, message.Length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if flags&FlagCompressed == 0 {
+		message.Data = data
+		return message, nil
+	}
+
+	if message.Reserved > maxSize {
+		return nil, ErrTooLarge
+	}
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(message.Reserved)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(decompressed)) != message.Reserved {
+		return nil, ErrLengthMismatch
+	}
+	message.Data = decompressed
+	return message, nil
+}
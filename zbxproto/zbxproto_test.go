@@ -0,0 +1,213 @@
+package zbxproto_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ecnepsnai/zbx/zbxproto"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	message, err := zbxproto.Encode([]byte("agent.ping"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := []byte("\x5A\x42\x58\x44\x01\x0A\x00\x00\x00\x00\x00\x00\x00\x61\x67\x65\x6E\x74\x2E\x70\x69\x6E\x67")
+	if !bytes.Equal(message, expected) {
+		t.Errorf("Unexpected message. Expected:\n%x\nGot:\n%x", expected, message)
+	}
+
+	message, err = zbxproto.Encode([]byte("agent.ping"), &zbxproto.Options{LargePacket: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected = []byte("\x5A\x42\x58\x44\x05\x0A\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x61\x67\x65\x6E\x74\x2E\x70\x69\x6E\x67")
+	if !bytes.Equal(message, expected) {
+		t.Errorf("Unexpected message. Expected:\n%x\nGot:\n%x", expected, message)
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	t.Parallel()
+
+	for _, options := range []*zbxproto.Options{nil, {LargePacket: true}} {
+		header, err := zbxproto.EncodeHeader(10, options)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		message, err := zbxproto.Encode([]byte("agent.ping"), options)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if !bytes.Equal(append(header, []byte("agent.ping")...), message) {
+			t.Errorf("Unexpected header with options %+v: %x", options, header)
+		}
+	}
+
+	if _, err := zbxproto.EncodeHeader(1<<32, nil); !errors.Is(err, zbxproto.ErrTooLarge) {
+		t.Errorf("Unexpected error for oversized header: %v", err)
+	}
+	if _, err := zbxproto.EncodeHeader(10, &zbxproto.Options{Compress: true}); err == nil {
+		t.Errorf("No error seen for compressed header")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(strings.Repeat("zabbix ", 100))
+	for _, options := range []*zbxproto.Options{
+		nil,
+		{Compress: true},
+		{LargePacket: true},
+		{Compress: true, LargePacket: true},
+	} {
+		buf := &bytes.Buffer{}
+		if err := zbxproto.WriteMessage(buf, data, options); err != nil {
+			t.Fatalf("Error writing message with options %+v: %s", options, err.Error())
+		}
+		message, err := zbxproto.ReadMessage(buf, nil)
+		if err != nil {
+			t.Fatalf("Error reading message with options %+v: %s", options, err.Error())
+		}
+		if !bytes.Equal(message.Data, data) {
+			t.Errorf("Unexpected data with options %+v: %s", options, message.Data)
+		}
+
+		compressed := options != nil && options.Compress
+		if compressed != (message.Flags&zbxproto.FlagCompressed != 0) {
+			t.Errorf("Unexpected flags with options %+v: %02x", options, message.Flags)
+		}
+		if compressed && (message.Length >= uint64(len(data)) || message.Reserved != uint64(len(data))) {
+			t.Errorf("Unexpected lengths for compressed message: %d, %d", message.Length, message.Reserved)
+		}
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	t.Parallel()
+
+	check := func(input string, options *zbxproto.Options, expected error) {
+		_, err := zbxproto.ReadMessage(strings.NewReader(input), options)
+		if !errors.Is(err, expected) {
+			t.Errorf("Unexpected error for input %x. Expected '%v' got '%v'", input, expected, err)
+		}
+	}
+
+	check("Hack the planet!", nil, zbxproto.ErrBadHeader)
+	check("ZBXD\x00\x00\x00\x00\x00\x00\x00\x00\x00", nil, zbxproto.ErrUnsupportedFlags)
+	check("ZBXD\x09\x00\x00\x00\x00\x00\x00\x00\x00", nil, zbxproto.ErrUnsupportedFlags)
+	check("ZBXD\x01\x01\x00\x00\x08\x00\x00\x00\x00", nil, zbxproto.ErrTooLarge)
+	check("ZBXD\x01\x05\x00\x00\x00\x00\x00\x00\x00abcde", &zbxproto.Options{MaxSize: 4}, zbxproto.ErrTooLarge)
+	check("ZBXD\x01\x0A\x00\x00\x00\x00\x00\x00\x00short", nil, io.ErrUnexpectedEOF)
+	check("ZBX", nil, io.ErrUnexpectedEOF)
+
+	// Compressed message that claims a different uncompressed length
+	message, _ := zbxproto.Encode([]byte("agent.ping"), &zbxproto.Options{Compress: true})
+	message[9] = 0x05
+	check(string(message), nil, zbxproto.ErrLengthMismatch)
+}
+
+func TestReadMessageReserved(t *testing.T) {
+	t.Parallel()
+
+	input := "ZBXD\x01\x0a\x00\x00\x00\x07\x00\x00\x00agent.ping"
+	message, err := zbxproto.ReadMessage(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error for non-zero reserved field: %s", err.Error())
+	}
+	if message.Reserved != 7 || string(message.Data) != "agent.ping" {
+		t.Errorf("Unexpected message: %+v", message)
+	}
+
+	strict := &zbxproto.Options{StrictReserved: true}
+	if _, err := zbxproto.ReadMessage(strings.NewReader(input), strict); !errors.Is(err, zbxproto.ErrReservedNotZero) {
+		t.Errorf("Unexpected error for non-zero reserved field in strict mode. Expected '%v' got '%v'", zbxproto.ErrReservedNotZero, err)
+	}
+
+	// The reserved field of compressed messages is the uncompressed length
+	compressed, _ := zbxproto.Encode([]byte("agent.ping"), &zbxproto.Options{Compress: true})
+	if _, err := zbxproto.ReadMessage(bytes.NewReader(compressed), strict); err != nil {
+		t.Errorf("Unexpected error for compressed message in strict mode: %s", err.Error())
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+
+	names := map[string]bool{}
+	for _, fixture := range zbxproto.Fixtures() {
+		if names[fixture.Name] {
+			t.Errorf("Fixture name '%s' is not unique", fixture.Name)
+		}
+		names[fixture.Name] = true
+
+		message, err := zbxproto.ReadMessage(bytes.NewReader(fixture.Raw), nil)
+		if !errors.Is(err, fixture.Err) {
+			t.Errorf("Unexpected error for fixture '%s'. Expected '%v' got '%v'", fixture.Name, fixture.Err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if message.Flags != fixture.Flags || message.Length != fixture.Length || message.Reserved != fixture.Reserved || !bytes.Equal(message.Data, fixture.Data) {
+			t.Errorf("Unexpected message for fixture '%s'. Expected %+v got %+v", fixture.Name, fixture, message)
+		}
+
+		// Encoding the data of uncompressed messages must produce the same message, as there is only one encoding
+		if fixture.Flags&zbxproto.FlagCompressed != 0 || fixture.Reserved != 0 {
+			continue
+		}
+		encoded, err := zbxproto.Encode(fixture.Data, &zbxproto.Options{LargePacket: fixture.Flags&zbxproto.FlagLargePacket != 0})
+		if err != nil {
+			t.Errorf("Error encoding fixture '%s': %s", fixture.Name, err.Error())
+		}
+		if !bytes.Equal(encoded, fixture.Raw) {
+			t.Errorf("Unexpected encoding of fixture '%s'. Expected %x got %x", fixture.Name, fixture.Raw, encoded)
+		}
+	}
+
+	// Each call returns a new copy
+	zbxproto.Fixtures()[0].Raw[0] = 'X'
+	if zbxproto.Fixtures()[0].Raw[0] != 'Z' {
+		t.Errorf("Modifying a fixture changed later calls to Fixtures")
+	}
+}
+
+func TestReadMessageOneByteAtATime(t *testing.T) {
+	t.Parallel()
+
+	for _, fixture := range zbxproto.Fixtures() {
+		if fixture.Err != nil {
+			continue
+		}
+		message, err := zbxproto.ReadMessage(iotest.OneByteReader(bytes.NewReader(fixture.Raw)), nil)
+		if err != nil {
+			t.Errorf("Unexpected error for fixture '%s': %s", fixture.Name, err.Error())
+			continue
+		}
+		if !bytes.Equal(message.Data, fixture.Data) {
+			t.Errorf("Unexpected data for fixture '%s'. Expected %q got %q", fixture.Name, fixture.Data, message.Data)
+		}
+	}
+
+	header, err := zbxproto.ReadHeader(iotest.OneByteReader(strings.NewReader("ZBXD\x05\x0a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00agent.ping")))
+	if err != nil {
+		t.Fatalf("Unexpected error reading header: %s", err.Error())
+	}
+	if header.Length != 10 || header.Data != nil || zbxproto.HeaderLength(header.Flags) != 21 {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+
+	_, err = zbxproto.ReadHeader(strings.NewReader("ZBXD\x7f\x00\x00\x00\x00\x00\x00\x00\x00"))
+	flagsErr := &zbxproto.FlagsError{}
+	if !errors.As(err, &flagsErr) || flagsErr.Flags != 0x7f || !errors.Is(err, zbxproto.ErrUnsupportedFlags) {
+		t.Errorf("Unexpected error for unknown flags: %v", err)
+	}
+}